@@ -0,0 +1,144 @@
+// Command fakegraph runs the fake Instagram Graph API from instagramtest with
+// some sample media, so that the server can be developed against it. Point the
+// server at it with INSTAGRAM_GRAPH_URL.
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+// sampleCaptions are the captions of the seeded media, oldest first
+var sampleCaptions = []string{
+	"Another JavaScript framework dropped today. My node_modules folder is now legally a planet.",
+	"AI will replace programmers, says the AI that just wrote a for loop that never ends.",
+	"Cloud costs explained: you pay for what you use, and you use everything you forgot to turn off.",
+	"Rewrote it in Rust. It's still broken, but now it's memory-safe broken.",
+	"The metaverse called. Nobody picked up.",
+	"Blockchain: a slow database with a marketing department.",
+	"Our standup lasted 45 minutes. We are now sitting down.",
+	"Tabs vs spaces is settled. It's whatever the linter shouts about.",
+	"Quantum computing will break all encryption, right after it finishes booting.",
+	"Dark mode is the only feature users have ever agreed on.",
+	"Microservices: turning one hard problem into forty network calls.",
+	"It works on my machine. Shipping my machine.",
+}
+
+func main() {
+	addr := os.Getenv("FAKE_GRAPH_ADDR")
+	if addr == "" {
+		addr = "localhost:8090"
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", addr, err)
+	}
+
+	server := instagramtest.NewUnstartedServer()
+	server.Listener.Close()
+	server.Listener = listener
+	server.FollowersCount = 1200
+	server.FollowsCount = 180
+	server.Start()
+	defer server.Close()
+
+	if err := seed(server); err != nil {
+		log.Fatalf("Failed to seed fake graph api: %v", err)
+	}
+
+	fmt.Printf("Fake Instagram Graph API running at %s\n", server.URL)
+	fmt.Printf("Run the server with:\n")
+	fmt.Printf("  INSTAGRAM_GRAPH_URL=%s INSTAGRAM_USER_ID=%s INSTAGRAM_ACCESS_TOKEN=%s \\\n",
+		server.URL, server.UserID, server.AccessToken)
+	fmt.Printf("  FACEBOOK_GRAPH_URL=%s FACEBOOK_ACCESS_TOKEN=%s\n",
+		server.URL, server.FacebookAccessToken)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+}
+
+// seed adds a post every few days with a handful of comments, and account insights
+func seed(server *instagramtest.Server) error {
+	published := time.Now().Add(-time.Duration(len(sampleCaptions)) * 3 * 24 * time.Hour)
+	commenters := []string{"devnull_dan", "sudo_sarah", "kernel_panic_kim"}
+
+	for i, caption := range sampleCaptions {
+		media := server.AddMedia(instagram.Media{
+			Caption:   caption,
+			MediaURL:  fmt.Sprintf("https://picsum.photos/seed/fakegraph%d/1080/1080", i),
+			Timestamp: published.Format("2006-01-02T15:04:05-0700"),
+		})
+
+		for j := 0; j <= i%len(commenters); j++ {
+			commentID, err := server.AddComment(media.ID, commenters[j], "This is painfully accurate")
+			if err != nil {
+				return err
+			}
+			if j == 0 && i%2 == 0 {
+				if _, err := server.AddReply(commentID, commenters[len(commenters)-1], "Too real"); err != nil {
+					return err
+				}
+			}
+		}
+
+		published = published.Add(3 * 24 * time.Hour)
+	}
+
+	server.SetAccountInsights(map[string]int{
+		"follower_count": 14,
+		"profile_views":  96,
+		"website_clicks": 7,
+		"reach":          2400,
+	})
+
+	seedDiscovery(server)
+	return nil
+}
+
+// seedDiscovery adds a competitor for business discovery, a tag of the account
+// and a few days of media for #techhumor
+func seedDiscovery(server *instagramtest.Server) {
+	now := time.Now()
+
+	competitor := instagramtest.BusinessAccount{
+		Username:       "byte_sized_jokes",
+		Name:           "Byte Sized Jokes",
+		Biography:      "Daily tech satire",
+		FollowersCount: 5400,
+		FollowsCount:   310,
+	}
+	for i := 0; i < 6; i++ {
+		competitor.Media = append(competitor.Media, instagram.PublishedMedia{
+			Caption:       fmt.Sprintf("Tech joke #%d", 6-i),
+			Timestamp:     now.Add(-time.Duration(i) * 2 * 24 * time.Hour).Format("2006-01-02T15:04:05-0700"),
+			LikeCount:     180 + 40*i,
+			CommentsCount: 12 + i,
+		})
+	}
+	server.AddBusinessAccount(competitor)
+
+	server.AddTag(instagram.MentionedMedia{
+		Caption:   "Stole this from @" + server.Username + " and I regret nothing",
+		Username:  "sudo_sarah",
+		Timestamp: now.Add(-6 * time.Hour).Format("2006-01-02T15:04:05-0700"),
+		LikeCount: 42,
+	})
+
+	for i := 0; i < 8; i++ {
+		server.AddHashtagMedia("techhumor", instagram.HashtagMedia{
+			Caption:       "#techhumor never gets old",
+			Timestamp:     now.Add(-time.Duration(i) * 5 * time.Hour).Format("2006-01-02T15:04:05-0700"),
+			LikeCount:     30 * (i + 1),
+			CommentsCount: i,
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+)
+
+// listAccounts lists the accounts
+func (s *server) listAccounts(c *gin.Context) {
+	accounts, err := s.db.GetAccounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   accounts,
+	})
+}
+
+// createAccount adds an account along with its access token
+func (s *server) createAccount(c *gin.Context) {
+	var request struct {
+		InstagramUserID string `json:"instagram_user_id" binding:"required"`
+		Username        string `json:"username"`
+		Name            string `json:"name"`
+		// Voice and SarcasmLevel set the style of drafted comment replies
+		Voice        string `json:"voice"`
+		SarcasmLevel int    `json:"sarcasm_level" binding:"omitempty,min=1,max=10"`
+		// AccessToken is a short-lived token to exchange for a long-lived one
+		AccessToken string `json:"access_token"`
+		// LongLivedToken is stored as is and refreshed when it is due
+		LongLivedToken string `json:"long_lived_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	token := database.AccessToken{
+		UserID:      request.InstagramUserID,
+		AccessToken: request.LongLivedToken,
+	}
+	if request.AccessToken != "" {
+		appSecret := os.Getenv("INSTAGRAM_APP_SECRET")
+		if appSecret == "" {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "INSTAGRAM_APP_SECRET environment variable not set",
+			})
+			return
+		}
+
+		exchanged, err := exchangeAccessToken(request.AccessToken, appSecret)
+		if err != nil {
+			respondInstagramError(c, err)
+			return
+		}
+		token.AccessToken = exchanged.AccessToken
+		token.TokenType = exchanged.TokenType
+		token.ExpiresAt = &exchanged.ExpiresAt
+	}
+
+	account := database.Account{
+		InstagramUserID: request.InstagramUserID,
+		Username:        request.Username,
+		Name:            request.Name,
+		Voice:           request.Voice,
+		SarcasmLevel:    request.SarcasmLevel,
+	}
+	if err := s.db.SaveAccount(&account); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if token.AccessToken != "" {
+		if err := s.db.SaveAccessToken(&token); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   account,
+	})
+}
+
+// getAccount gets the account of an account-scoped route
+func (s *server) getAccount(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   scopedAccount(c),
+	})
+}
+
+// accountContextKey is the gin context key holding the account of an
+// /accounts/:accountId route
+const accountContextKey = "account"
+
+// loadAccount resolves the :accountId parameter of account-scoped routes
+func loadAccount(db *database.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID, err := strconv.Atoi(c.Param("accountId"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid account ID",
+			})
+			return
+		}
+
+		account, err := db.GetAccount(accountID)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Account not found",
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.Set(accountContextKey, account)
+		c.Next()
+	}
+}
+
+// scopedAccount returns the account of an account-scoped route, or nil for the
+// unscoped routes
+func scopedAccount(c *gin.Context) *database.Account {
+	if value, ok := c.Get(accountContextKey); ok {
+		return value.(*database.Account)
+	}
+	return nil
+}
+
+// requestAccount returns the account a request acts on: the scoped account, or
+// the default account for unscoped routes. It returns nil if there is no
+// default account because INSTAGRAM_USER_ID is not set or there is no database.
+func requestAccount(c *gin.Context, db *database.DB) (*database.Account, error) {
+	if account := scopedAccount(c); account != nil {
+		return account, nil
+	}
+	if db == nil {
+		return nil, nil
+	}
+
+	account, err := db.GetAccountByInstagramID(os.Getenv("INSTAGRAM_USER_ID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return account, err
+}
+
+// requireAccount returns the account a request acts on, writing an error
+// response and returning false if there is none
+func requireAccount(c *gin.Context, db *database.DB) (*database.Account, bool) {
+	account, err := requestAccount(c, db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+	if account == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No default account: set INSTAGRAM_USER_ID or use the /api/accounts/:accountId routes",
+		})
+		return nil, false
+	}
+	return account, true
+}
+
+// ensureDefaultAccount creates the account for INSTAGRAM_USER_ID and gives it
+// the posts created before accounts existed
+func ensureDefaultAccount(db *database.DB) {
+	userID := os.Getenv("INSTAGRAM_USER_ID")
+	if userID == "" {
+		return
+	}
+
+	account := database.Account{InstagramUserID: userID}
+	if err := db.SaveAccount(&account); err != nil {
+		log.Printf("Failed to create default account: %v", err)
+		return
+	}
+
+	moved, err := db.AssignUnownedPosts(account.ID)
+	if err != nil {
+		log.Printf("Failed to assign posts to default account: %v", err)
+		return
+	}
+	if moved > 0 {
+		log.Printf("Assigned %d posts to default account %d", moved, account.ID)
+	}
+}
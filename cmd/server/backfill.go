@@ -0,0 +1,261 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// backfillMedia starts importing the account's media as posts in the background
+func (s *server) backfillMedia(c *gin.Context) {
+	query, err := parseMediaQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	account, err := requestAccount(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var accountID *int
+	if account != nil {
+		accountID = &account.ID
+	}
+
+	// Backfilling a large account takes longer than a request should, so
+	// it runs in the background; poll the job for progress
+	job, started := s.backfills.start(accountID)
+	if !started {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "A backfill is already running for this account",
+			"data":  job,
+		})
+		return
+	}
+	go runBackfill(s.backfills, s.repos.Posts, client, query, job.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// getBackfill reports the progress of a backfill job
+func (s *server) getBackfill(c *gin.Context) {
+	jobID, err := strconv.Atoi(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid job ID",
+		})
+		return
+	}
+
+	job, ok := s.backfills.get(jobID)
+	if account := scopedAccount(c); ok && account != nil &&
+		(job.AccountID == nil || *job.AccountID != account.ID) {
+		ok = false
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Backfill not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// Backfill job statuses
+const (
+	backfillRunning   = "running"
+	backfillSucceeded = "succeeded"
+	backfillFailed    = "failed"
+)
+
+// backfillJob is a media backfill running in the background
+type backfillJob struct {
+	ID        int    `json:"id"`
+	AccountID *int   `json:"account_id"`
+	Status    string `json:"status"`
+	Imported  int    `json:"imported"`
+	Updated   int    `json:"updated"`
+	// Error and Kind describe why a failed backfill stopped. A re-run starts
+	// again from the newest media; it only updates the posts already imported,
+	// since imports are upserted by Instagram ID, and until can skip them.
+	Error      string     `json:"error,omitempty"`
+	Kind       string     `json:"kind,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// backfillJobTTL is how long a finished backfill job can still be polled
+const backfillJobTTL = time.Hour
+
+// backfillJobs tracks the running backfills and those finished in the last
+// backfillJobTTL
+type backfillJobs struct {
+	mu     sync.Mutex
+	lastID int
+	jobs   map[int]*backfillJob
+}
+
+func newBackfillJobs() *backfillJobs {
+	return &backfillJobs{jobs: make(map[int]*backfillJob)}
+}
+
+// start registers a new running backfill for an account. If one is already
+// running for the account, it returns that one and false.
+func (b *backfillJobs) start(accountID *int) (backfillJob, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evictFinished()
+
+	for _, job := range b.jobs {
+		sameAccount := (job.AccountID == nil && accountID == nil) ||
+			(job.AccountID != nil && accountID != nil && *job.AccountID == *accountID)
+		if sameAccount && job.Status == backfillRunning {
+			return *job, false
+		}
+	}
+
+	b.lastID++
+	job := &backfillJob{ID: b.lastID, AccountID: accountID, Status: backfillRunning, StartedAt: time.Now()}
+	b.jobs[job.ID] = job
+	return *job, true
+}
+
+// get returns a copy of a backfill job
+func (b *backfillJobs) get(id int) (backfillJob, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evictFinished()
+
+	job, ok := b.jobs[id]
+	if !ok {
+		return backfillJob{}, false
+	}
+	return *job, true
+}
+
+// update changes a backfill job under the lock
+func (b *backfillJobs) update(id int, change func(job *backfillJob)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if job, ok := b.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// evictFinished forgets the jobs that finished more than backfillJobTTL ago.
+// The caller must hold the lock.
+func (b *backfillJobs) evictFinished() {
+	cutoff := time.Now().Add(-backfillJobTTL)
+	for id, job := range b.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(b.jobs, id)
+		}
+	}
+}
+
+// runBackfill imports the account's media matching query as posts, recording
+// progress on the job as it goes
+func runBackfill(jobs *backfillJobs, posts database.PostRepository, client *instagram.Client, query instagram.MediaQuery, jobID int) {
+	job, _ := jobs.get(jobID)
+
+	finish := func(err error) {
+		jobs.update(jobID, func(job *backfillJob) {
+			now := time.Now()
+			job.FinishedAt = &now
+			job.Status = backfillSucceeded
+			if err != nil {
+				job.Status = backfillFailed
+				job.Error = err.Error()
+				job.Kind = string(instagram.ErrorKindOf(err))
+			}
+		})
+	}
+
+	it := client.IterateMedia(query)
+	for it.Next() {
+		post := postFromMedia(it.Media())
+		post.AccountID = job.AccountID
+		created, err := posts.SaveImportedPost(&post)
+		if err != nil {
+			finish(err)
+			return
+		}
+
+		jobs.update(jobID, func(job *backfillJob) {
+			if created {
+				job.Imported++
+			} else {
+				job.Updated++
+			}
+		})
+	}
+
+	if err := it.Err(); err != nil {
+		log.Printf("Backfill %d stopped: %v", jobID, err)
+		finish(err)
+		return
+	}
+	finish(nil)
+}
+
+// postFromMedia converts published Instagram media into a post record
+func postFromMedia(media instagram.Media) database.Post {
+	post := database.Post{
+		InstagramID: media.ID,
+		Caption:     media.Caption,
+		MediaURL:    media.MediaURL,
+		Permalink:   media.Permalink,
+		Status:      database.PostStatusPosted,
+		MediaType:   database.PostMediaImage,
+	}
+
+	switch {
+	case media.IsCarousel():
+		post.MediaType = database.PostMediaCarousel
+		for _, child := range media.Children {
+			post.Assets = append(post.Assets, database.PostAsset{
+				MediaURL:  child.MediaURL,
+				MediaType: child.MediaType,
+			})
+		}
+	case media.MediaProductType == instagram.ProductTypeReels:
+		post.MediaType = database.PostMediaReels
+	}
+
+	if postedAt, err := media.PublishedAt(); err == nil {
+		post.PostedAt = &postedAt
+	}
+
+	return post
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackfillJobsEvictFinishedJobs(t *testing.T) {
+	jobs := newBackfillJobs()
+	accountID := 1
+
+	running, _ := jobs.start(nil)
+	recent, _ := jobs.start(&accountID)
+	jobs.update(recent.ID, func(job *backfillJob) {
+		finishedAt := time.Now().Add(-backfillJobTTL / 2)
+		job.Status, job.FinishedAt = backfillSucceeded, &finishedAt
+	})
+
+	otherAccount := 2
+	stale, _ := jobs.start(&otherAccount)
+	jobs.update(stale.ID, func(job *backfillJob) {
+		finishedAt := time.Now().Add(-backfillJobTTL - time.Minute)
+		job.Status, job.FinishedAt = backfillFailed, &finishedAt
+	})
+
+	if _, ok := jobs.get(stale.ID); ok {
+		t.Errorf("job finished over %s ago still listed", backfillJobTTL)
+	}
+	for _, id := range []int{running.ID, recent.ID} {
+		if _, ok := jobs.get(id); !ok {
+			t.Errorf("job %d evicted too early", id)
+		}
+	}
+	if len(jobs.jobs) != 2 {
+		t.Errorf("%d jobs kept, want 2", len(jobs.jobs))
+	}
+
+	// A finished job doesn't block a new backfill for its account
+	if _, started := jobs.start(&accountID); !started {
+		t.Errorf("backfill refused after the previous one finished")
+	}
+	if _, started := jobs.start(nil); started {
+		t.Errorf("second backfill started while one is running")
+	}
+}
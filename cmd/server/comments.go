@@ -0,0 +1,264 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// listComments lists a post's comments
+func (s *server) listComments(c *gin.Context) {
+	post, ok := requestPost(c, s.repos.Posts)
+	if !ok {
+		return
+	}
+	if post.InstagramID == "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Post has not been published",
+		})
+		return
+	}
+
+	// Stored comments are returned unless a sync with Instagram is requested
+	if c.Query("refresh") == "true" {
+		client, err := clientForPost(s.db, post)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if err := syncComments(s.db, client, post); err != nil {
+			respondInstagramError(c, err)
+			return
+		}
+	}
+
+	comments, err := s.db.GetMediaComments(post.InstagramID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   comments,
+	})
+}
+
+// replyToComment replies to a comment on Instagram
+func (s *server) replyToComment(c *gin.Context) {
+	post, comment, ok := requestComment(c, s.db, s.repos.Posts)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	client, err := clientForPost(s.db, post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Replies to a reply go to the top-level comment; Instagram threads are one level deep
+	parentID := comment.InstagramID
+	if comment.ParentID != "" {
+		parentID = comment.ParentID
+	}
+
+	replyID, err := client.ReplyToComment(parentID, request.Message)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	now := time.Now()
+	reply := database.Comment{
+		AccountID:   post.AccountID,
+		InstagramID: replyID,
+		MediaID:     post.InstagramID,
+		ParentID:    parentID,
+		UserID:      client.UserID,
+		Text:        request.Message,
+		CommentedAt: &now,
+	}
+	if err := s.db.SaveFetchedComment(&reply); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   reply,
+	})
+}
+
+// setCommentHidden hides or unhides a comment
+func (s *server) setCommentHidden(hidden bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, comment, ok := requestComment(c, s.db, s.repos.Posts)
+		if !ok {
+			return
+		}
+
+		client, err := clientForPost(s.db, post)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if err := client.SetCommentHidden(comment.InstagramID, hidden); err != nil {
+			respondInstagramError(c, err)
+			return
+		}
+		if err := s.db.SetCommentHidden(comment.InstagramID, hidden); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		comment.Hidden = hidden
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   comment,
+		})
+	}
+}
+
+// deleteComment deletes a comment
+func (s *server) deleteComment(c *gin.Context) {
+	post, comment, ok := requestComment(c, s.db, s.repos.Posts)
+	if !ok {
+		return
+	}
+
+	client, err := clientForPost(s.db, post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := client.DeleteComment(comment.InstagramID); err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+	if err := s.db.MarkCommentDeleted(comment.InstagramID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// requestComment loads the post named by :id and its comment named by
+// :commentId, writing an error response and returning false if either is missing
+func requestComment(c *gin.Context, db *database.DB, posts database.PostRepository) (*database.Post, *database.Comment, bool) {
+	post, ok := requestPost(c, posts)
+	if !ok {
+		return nil, nil, false
+	}
+
+	comment, err := db.GetComment(c.Param("commentId"))
+	if err == nil && (post.InstagramID == "" || comment.MediaID != post.InstagramID || comment.DeletedAt != nil) {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Comment not found",
+		})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	return post, comment, true
+}
+
+// syncComments fetches the comments on a published post, with all their
+// replies, and stores them
+func syncComments(db *database.DB, client *instagram.Client, post *database.Post) error {
+	it := client.IterateComments(post.InstagramID, 0)
+	for it.Next() {
+		comment := it.Comment()
+		if err := saveFetchedComment(db, post, comment, ""); err != nil {
+			return err
+		}
+
+		// Only the first page of replies comes with the comment, so fetch them
+		// all when there are any
+		replies := comment.Replies
+		if len(replies) > 0 {
+			var err error
+			if replies, err = client.ListReplies(comment.ID, 0); err != nil {
+				return err
+			}
+		}
+		for _, reply := range replies {
+			if err := saveFetchedComment(db, post, reply, comment.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Err()
+}
+
+// saveFetchedComment stores a comment fetched from Instagram
+func saveFetchedComment(db *database.DB, post *database.Post, comment instagram.Comment, parentID string) error {
+	if comment.ParentID != "" {
+		parentID = comment.ParentID
+	}
+
+	record := database.Comment{
+		AccountID:   post.AccountID,
+		InstagramID: comment.ID,
+		MediaID:     post.InstagramID,
+		ParentID:    parentID,
+		UserID:      comment.From.ID,
+		Username:    comment.Username,
+		Text:        comment.Text,
+		LikeCount:   comment.LikeCount,
+		Hidden:      comment.Hidden,
+	}
+	if record.Username == "" {
+		record.Username = comment.From.Username
+	}
+	if commentedAt, err := comment.CommentedAt(); err == nil {
+		record.CommentedAt = &commentedAt
+	}
+
+	return db.SaveFetchedComment(&record)
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// listCompetitors lists the tracked competitors
+func (s *server) listCompetitors(c *gin.Context) {
+	competitors, err := s.db.GetCompetitors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   competitors,
+	})
+}
+
+// addCompetitor starts tracking a competitor by username
+func (s *server) addCompetitor(c *gin.Context) {
+	var request struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	username, err := instagram.NormalizeUsername(request.Username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	client, err := newInstagramClient(s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Looking the account up first checks that it exists and is a business account
+	competitor := database.Competitor{Username: username}
+	if _, err := snapshotCompetitor(s.db, client, &competitor); err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   competitor,
+	})
+}
+
+// removeCompetitor stops tracking a competitor
+func (s *server) removeCompetitor(c *gin.Context) {
+	competitorID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid competitor ID",
+		})
+		return
+	}
+
+	deleted, err := s.db.DeleteCompetitor(competitorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Competitor not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// getCompetitorSnapshots lists a competitor's snapshots
+func (s *server) getCompetitorSnapshots(c *gin.Context) {
+	competitorID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid competitor ID",
+		})
+		return
+	}
+
+	since, err := parseDaysQuery(c, 90)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if _, err := s.db.GetCompetitor(competitorID); errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Competitor not found",
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	snapshots, err := s.db.GetCompetitorSnapshots(competitorID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   snapshots,
+	})
+}
+
+// snapshotCompetitors snapshots every competitor
+func (s *server) snapshotCompetitors(c *gin.Context) {
+	client, err := newInstagramClient(s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	competitors, err := s.db.GetCompetitors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	snapshots := []database.CompetitorSnapshot{}
+	for i := range competitors {
+		snapshot, err := snapshotCompetitor(s.db, client, &competitors[i])
+		if err != nil {
+			respondInstagramError(c, err)
+			return
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   snapshots,
+	})
+}
+
+// compareCompetitors compares the account with each competitor. Our stats are
+// computed live from our recent media the same way the competitors' snapshots
+// are.
+func (s *server) compareCompetitors(c *gin.Context) {
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	profile, err := client.GetOwnProfile(competitorMediaSample)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+	ours := profile.Stats(time.Now())
+
+	competitors, err := s.db.GetCompetitors()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	comparisons := make([]competitorComparison, 0, len(competitors))
+	for _, competitor := range competitors {
+		comparisons = append(comparisons, compareToCompetitor(ours, competitor))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"ours": gin.H{
+				"username": profile.Username,
+				"stats":    ours,
+			},
+			"competitors": comparisons,
+		},
+	})
+}
+
+// competitorMediaSample is how many recent media competitor stats are based on
+const competitorMediaSample = 25
+
+// competitorSnapshotInterval is how often competitors are snapshotted
+const competitorSnapshotInterval = 24 * time.Hour
+
+// snapshotCompetitor looks a competitor up through business discovery, updates
+// its stored profile and records a snapshot of its stats
+func snapshotCompetitor(db *database.DB, client *instagram.Client, competitor *database.Competitor) (*database.CompetitorSnapshot, error) {
+	profile, err := client.DiscoverBusiness(competitor.Username, competitorMediaSample)
+	if err != nil {
+		return nil, err
+	}
+
+	competitor.InstagramID = profile.ID
+	competitor.Name = profile.Name
+	if err := db.SaveCompetitor(competitor); err != nil {
+		return nil, err
+	}
+
+	stats := profile.Stats(time.Now())
+	snapshot := database.CompetitorSnapshot{
+		CompetitorID:   competitor.ID,
+		FollowersCount: profile.FollowersCount,
+		MediaCount:     profile.MediaCount,
+		MediaSampled:   stats.MediaSampled,
+		PostsPerWeek:   stats.PostsPerWeek,
+		Formats:        stats.Formats,
+		AvgLikes:       stats.AvgLikes,
+		AvgComments:    stats.AvgComments,
+		EngagementRate: stats.EngagementRate,
+	}
+	if err := db.SaveCompetitorSnapshot(&snapshot); err != nil {
+		return nil, err
+	}
+
+	competitor.Latest = &snapshot
+	return &snapshot, nil
+}
+
+// runCompetitorTracker snapshots every competitor whose last snapshot is older
+// than competitorSnapshotInterval, checking every interval
+func runCompetitorTracker(db *database.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		competitors, err := db.GetCompetitorsDueForSnapshot(time.Now().Add(-competitorSnapshotInterval))
+		if err != nil {
+			log.Printf("Failed to load competitors to snapshot: %v", err)
+			continue
+		}
+		if len(competitors) == 0 {
+			continue
+		}
+
+		client, err := newInstagramClient(db)
+		if err == nil && client.FacebookAccessToken == "" {
+			err = instagram.ErrFacebookLoginRequired
+		}
+		if err == nil {
+			err = client.CheckQuota()
+		}
+		if err != nil {
+			log.Printf("Skipping competitor snapshots: %v", err)
+			continue
+		}
+
+		for i := range competitors {
+			if _, err := snapshotCompetitor(db, client, &competitors[i]); err != nil {
+				log.Printf("Failed to snapshot competitor @%s: %v", competitors[i].Username, err)
+			}
+		}
+	}
+}
+
+// competitorComparison sets a competitor's latest stats against ours. Ratios
+// are theirs over ours and are nil when either side has no data.
+type competitorComparison struct {
+	Competitor          database.Competitor `json:"competitor"`
+	PostsPerWeekRatio   *float64            `json:"posts_per_week_ratio"`
+	EngagementRateRatio *float64            `json:"engagement_rate_ratio"`
+	FollowersRatio      *float64            `json:"followers_ratio"`
+	// FormatShare is the share of each format in their sampled media and in ours
+	FormatShare map[string]formatShare `json:"format_share"`
+}
+
+// formatShare is the fraction of sampled media in one format
+type formatShare struct {
+	Theirs float64 `json:"theirs"`
+	Ours   float64 `json:"ours"`
+}
+
+// compareToCompetitor compares our stats with a competitor's latest snapshot
+func compareToCompetitor(ours instagram.ProfileStats, competitor database.Competitor) competitorComparison {
+	comparison := competitorComparison{
+		Competitor:  competitor,
+		FormatShare: make(map[string]formatShare),
+	}
+
+	theirs := competitor.Latest
+	if theirs == nil {
+		return comparison
+	}
+
+	comparison.PostsPerWeekRatio = ratio(theirs.PostsPerWeek, ours.PostsPerWeek)
+	comparison.EngagementRateRatio = ratio(theirs.EngagementRate, ours.EngagementRate)
+	comparison.FollowersRatio = ratio(float64(theirs.FollowersCount), float64(ours.FollowersCount))
+
+	for format, count := range theirs.Formats {
+		share := comparison.FormatShare[format]
+		share.Theirs = float64(count) / float64(theirs.MediaSampled)
+		comparison.FormatShare[format] = share
+	}
+	for format, count := range ours.Formats {
+		share := comparison.FormatShare[format]
+		share.Ours = float64(count) / float64(ours.MediaSampled)
+		comparison.FormatShare[format] = share
+	}
+
+	return comparison
+}
+
+// ratio returns a over b, or nil if b is zero
+func ratio(a, b float64) *float64 {
+	if b == 0 {
+		return nil
+	}
+	r := a / b
+	return &r
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/agents"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+)
+
+// getTechTrends fetches the latest tech news and stores it for trend detection
+func (s *server) getTechTrends(c *gin.Context) {
+	analyzer, err := agents.NewTechTrendAnalyzer()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	news, err := analyzer.FetchTechNews()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	news = storeNews(s.db, news)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   news,
+	})
+}
+
+// generateContentIdeas generates content ideas, aimed at rising topics once
+// there is enough history to spot them, and saves them when ?save=true
+func (s *server) generateContentIdeas(c *gin.Context) {
+	analyzer, err := agents.NewTechTrendAnalyzer()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	news, err := analyzer.FetchTechNews()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	news = storeNews(s.db, news)
+
+	// Aim the ideas at rising topics when there's enough history to spot them
+	trends, err := detectTrends(s.db, agents.NewTrendEngine(), 5)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Suggest the hashtags heating up on Instagram
+	analyzer.HotHashtags, err = hotHashtags(s.db, 10)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var ideas []agents.ContentIdea
+	if len(trends) > 0 {
+		ideas, err = analyzer.GenerateTrendingContentIdeas(trends, 5)
+	} else {
+		ideas, err = analyzer.GenerateContentIdeas(news)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Persist the generated ideas in one go when requested
+	if c.Query("save") == "true" {
+		saved := make([]database.ContentIdea, 0, len(ideas))
+		for _, idea := range ideas {
+			saved = append(saved, database.ContentIdea{
+				Headline:      idea.Headline,
+				Content:       idea.Content,
+				TalkingPoints: idea.TalkingPoints,
+				Hashtags:      idea.Hashtags,
+				SourceURLs:    idea.SourceURLs,
+			})
+		}
+		if err := s.repos.Ideas.SaveContentIdeas(saved); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   saved,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   ideas,
+	})
+}
+
+// getTrends ranks the topics rising in the stored news
+func (s *server) getTrends(c *gin.Context) {
+	engine := agents.NewTrendEngine()
+
+	if raw := c.Query("window_hours"); raw != "" {
+		hours, err := strconv.Atoi(raw)
+		if err != nil || hours < 1 || hours > 24*30 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid window_hours",
+			})
+			return
+		}
+		engine.Window = time.Duration(hours) * time.Hour
+		engine.HalfLife = engine.Window / 2
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit",
+		})
+		return
+	}
+
+	trends, err := detectTrends(s.db, engine, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   trends,
+	})
+}
+
+// enhanceContent rewrites content with the requested level of sarcasm
+func (s *server) enhanceContent(c *gin.Context) {
+	var req struct {
+		Content      string `json:"content" binding:"required"`
+		SarcasmLevel int    `json:"sarcasmLevel" binding:"required,min=1,max=10"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	enhancer, err := agents.NewSarcasmEnhancer()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	enhanced, err := enhancer.EnhanceContent(req.Content, req.SarcasmLevel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   enhanced,
+	})
+}
+
+// listCompanies lists the companies the speculator knows about
+func (s *server) listCompanies(c *gin.Context) {
+	speculator, err := agents.NewBehindScenesSpeculator()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	companies := speculator.ListAvailableCompanies()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   companies,
+	})
+}
+
+// listTopics suggests speculation topics for a company
+func (s *server) listTopics(c *gin.Context) {
+	company := c.Param("company")
+
+	speculator, err := agents.NewBehindScenesSpeculator()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	topics, err := speculator.GenerateTopics(company)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   topics,
+	})
+}
+
+// speculate generates a speculation about a company and stores it
+func (s *server) speculate(c *gin.Context) {
+	var req struct {
+		Company string `json:"company" binding:"required"`
+		Topic   string `json:"topic" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	speculator, err := agents.NewBehindScenesSpeculator()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, err := speculator.GenerateSpeculation(req.Company, req.Topic)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err = s.repos.Speculations.SaveSpeculation(result.Company, req.Topic, result.Headline, result.Speculation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+// listSpeculations lists the most recent speculations
+func (s *server) listSpeculations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit",
+		})
+		return
+	}
+
+	speculations, err := s.repos.Speculations.GetRecentSpeculations(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   speculations,
+	})
+}
+
+// listContentIdeas lists the stored content ideas
+func (s *server) listContentIdeas(c *gin.Context) {
+	ideas, err := s.repos.Ideas.GetContentIdeas()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   ideas,
+	})
+}
+
+// saveContentIdea stores a content idea
+func (s *server) saveContentIdea(c *gin.Context) {
+	var idea database.ContentIdea
+
+	if err := c.ShouldBindJSON(&idea); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err := s.repos.Ideas.SaveContentIdea(&idea)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   idea,
+	})
+}
+
+// saveContentIdeas stores a batch of content ideas all or nothing, so a failure
+// can be retried as a whole
+func (s *server) saveContentIdeas(c *gin.Context) {
+	var ideas []database.ContentIdea
+
+	if err := c.ShouldBindJSON(&ideas); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if len(ideas) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No ideas to save",
+		})
+		return
+	}
+
+	if err := s.repos.Ideas.SaveContentIdeas(ideas); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   ideas,
+	})
+}
+
+// getContentIdeaSources lists the news items a content idea is based on
+func (s *server) getContentIdeaSources(c *gin.Context) {
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid content idea ID",
+		})
+		return
+	}
+
+	sources, err := s.db.GetContentIdeaSources(ideaID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   sources,
+	})
+}
+
+// getNewsHistory lists the stored news items
+func (s *server) getNewsHistory(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit",
+		})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid offset",
+		})
+		return
+	}
+
+	history, err := s.db.GetNewsHistory(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   history,
+	})
+}
+
+// storeNews persists freshly fetched news and returns only the stories that
+// are not near-duplicates of one we've already seen. Without a database, or
+// if saving fails, every story is returned; fresh news beats no news.
+func storeNews(db *database.DB, news []agents.NewsItem) []agents.NewsItem {
+	if db == nil {
+		return news
+	}
+
+	records := make([]database.NewsItem, 0, len(news))
+	for _, item := range news {
+		record := database.NewsItem{
+			URL:     item.URL,
+			Title:   item.Title,
+			Source:  item.Source,
+			Content: item.Content,
+		}
+		if !item.PublishedAt.IsZero() {
+			publishedAt := item.PublishedAt
+			record.PublishedAt = &publishedAt
+		}
+		records = append(records, record)
+	}
+
+	saved, err := db.SaveNewsItems(records)
+	if err != nil {
+		log.Printf("Failed to store news, serving it without deduplication: %v", err)
+		return news
+	}
+
+	unique := make([]agents.NewsItem, 0, len(news))
+	seen := make(map[int]bool)
+	for i, record := range saved {
+		if record.DuplicateOf == nil && !seen[record.ID] {
+			seen[record.ID] = true
+			unique = append(unique, news[i])
+		}
+	}
+
+	return unique
+}
+
+// detectTrends runs the trend engine over the stored news covering its
+// current and previous windows. There is no history, and so no trends,
+// without a database.
+func detectTrends(db *database.DB, engine *agents.TrendEngine, limit int) ([]agents.Trend, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	records, err := db.GetNewsItemsSince(time.Now().Add(-2 * engine.Window))
+	if err != nil {
+		return nil, err
+	}
+
+	news := make([]agents.NewsItem, 0, len(records))
+	for _, record := range records {
+		item := agents.NewsItem{
+			Title:   record.Title,
+			Source:  record.Source,
+			URL:     record.URL,
+			Content: record.Content,
+		}
+		// Undated stories fetched in the current window are left undated so the
+		// engine weighs them down; older ones only count for the previous window
+		if record.PublishedAt != nil {
+			item.PublishedAt = *record.PublishedAt
+		} else if record.FetchedAt.Before(time.Now().Add(-engine.Window)) {
+			item.PublishedAt = record.FetchedAt
+		}
+		news = append(news, item)
+	}
+
+	return engine.Detect(news, limit), nil
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// listHashtags lists the hashtags whose IDs have been looked up
+func (s *server) listHashtags(c *gin.Context) {
+	trends, err := s.db.GetHashtagTrends()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   trends,
+	})
+}
+
+// getHashtagQuota reports the hashtags the account queried in the last week and
+// how many it has left
+func (s *server) getHashtagQuota(c *gin.Context) {
+	userID := requestInstagramUserID(c)
+	queries, err := s.db.GetHashtagQueries(userID, time.Now().Add(-instagram.HashtagWindow))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	remaining := instagram.HashtagWeeklyLimit - len(queries)
+	if remaining < 0 {
+		remaining = 0
+	}
+	var resetAt *time.Time
+	if len(queries) > 0 {
+		reset := queries[0].FirstQueriedAt.Add(instagram.HashtagWindow)
+		resetAt = &reset
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"limit":     instagram.HashtagWeeklyLimit,
+			"used":      len(queries),
+			"remaining": remaining,
+			"reset_at":  resetAt,
+			"hashtags":  queries,
+		},
+	})
+}
+
+// searchHashtag looks up the ID of a hashtag
+func (s *server) searchHashtag(c *gin.Context) {
+	name := instagram.NormalizeHashtag(c.Query("q"))
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "q is required",
+		})
+		return
+	}
+
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	hashtag, err := lookupHashtag(s.db, client, name)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   hashtag,
+	})
+}
+
+// getHashtagMedia gets the top or recent media of a hashtag
+func (s *server) getHashtagMedia(c *gin.Context) {
+	edge := instagram.HashtagEdgeTop
+	switch c.DefaultQuery("edge", "top") {
+	case "top":
+	case "recent":
+		edge = instagram.HashtagEdgeRecent
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "edge must be top or recent",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "25"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit",
+		})
+		return
+	}
+
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	hashtag, err := lookupHashtag(s.db, client, instagram.NormalizeHashtag(c.Param("name")))
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	var media []instagram.HashtagMedia
+	if edge == instagram.HashtagEdgeRecent {
+		media, err = client.GetHashtagRecentMedia(hashtag.HashtagID, limit)
+	} else {
+		media, err = client.GetHashtagTopMedia(hashtag.HashtagID, limit)
+	}
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"hashtag": hashtag,
+			"media":   media,
+		},
+	})
+}
+
+// trackHashtag starts snapshotting a hashtag. Tracked hashtags are snapshotted
+// with the default account's client and count towards its weekly limit.
+func (s *server) trackHashtag(c *gin.Context) {
+	client, err := newInstagramClient(s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	hashtag, err := lookupHashtag(s.db, client, instagram.NormalizeHashtag(c.Param("name")))
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	if _, err := s.db.SetHashtagTracked(hashtag.Name, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	hashtag.Tracked = true
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   hashtag,
+	})
+}
+
+// untrackHashtag stops snapshotting a hashtag
+func (s *server) untrackHashtag(c *gin.Context) {
+	untracked, err := s.db.SetHashtagTracked(instagram.NormalizeHashtag(c.Param("name")), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !untracked {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Hashtag not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// snapshotHashtags snapshots every tracked hashtag
+func (s *server) snapshotHashtags(c *gin.Context) {
+	client, err := newInstagramClient(s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	snapshots, err := snapshotTrackedHashtags(s.db, client)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   snapshots,
+	})
+}
+
+// lookupHashtag returns the ID of a hashtag, searching for it only if it isn't
+// known yet. Either way the query counts against the account's weekly limit,
+// failing with a *HashtagLimitError once the window is full.
+func lookupHashtag(db *database.DB, client *instagram.Client, name string) (*database.Hashtag, error) {
+	if name == "" {
+		return nil, fmt.Errorf("hashtag is required")
+	}
+
+	hashtag, err := db.GetHashtagByName(name)
+	if err == nil {
+		if err := client.ReserveHashtag(name); err != nil {
+			return nil, err
+		}
+		return hashtag, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	hashtagID, err := client.SearchHashtag(name)
+	if err != nil {
+		return nil, err
+	}
+	hashtag = &database.Hashtag{HashtagID: hashtagID, Name: name}
+	if err := db.SaveHashtag(hashtag); err != nil {
+		return nil, err
+	}
+	return hashtag, nil
+}
+
+// hashtagRecentMediaCap is the most recent media a hashtag snapshot counts
+const hashtagRecentMediaCap = 100
+
+// snapshotHashtag records the current top and recent activity of a hashtag
+func snapshotHashtag(db *database.DB, client *instagram.Client, hashtag *database.Hashtag) (*database.HashtagSnapshot, error) {
+	top, err := client.GetHashtagTopMedia(hashtag.HashtagID, 50)
+	if err != nil {
+		return nil, err
+	}
+
+	// One more than the cap tells whether it was hit
+	recent, err := client.GetHashtagRecentMedia(hashtag.HashtagID, hashtagRecentMediaCap+1)
+	if err != nil {
+		return nil, err
+	}
+	capped := len(recent) > hashtagRecentMediaCap
+	if capped {
+		recent = recent[:hashtagRecentMediaCap]
+	}
+
+	snapshot := database.HashtagSnapshot{
+		HashtagID:         hashtag.HashtagID,
+		TopMediaCount:     len(top),
+		RecentMediaCount:  len(recent),
+		RecentMediaCapped: capped,
+	}
+	for _, media := range top {
+		snapshot.TopLikes += media.LikeCount
+		snapshot.TopComments += media.CommentsCount
+	}
+	for _, media := range recent {
+		snapshot.RecentLikes += media.LikeCount
+		snapshot.RecentComments += media.CommentsCount
+	}
+
+	if err := db.SaveHashtagSnapshot(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// snapshotTrackedHashtags snapshots every tracked hashtag, stopping at the
+// first error
+func snapshotTrackedHashtags(db *database.DB, client *instagram.Client) ([]database.HashtagSnapshot, error) {
+	hashtags, err := db.GetTrackedHashtags()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []database.HashtagSnapshot{}
+	for _, tracked := range hashtags {
+		hashtag, err := lookupHashtag(db, client, tracked.Name)
+		if err != nil {
+			return snapshots, err
+		}
+
+		snapshot, err := snapshotHashtag(db, client, hashtag)
+		if err != nil {
+			return snapshots, err
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// runHashtagTracker snapshots the tracked hashtags every interval
+func runHashtagTracker(db *database.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		client, err := newInstagramClient(db)
+		if err == nil && client.FacebookAccessToken == "" {
+			err = instagram.ErrFacebookLoginRequired
+		}
+		if err == nil {
+			err = client.CheckQuota()
+		}
+		if err != nil {
+			log.Printf("Skipping hashtag snapshots: %v", err)
+			continue
+		}
+
+		snapshots, err := snapshotTrackedHashtags(db, client)
+		if err != nil {
+			log.Printf("Failed to snapshot hashtags: %v", err)
+		}
+		if len(snapshots) > 0 {
+			log.Printf("Snapshotted %d hashtags", len(snapshots))
+		}
+	}
+}
+
+// hotHashtags returns up to limit tracked hashtags whose posting volume is
+// rising, hottest first. Hashtags are only tracked with a database.
+func hotHashtags(db *database.DB, limit int) ([]string, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	trends, err := db.GetHashtagTrends()
+	if err != nil {
+		return nil, err
+	}
+
+	var hashtags []string
+	for _, trend := range trends {
+		if trend.Heat <= 0 || len(hashtags) >= limit {
+			break
+		}
+		hashtags = append(hashtags, "#"+trend.Name)
+	}
+	return hashtags, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// getMediaInsights gets the insights of a media
+func (s *server) getMediaInsights(c *gin.Context) {
+	mediaID := c.Param("mediaId")
+
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	insights, err := client.GetMediaInsights(mediaID)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   insights,
+	})
+}
+
+// getAccountInsights gets the account's insights
+func (s *server) getAccountInsights(c *gin.Context) {
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	insights, err := client.GetAccountInsights()
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	audience, err := client.GetAudience()
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"insights": insights,
+			"audience": audience,
+		},
+	})
+}
+
+// snapshotAccountInsights stores a snapshot of the account's insights
+func (s *server) snapshotAccountInsights(c *gin.Context) {
+	account, ok := requireAccount(c, s.db)
+	if !ok {
+		return
+	}
+
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	snapshot, err := takeInsightsSnapshot(s.db, client, account.ID)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   snapshot,
+	})
+}
+
+// getInsightsHistory lists the stored snapshots of the account's insights
+func (s *server) getInsightsHistory(c *gin.Context) {
+	account, ok := requireAccount(c, s.db)
+	if !ok {
+		return
+	}
+
+	since, err := parseDaysQuery(c, 28)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	snapshots, err := s.db.GetAccountInsightsSnapshots(account.ID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   snapshots,
+	})
+}
+
+// getFollowerGrowth charts follower growth from the daily snapshots, with the
+// posts published each day alongside so growth can be tied to content
+func (s *server) getFollowerGrowth(c *gin.Context) {
+	account, ok := requireAccount(c, s.db)
+	if !ok {
+		return
+	}
+
+	since, err := parseDaysQuery(c, 90)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	points, err := s.db.GetFollowerGrowth(account.ID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	netChange := 0
+	if len(points) > 1 {
+		netChange = points[len(points)-1].Followers - points[0].Followers
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"account_id": account.ID,
+			"points":     points,
+			"net_change": netChange,
+		},
+	})
+}
+
+// takeInsightsSnapshot fetches an account's insights and audience and stores
+// them as today's snapshot
+func takeInsightsSnapshot(db *database.DB, client *instagram.Client, accountID int) (*database.AccountInsightsSnapshot, error) {
+	insights, err := client.GetAccountInsights()
+	if err != nil {
+		return nil, err
+	}
+
+	audience, err := client.GetAudience()
+	if err != nil {
+		return nil, err
+	}
+
+	// Metrics the API doesn't offer are stored as null rather than as zero
+	unsupported := make(map[string]bool)
+	for _, metric := range insights.Unsupported {
+		unsupported[metric] = true
+	}
+	metric := func(name, period string, value int) *int {
+		if unsupported[name+"/"+period] {
+			return nil
+		}
+		return &value
+	}
+
+	snapshot := database.AccountInsightsSnapshot{
+		AccountID:         accountID,
+		Date:              time.Now().UTC(),
+		FollowersCount:    insights.FollowersCount,
+		FollowsCount:      insights.FollowsCount,
+		MediaCount:        insights.MediaCount,
+		NewFollowers:      metric("follower_count", instagram.PeriodDay, insights.NewFollowers),
+		ProfileViews:      metric("profile_views", instagram.PeriodDay, insights.ProfileViews),
+		WebsiteClicks:     metric("website_clicks", instagram.PeriodDay, insights.WebsiteClicks),
+		ReachDay:          metric("reach", instagram.PeriodDay, insights.Reach[instagram.PeriodDay]),
+		ReachWeek:         metric("reach", instagram.PeriodWeek, insights.Reach[instagram.PeriodWeek]),
+		Reach28Days:       metric("reach", instagram.PeriodDays28, insights.Reach[instagram.PeriodDays28]),
+		AudienceCities:    audience.Cities,
+		AudienceCountries: audience.Countries,
+		AudienceAgeGender: audience.AgeGender,
+	}
+	if err := db.SaveAccountInsightsSnapshot(&snapshot); err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// runInsightsSnapshotter snapshots the insights of every account that has no
+// snapshot for the current UTC day yet, checking every interval
+func runInsightsSnapshotter(db *database.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		accounts, err := db.GetAccountsWithoutSnapshot(time.Now().UTC())
+		if err != nil {
+			log.Printf("Failed to load accounts to snapshot: %v", err)
+			continue
+		}
+
+		for _, account := range accounts {
+			client, err := newUserClient(db, account.InstagramUserID)
+			if err == nil {
+				err = client.CheckQuota()
+			}
+			if err != nil {
+				log.Printf("Skipping insights snapshot of account %d: %v", account.ID, err)
+				continue
+			}
+
+			if _, err := takeInsightsSnapshot(db, client, account.ID); err != nil {
+				log.Printf("Failed to snapshot insights of account %d: %v", account.ID, err)
+			}
+		}
+	}
+}
+
+// parseDaysQuery reads the days query parameter, defaulting to defaultDays,
+// and returns the start of the window it covers
+func parseDaysQuery(c *gin.Context, defaultDays int) (time.Time, error) {
+	days := defaultDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 3650 {
+			return time.Time{}, fmt.Errorf("invalid days: must be between 1 and 3650")
+		}
+		days = parsed
+	}
+
+	return time.Now().UTC().AddDate(0, 0, -days), nil
+}
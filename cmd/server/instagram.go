@@ -0,0 +1,264 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// listMedia lists the account's media, optionally within a time window
+func (s *server) listMedia(c *gin.Context) {
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	query, err := parseMediaQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 25
+	}
+
+	media, err := client.ListMedia(query)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   media,
+	})
+}
+
+// getUsage reports the API quota used by the app and every account
+func (s *server) getUsage(c *gin.Context) {
+	// Usage is only known from response headers, so there's nothing to
+	// report until a call has been made unless a refresh is requested
+	if c.Query("refresh") != "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   instagram.CurrentUsage(),
+		})
+		return
+	}
+
+	client, err := newInstagramClient(s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	usage, err := client.RefreshUsage()
+	if err != nil {
+		c.JSON(instagramErrorStatus(err), gin.H{
+			"error": err.Error(),
+			"kind":  instagram.ErrorKindOf(err),
+			"data":  usage,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   usage,
+	})
+}
+
+// getAccountUsage reports the quota that applies to one account's calls:
+// the app's and the account's own
+func (s *server) getAccountUsage(c *gin.Context) {
+	if c.Query("refresh") != "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   instagram.AccountUsage(requestInstagramUserID(c)),
+		})
+		return
+	}
+
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	usage, err := client.RefreshUsage()
+	if err != nil {
+		c.JSON(instagramErrorStatus(err), gin.H{
+			"error": err.Error(),
+			"kind":  instagram.ErrorKindOf(err),
+			"data":  usage,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   usage,
+	})
+}
+
+// requestInstagramUserID returns the Instagram user ID a request acts on
+func requestInstagramUserID(c *gin.Context) string {
+	if account := scopedAccount(c); account != nil {
+		return account.InstagramUserID
+	}
+	return os.Getenv("INSTAGRAM_USER_ID")
+}
+
+// requestClient creates an Instagram client for the account a request acts on
+func requestClient(c *gin.Context, db *database.DB) (*instagram.Client, error) {
+	return newUserClient(db, requestInstagramUserID(c))
+}
+
+// clientForPost creates an Instagram client for the account a post belongs to
+func clientForPost(db *database.DB, post *database.Post) (*instagram.Client, error) {
+	return clientForAccount(db, post.AccountID)
+}
+
+// clientForAccount creates an Instagram client for an account, or for the
+// default account if accountID is nil
+func clientForAccount(db *database.DB, accountID *int) (*instagram.Client, error) {
+	if accountID == nil {
+		return newInstagramClient(db)
+	}
+	if db == nil {
+		return nil, fmt.Errorf("account %d can't be loaded without a database", *accountID)
+	}
+
+	account, err := db.GetAccount(*accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account %d: %w", *accountID, err)
+	}
+	return newUserClient(db, account.InstagramUserID)
+}
+
+// newInstagramClient creates an Instagram client for the default account
+func newInstagramClient(db *database.DB) (*instagram.Client, error) {
+	return newUserClient(db, os.Getenv("INSTAGRAM_USER_ID"))
+}
+
+// newUserClient creates an Instagram client using the stored access token of an
+// Instagram user. The default account falls back to INSTAGRAM_ACCESS_TOKEN until
+// a token is stored for it, or always without a database.
+func newUserClient(db *database.DB, userID string) (*instagram.Client, error) {
+	client, err := newTokenClient(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if db != nil {
+		client.HashtagLedger = db
+	}
+	return client, nil
+}
+
+// newTokenClient creates an Instagram client with the access token
+// newUserClient picks for an Instagram user
+func newTokenClient(db *database.DB, userID string) (*instagram.Client, error) {
+	var token *database.AccessToken
+	err := sql.ErrNoRows
+	if db != nil {
+		token, err = db.GetAccessToken(userID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		if userID == "" || userID == os.Getenv("INSTAGRAM_USER_ID") {
+			return instagram.NewClient()
+		}
+		return nil, fmt.Errorf("no access token stored for Instagram user %s", userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return nil, fmt.Errorf("stored Instagram access token for user %s expired at %s; exchange a new one",
+			userID, token.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return instagram.NewClientFor(userID, token.AccessToken)
+}
+
+// instagramErrorStatus maps a Graph API error to the HTTP status reported to
+// API clients. Errors that didn't come from the Graph API are a bad gateway.
+func instagramErrorStatus(err error) int {
+	switch instagram.ErrorKindOf(err) {
+	case instagram.ErrorKindOAuth:
+		return http.StatusUnauthorized
+	case instagram.ErrorKindPermission:
+		return http.StatusForbidden
+	case instagram.ErrorKindRateLimit:
+		return http.StatusTooManyRequests
+	case instagram.ErrorKindInvalidParameter:
+		return http.StatusBadRequest
+	case instagram.ErrorKindTransient, instagram.ErrorKindNetwork:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// respondInstagramError writes a failed Graph API call as an error response
+func respondInstagramError(c *gin.Context, err error) {
+	c.JSON(instagramErrorStatus(err), gin.H{
+		"error": err.Error(),
+		"kind":  instagram.ErrorKindOf(err),
+	})
+}
+
+// parseMediaQuery reads the limit, since and until query parameters.
+// Times may be given as RFC 3339 timestamps or YYYY-MM-DD dates.
+func parseMediaQuery(c *gin.Context) (instagram.MediaQuery, error) {
+	var query instagram.MediaQuery
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return query, fmt.Errorf("invalid limit: %q", raw)
+		}
+		query.Limit = limit
+	}
+
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &query.Since}, {"until", &query.Until}} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", raw)
+			// A bare until date includes the whole day
+			if err == nil && param.dest == &query.Until {
+				t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		}
+		if err != nil {
+			return query, fmt.Errorf("invalid %s: %q", param.name, raw)
+		}
+		*param.dest = t
+	}
+
+	return query, nil
+}
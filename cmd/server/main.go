@@ -3,402 +3,180 @@ package main
 import (
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"strconv"
+	"time"
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
 	"github.com/igo-used/instagram-ai-agents/internal/agents"
 	"github.com/igo-used/instagram-ai-agents/internal/database"
-	"github.com/igo-used/instagram-ai-agents/internal/instagram"
 )
 
 func main() {
-	// Initialize database
-	db, err := database.New()
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-
-	err = db.Initialize()
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-
-	// Initialize Gin router
-	r := gin.Default()
-
-	// Configure CORS
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-	}))
-
-	// Serve static files
-	r.Static("/static", "./web/static")
-	r.LoadHTMLGlob("web/templates/*")
-
-	// Web UI routes
-	r.GET("/", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "index.html", gin.H{
-			"title": "Instagram AI Agents",
-		})
-	})
-
-	r.GET("/dashboard", func(c *gin.Context) {
-		c.HTML(http.StatusOK, "dashboard.html", gin.H{
-			"title": "Dashboard | Instagram AI Agents",
-		})
-	})
-
-	// API routes
-	api := r.Group("/api")
-	{
-		// Tech Trend Analyzer routes
-		api.GET("/tech-trends", func(c *gin.Context) {
-			analyzer, err := agents.NewTechTrendAnalyzer()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			news, err := analyzer.FetchTechNews()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   news,
-			})
-		})
-
-		api.GET("/content-ideas", func(c *gin.Context) {
-			analyzer, err := agents.NewTechTrendAnalyzer()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			news, err := analyzer.FetchTechNews()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			ideas, err := analyzer.GenerateContentIdeas(news)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   ideas,
-			})
-		})
-
-		// Sarcasm Enhancer routes
-		api.POST("/enhance-content", func(c *gin.Context) {
-			var req struct {
-				Content      string `json:"content" binding:"required"`
-				SarcasmLevel int    `json:"sarcasmLevel" binding:"required,min=1,max=10"`
-			}
-
-			if err := c.ShouldBindJSON(&req); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			enhancer, err := agents.NewSarcasmEnhancer()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			enhanced, err := enhancer.EnhanceContent(req.Content, req.SarcasmLevel)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   enhanced,
-			})
-		})
-
-		// Behind the Scenes Speculator routes
-		api.GET("/companies", func(c *gin.Context) {
-			speculator, err := agents.NewBehindScenesSpeculator()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			companies := speculator.ListAvailableCompanies()
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   companies,
-			})
-		})
-
-		api.GET("/topics/:company", func(c *gin.Context) {
-			company := c.Param("company")
-
-			speculator, err := agents.NewBehindScenesSpeculator()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			topics, err := speculator.GenerateTopics(company)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   topics,
-			})
-		})
-
-		api.POST("/speculate", func(c *gin.Context) {
-			var req struct {
-				Company string `json:"company" binding:"required"`
-				Topic   string `json:"topic" binding:"required"`
-			}
-
-			if err := c.ShouldBindJSON(&req); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			speculator, err := agents.NewBehindScenesSpeculator()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			result, err := speculator.GenerateSpeculation(req.Company, req.Topic)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   result,
-			})
-		})
-
-		// Instagram routes
-		api.GET("/instagram/media", func(c *gin.Context) {
-			client, err := instagram.NewClient()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			media, err := client.GetRecentMedia()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   media,
-			})
-		})
+	// STORAGE=memory runs the core features without Postgres; nothing is kept
+	// across restarts and the routes that need the database are unavailable
+	var db *database.DB
+	var repos database.Repositories
+	if os.Getenv("STORAGE") == "memory" {
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			log.Fatalf("Nothing to migrate: STORAGE is set to memory")
+		}
 
-		api.GET("/instagram/insights/:mediaId", func(c *gin.Context) {
-			mediaID := c.Param("mediaId")
+		log.Printf("Using in-memory storage: data is lost on restart and database-only routes are disabled")
+		repos = database.NewMemoryRepositories()
+	} else {
+		var err error
+		db, err = database.New()
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
 
-			client, err := instagram.NewClient()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
+		if len(os.Args) > 1 && os.Args[1] == "migrate" {
+			if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+				log.Fatalf("Migration failed: %v", err)
 			}
+			return
+		}
 
-			insights, err := client.GetMediaInsights(mediaID)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
+		// Refuse to run against a schema from a newer build, and bring an older one up to date
+		pending, err := db.CheckSchema()
+		if err != nil {
+			log.Fatalf("Refusing to start: %v", err)
+		}
+		if pending > 0 {
+			log.Printf("Applying %d pending database migrations", pending)
+			if err := db.Migrate(); err != nil {
+				log.Fatalf("Failed to migrate database: %v", err)
 			}
+		}
 
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   insights,
-			})
-		})
-
-		// Database routes
-		api.GET("/content-ideas/db", func(c *gin.Context) {
-			ideas, err := db.GetContentIdeas()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
+		repos = db.Repositories()
+	}
 
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   ideas,
-			})
-		})
+	srv := &server{db: db, repos: repos, backfills: newBackfillJobs()}
+	r := srv.router()
 
-		api.POST("/content-ideas/db", func(c *gin.Context) {
-			var idea database.ContentIdea
+	if db != nil {
+		startDatabaseJobs(db)
+	}
 
-			if err := c.ShouldBindJSON(&idea); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
+	// Publish scheduled posts in the background; each post is published with
+	// the client of the account it belongs to
+	go runPublishScheduler(db, repos.Posts, 30*time.Second)
 
-			err := db.SaveContentIdea(&idea)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
+	// Start server
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
 
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   idea,
-			})
-		})
+	fmt.Printf("Server running on port %s\n", port)
+	log.Fatal(r.Run(":" + port))
+}
 
-		api.GET("/posts", func(c *gin.Context) {
-			posts, err := db.GetPosts()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
+// startDatabaseJobs sets up the default account and starts the background
+// jobs that keep their state in the database
+func startDatabaseJobs(db *database.DB) {
+	ensureDefaultAccount(db)
+
+	// Keep stored access tokens fresh; the env token is stored on first start so
+	// that it gets refreshed too
+	if os.Getenv("TOKEN_ENCRYPTION_KEY") == "" {
+		log.Printf("Access token refresh disabled: TOKEN_ENCRYPTION_KEY environment variable not set")
+	} else {
+		seedAccessToken(db)
+		go runTokenRefresher(db, time.Hour)
+	}
 
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   posts,
-			})
-		})
+	// Snapshot tracked hashtags so we can see which are heating up
+	go runHashtagTracker(db, 6*time.Hour)
 
-		api.POST("/posts", func(c *gin.Context) {
-			var post database.Post
+	// Pick up tags and fill in the details of mentions received by webhook
+	go runMentionSync(db, time.Hour)
 
-			if err := c.ShouldBindJSON(&post); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
+	// Snapshot competitors once a day
+	go runCompetitorTracker(db, time.Hour)
 
-			err := db.SavePost(&post)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
+	// Snapshot every account's insights once a day for the growth curves
+	go runInsightsSnapshotter(db, time.Hour)
 
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   post,
-			})
-		})
+	// Draft replies to new comments in the background; drafts wait for approval
+	responder, err := agents.NewCommentResponder()
+	if err != nil {
+		log.Printf("Comment reply drafting disabled: %v", err)
+	} else {
+		go runReplyDrafter(db, responder, 5*time.Minute)
+	}
+}
 
-		api.GET("/analytics/:postId", func(c *gin.Context) {
-			postIDStr := c.Param("postId")
-			postID, err := strconv.Atoi(postIDStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error": "Invalid post ID",
-				})
-				return
-			}
+// runMigrateCommand runs "migrate [up | down [n] | to <version> | status]"
+// and prints the resulting migration status
+func runMigrateCommand(db *database.DB, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
 
-			analytics, err := db.GetAnalyticsForPost(postID)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": err.Error(),
-				})
-				return
+	switch command {
+	case "up":
+		if err := db.Migrate(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			var err error
+			steps, err = strconv.Atoi(args[0])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to revert: %q", args[0])
 			}
-
-			c.JSON(http.StatusOK, gin.H{
-				"status": "success",
-				"data":   analytics,
-			})
-		})
-
-		// Initialize database
-		db, err := database.New()
+		}
+		version, err := db.SchemaVersion()
 		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
+			return err
 		}
-		defer db.Close()
-
-		// Initialize schema
-		err = db.InitSchema()
+		target := version - steps
+		if target < 0 {
+			target = 0
+		}
+		if err := db.MigrateTo(target); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: migrate to <version>")
+		}
+		version, err := strconv.Atoi(args[0])
 		if err != nil {
-			log.Fatalf("Failed to initialize database schema: %v", err)
+			return fmt.Errorf("invalid schema version: %q", args[0])
+		}
+		if err := db.MigrateTo(version); err != nil {
+			return err
 		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q; use up, down [n], to <version> or status", command)
 	}
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	statuses, err := db.GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Server running on port %s\n", port)
-	log.Fatal(r.Run(":" + port))
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		if status.Unknown {
+			state += ", unknown to this build"
+		}
+		fmt.Printf("%4d  %-70s %s\n", status.Version, status.Description, state)
+	}
+	fmt.Printf("Schema version %d, latest %d\n", version, database.LatestSchemaVersion())
+
+	return nil
 }
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// listMentions lists the stored mentions and tags
+func (s *server) listMentions(c *gin.Context) {
+	filter := database.MentionFilter{Kind: c.Query("kind")}
+	if account := scopedAccount(c); account != nil {
+		filter.AccountID = &account.ID
+	}
+	if raw := c.Query("needs_response"); raw != "" {
+		needsResponse, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "needs_response must be true or false",
+			})
+			return
+		}
+		filter.NeedsResponse = &needsResponse
+	}
+
+	mentions, err := s.db.GetMentions(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   mentions,
+	})
+}
+
+// syncAccountMentions syncs the media the account is tagged in
+func (s *server) syncAccountMentions(c *gin.Context) {
+	account, err := requestAccount(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	var accountID *int
+	if account != nil {
+		accountID = &account.ID
+	}
+
+	client, err := requestClient(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	synced, err := syncMentions(s.db, client, accountID)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"synced": synced,
+		},
+	})
+}
+
+// replyToMention replies to a mention on Instagram
+func (s *server) replyToMention(c *gin.Context) {
+	mention, ok := requestMention(c, s.db)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if mention.Kind == database.MentionKindTag {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Instagram doesn't allow replying to tags; dismiss the mention once handled",
+		})
+		return
+	}
+
+	client, err := clientForAccount(s.db, mention.AccountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	responseID, err := client.ReplyToMention(mention.MediaID, mention.CommentID, request.Message)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+	if err := s.db.MarkMentionResponded(mention.ID, responseID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	mention, err = s.db.GetMention(mention.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   mention,
+	})
+}
+
+// setMentionNeedsResponse flags whether a mention still needs a response
+func (s *server) setMentionNeedsResponse(needsResponse bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mention, ok := requestMention(c, s.db)
+		if !ok {
+			return
+		}
+
+		if err := s.db.SetMentionNeedsResponse(mention.ID, needsResponse); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		mention.NeedsResponse = needsResponse
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data":   mention,
+		})
+	}
+}
+
+// requestMention loads the mention named by the :mentionId parameter, writing
+// an error response and returning false if it doesn't exist or belongs to
+// another account than the scoped one
+func requestMention(c *gin.Context, db *database.DB) (*database.Mention, bool) {
+	mentionID, err := strconv.Atoi(c.Param("mentionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid mention ID",
+		})
+		return nil, false
+	}
+
+	mention, err := db.GetMention(mentionID)
+	if account := scopedAccount(c); err == nil && account != nil &&
+		(mention.AccountID == nil || *mention.AccountID != account.ID) {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Mention not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+
+	return mention, true
+}
+
+// mentionSyncBatchSize caps the tags and webhook mentions fetched per sync
+const mentionSyncBatchSize = 100
+
+// syncMentions stores the media an account is tagged in and fetches the media
+// and comments of mentions received by webhook, returning how many mentions
+// were stored or filled in
+func syncMentions(db *database.DB, client *instagram.Client, accountID *int) (int, error) {
+	synced := 0
+
+	// Tags need Facebook Login; mentions received by webhook are fetched without it
+	tags, err := client.ListTags(mentionSyncBatchSize)
+	if err != nil && !errors.Is(err, instagram.ErrFacebookLoginRequired) {
+		return synced, err
+	}
+	for _, tag := range tags {
+		mention := database.Mention{
+			AccountID:     accountID,
+			Kind:          database.MentionKindTag,
+			MediaID:       tag.ID,
+			Username:      tag.Username,
+			Text:          tag.Caption,
+			Permalink:     tag.Permalink,
+			MediaType:     tag.MediaType,
+			LikeCount:     tag.LikeCount,
+			CommentsCount: tag.CommentsCount,
+		}
+		if publishedAt, err := tag.PublishedAt(); err == nil {
+			mention.MentionedAt = &publishedAt
+		}
+		if err := db.SaveFetchedMention(&mention); err != nil {
+			return synced, err
+		}
+		synced++
+	}
+
+	unfetched, err := db.GetUnfetchedMentions(accountID, mentionSyncBatchSize)
+	if err != nil {
+		return synced, err
+	}
+	for i := range unfetched {
+		mention := &unfetched[i]
+		if err := fetchMention(client, mention); err != nil {
+			// Media and comments that were deleted or made private can't be
+			// fetched; store them as they are rather than retrying forever
+			if instagram.ErrorKindOf(err) != instagram.ErrorKindInvalidParameter {
+				return synced, err
+			}
+			log.Printf("Could not fetch mention %d: %v", mention.ID, err)
+		}
+		if err := db.SaveFetchedMention(mention); err != nil {
+			return synced, err
+		}
+		synced++
+	}
+
+	return synced, nil
+}
+
+// fetchMention fills in a webhook mention from its media or comment
+func fetchMention(client *instagram.Client, mention *database.Mention) error {
+	if mention.Kind == database.MentionKindComment {
+		comment, err := client.GetMentionedComment(mention.CommentID)
+		if err != nil {
+			return err
+		}
+		mention.Username = comment.Username
+		mention.Text = comment.Text
+		mention.Permalink = comment.Media.Permalink
+		mention.LikeCount = comment.LikeCount
+		if commentedAt, err := comment.CommentedAt(); err == nil {
+			mention.MentionedAt = &commentedAt
+		}
+		return nil
+	}
+
+	media, err := client.GetMentionedMedia(mention.MediaID)
+	if err != nil {
+		return err
+	}
+	mention.Username = media.Username
+	mention.Text = media.Caption
+	mention.Permalink = media.Permalink
+	mention.MediaType = media.MediaType
+	mention.LikeCount = media.LikeCount
+	mention.CommentsCount = media.CommentsCount
+	if publishedAt, err := media.PublishedAt(); err == nil {
+		mention.MentionedAt = &publishedAt
+	}
+	return nil
+}
+
+// runMentionSync syncs the mentions of every account every interval
+func runMentionSync(db *database.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		accounts, err := db.GetAccounts()
+		if err != nil {
+			log.Printf("Failed to load accounts to sync mentions for: %v", err)
+			continue
+		}
+
+		for _, account := range accounts {
+			client, err := newUserClient(db, account.InstagramUserID)
+			if err == nil {
+				err = client.CheckQuota()
+			}
+			if err != nil {
+				log.Printf("Skipping mention sync of account %d: %v", account.ID, err)
+				continue
+			}
+
+			accountID := account.ID
+			if _, err := syncMentions(db, client, &accountID); err != nil {
+				log.Printf("Failed to sync mentions of account %d: %v", account.ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// listPosts lists the posts
+func (s *server) listPosts(c *gin.Context) {
+	var posts []database.Post
+	var err error
+	if account := scopedAccount(c); account != nil {
+		posts, err = s.repos.Posts.GetAccountPosts(account.ID)
+	} else {
+		posts, err = s.repos.Posts.GetPosts()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   posts,
+	})
+}
+
+// newPostRequest holds the fields a client may set when creating a post; the
+// rest are filled in by publishing
+type newPostRequest struct {
+	AccountID     *int                  `json:"account_id"`
+	Caption       string                `json:"caption"`
+	MediaURL      string                `json:"media_url"`
+	Status        string                `json:"status"`
+	ScheduledAt   *time.Time            `json:"scheduled_at"`
+	MediaType     string                `json:"media_type"`
+	CoverURL      string                `json:"cover_url"`
+	ThumbOffsetMs int                   `json:"thumb_offset_ms"`
+	ShareToFeed   *bool                 `json:"share_to_feed"`
+	Assets        []newPostAssetRequest `json:"assets"`
+}
+
+// newPostAssetRequest is one slide of a carousel post being created
+type newPostAssetRequest struct {
+	MediaURL  string `json:"media_url"`
+	MediaType string `json:"media_type"`
+}
+
+// post returns the post the request creates
+func (r *newPostRequest) post() database.Post {
+	post := database.Post{
+		AccountID:     r.AccountID,
+		Caption:       r.Caption,
+		MediaURL:      r.MediaURL,
+		Status:        r.Status,
+		ScheduledAt:   r.ScheduledAt,
+		MediaType:     r.MediaType,
+		CoverURL:      r.CoverURL,
+		ThumbOffsetMs: r.ThumbOffsetMs,
+		ShareToFeed:   r.ShareToFeed,
+	}
+	for _, asset := range r.Assets {
+		post.Assets = append(post.Assets, database.PostAsset{MediaURL: asset.MediaURL, MediaType: asset.MediaType})
+	}
+	return post
+}
+
+// createPost creates a draft or scheduled post
+func (s *server) createPost(c *gin.Context) {
+	var req newPostRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	post := req.post()
+	if err := validateNewPost(&post); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Posts created outside an account scope belong to the default account
+	account, err := requestAccount(c, s.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if account != nil && (scopedAccount(c) != nil || post.AccountID == nil) {
+		post.AccountID = &account.ID
+	}
+
+	err = s.repos.Posts.SavePost(&post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   post,
+	})
+}
+
+// publishPostNow publishes a post right away. Reels are left processing and
+// answered with 202, but carousels are published within the request, so one
+// with video slides can hold it open for up to the client's ReelTimeout.
+func (s *server) publishPostNow(c *gin.Context) {
+	existing, ok := requestPost(c, s.repos.Posts)
+	if !ok {
+		return
+	}
+
+	client, err := clientForPost(s.db, existing)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	post, err := publishPost(s.repos.Posts, client, existing.ID)
+	if err != nil {
+		status := instagramErrorStatus(err)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			status = http.StatusNotFound
+		case errors.Is(err, errPostNotPublishable):
+			status = http.StatusConflict
+		case errors.Is(err, errPostHasNoMedia), errors.Is(err, errPostMediaUnsupported):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Reels keep processing after the upload is accepted
+	status := http.StatusOK
+	if post.Status == database.PostStatusProcessing {
+		status = http.StatusAccepted
+	}
+
+	c.JSON(status, gin.H{
+		"status": "success",
+		"data":   post,
+	})
+}
+
+// getPostAnalytics lists the analytics recorded for a post
+func (s *server) getPostAnalytics(c *gin.Context) {
+	postIDStr := c.Param("postId")
+	postID, err := strconv.Atoi(postIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid post ID",
+		})
+		return
+	}
+
+	analytics, err := s.repos.Analytics.GetAnalyticsForPost(postID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   analytics,
+	})
+}
+
+// getAccountAnalytics lists the analytics recorded for the account's posts
+func (s *server) getAccountAnalytics(c *gin.Context) {
+	analytics, err := s.repos.Analytics.GetAccountAnalytics(scopedAccount(c).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   analytics,
+	})
+}
+
+// requestPost loads the post named by the :id parameter, writing an error
+// response and returning false if it doesn't exist or belongs to another
+// account than the scoped one
+func requestPost(c *gin.Context, posts database.PostRepository) (*database.Post, bool) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid post ID",
+		})
+		return nil, false
+	}
+
+	post, err := posts.GetPost(postID)
+	if account := scopedAccount(c); err == nil && account != nil &&
+		(post.AccountID == nil || *post.AccountID != account.ID) {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Post not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+
+	return post, true
+}
+
+// validateNewPost checks a post created through the API, defaulting its status
+// to draft and its media type to what its assets imply. Clients may only create
+// drafts and scheduled posts; the other statuses are reached by publishing.
+func validateNewPost(post *database.Post) error {
+	switch post.Status {
+	case "":
+		post.Status = database.PostStatusDraft
+	case database.PostStatusDraft, database.PostStatusScheduled:
+	default:
+		return fmt.Errorf("status must be %s or %s", database.PostStatusDraft, database.PostStatusScheduled)
+	}
+	if post.Status == database.PostStatusScheduled && post.ScheduledAt == nil {
+		return errors.New("scheduled posts need a scheduled_at time")
+	}
+
+	if post.MediaType == "" {
+		post.MediaType = database.PostMediaImage
+		if len(post.Assets) > 0 {
+			post.MediaType = database.PostMediaCarousel
+		}
+	}
+	switch post.MediaType {
+	case database.PostMediaImage, database.PostMediaReels:
+		if len(post.Assets) > 0 {
+			return fmt.Errorf("%s posts can't have assets", post.MediaType)
+		}
+	case database.PostMediaCarousel:
+		if post.MediaURL != "" {
+			return fmt.Errorf("%s posts take their media from assets, not media_url", post.MediaType)
+		}
+	default:
+		return fmt.Errorf("media_type must be %s, %s or %s",
+			database.PostMediaImage, database.PostMediaCarousel, database.PostMediaReels)
+	}
+	if post.MediaType != database.PostMediaReels && (post.CoverURL != "" || post.ThumbOffsetMs != 0 || post.ShareToFeed != nil) {
+		return fmt.Errorf("cover_url, thumb_offset_ms and share_to_feed only apply to %s posts", database.PostMediaReels)
+	}
+
+	if n := len(post.Assets); post.MediaType == database.PostMediaCarousel && (n < 2 || n > 10) {
+		return errors.New("carousel posts need between 2 and 10 assets")
+	}
+	for i, asset := range post.Assets {
+		if strings.TrimSpace(asset.MediaURL) == "" {
+			return fmt.Errorf("asset %d needs a media_url", i+1)
+		}
+		switch asset.MediaType {
+		case "", instagram.MediaTypeImage, instagram.MediaTypeVideo:
+		default:
+			return fmt.Errorf("asset %d media_type must be %s or %s", i+1, instagram.MediaTypeImage, instagram.MediaTypeVideo)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+)
+
+func TestValidateNewPost(t *testing.T) {
+	now := time.Now()
+	assets := func(n int) []database.PostAsset {
+		var assets []database.PostAsset
+		for i := 0; i < n; i++ {
+			assets = append(assets, database.PostAsset{MediaURL: "https://example.com/a.jpg"})
+		}
+		return assets
+	}
+	yes := true
+
+	tests := []struct {
+		name      string
+		post      database.Post
+		wantErr   string
+		wantMedia string
+	}{
+		{name: "image", post: database.Post{MediaURL: "https://example.com/a.jpg"}, wantMedia: database.PostMediaImage},
+		{name: "carousel inferred", post: database.Post{Assets: assets(3)}, wantMedia: database.PostMediaCarousel},
+		{name: "reel", post: database.Post{MediaType: database.PostMediaReels, MediaURL: "https://example.com/a.mp4", ShareToFeed: &yes}, wantMedia: database.PostMediaReels},
+		{name: "scheduled", post: database.Post{Status: database.PostStatusScheduled, ScheduledAt: &now}, wantMedia: database.PostMediaImage},
+		{name: "scheduled without time", post: database.Post{Status: database.PostStatusScheduled}, wantErr: "scheduled_at"},
+		{name: "posted status", post: database.Post{Status: database.PostStatusPosted}, wantErr: "status must be"},
+		{name: "unknown media type", post: database.Post{MediaType: "VIDEO"}, wantErr: "media_type must be"},
+		{name: "reel with assets", post: database.Post{MediaType: database.PostMediaReels, Assets: assets(2)}, wantErr: "can't have assets"},
+		{name: "carousel without assets", post: database.Post{MediaType: database.PostMediaCarousel}, wantErr: "between 2 and 10"},
+		{name: "carousel with one asset", post: database.Post{Assets: assets(1)}, wantErr: "between 2 and 10"},
+		{name: "carousel with eleven assets", post: database.Post{Assets: assets(11)}, wantErr: "between 2 and 10"},
+		{name: "carousel with media url", post: database.Post{MediaURL: "https://example.com/a.jpg", Assets: assets(2)}, wantErr: "not media_url"},
+		{name: "image with reel options", post: database.Post{CoverURL: "https://example.com/c.jpg"}, wantErr: "only apply to REELS"},
+		{name: "asset without url", post: database.Post{Assets: []database.PostAsset{{MediaURL: "a"}, {}}}, wantErr: "asset 2 needs"},
+		{name: "asset media type", post: database.Post{Assets: []database.PostAsset{{MediaURL: "a"}, {MediaURL: "b", MediaType: "GIF"}}}, wantErr: "asset 2 media_type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNewPost(&tt.post)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.post.MediaType != tt.wantMedia {
+				t.Errorf("media type = %s, want %s", tt.post.MediaType, tt.wantMedia)
+			}
+		})
+	}
+}
+
+func TestCreatePostIgnoresPublishingFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &server{repos: database.NewMemoryRepositories()}
+	r := gin.New()
+	r.POST("/api/posts", s.createPost)
+
+	body := `{"caption":"hi","media_url":"https://example.com/a.jpg","instagram_id":"17900",
+		"permalink":"https://instagram.com/p/x","posted_at":"2026-01-01T00:00:00Z","container_id":"c1"}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/posts", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Data database.Post `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	post := resp.Data
+	if post.InstagramID != "" || post.Permalink != "" || post.PostedAt != nil || post.ContainerID != "" {
+		t.Errorf("created post = %+v, want the publishing fields left empty", post)
+	}
+	if post.Status != database.PostStatusDraft || post.Caption != "hi" {
+		t.Errorf("created post = %+v, want a draft with the caption", post)
+	}
+}
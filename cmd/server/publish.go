@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// errPostNotPublishable is returned when a post is already publishing or posted
+var errPostNotPublishable = errors.New("post is already being published or has been posted")
+
+// errPostHasNoMedia is returned when a post has nothing to upload
+var errPostHasNoMedia = errors.New("post has no media URL")
+
+// errPostMediaUnsupported is returned when a post has a media type we can't publish
+var errPostMediaUnsupported = errors.New("post has an unsupported media type")
+
+// errPublishInterrupted is recorded on posts whose publishing was cut short
+// before Instagram published them
+var errPublishInterrupted = errors.New("publishing was interrupted before the post went live; publish it again")
+
+// publishClaimTimeout is how long a post may stay publishing before the
+// process publishing it is assumed to have died. Publishing a carousel of
+// videos can take several minutes.
+const publishClaimTimeout = 30 * time.Minute
+
+// publishPost publishes a stored post to Instagram and records the outcome.
+// Reels are only uploaded here; they stay in the processing state until
+// the scheduler sees Instagram finish processing the video and publishes them.
+func publishPost(posts database.PostRepository, client *instagram.Client, postID int) (*database.Post, error) {
+	post, err := posts.GetPost(postID)
+	if err != nil {
+		return nil, err
+	}
+	if post.MediaURL == "" && len(post.Assets) == 0 {
+		return nil, errPostHasNoMedia
+	}
+	switch post.MediaType {
+	case database.PostMediaImage, database.PostMediaCarousel, database.PostMediaReels:
+	default:
+		return nil, fmt.Errorf("%w: %q", errPostMediaUnsupported, post.MediaType)
+	}
+
+	claimed, err := posts.ClaimPostForPublishing(postID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, errPostNotPublishable
+	}
+
+	var containerID string
+	switch post.MediaType {
+	case database.PostMediaReels:
+		containerID, err = client.CreateReelContainer(post.Caption, instagram.ReelOptions{
+			VideoURL:    post.MediaURL,
+			CoverURL:    post.CoverURL,
+			ThumbOffset: time.Duration(post.ThumbOffsetMs) * time.Millisecond,
+			ShareToFeed: post.ShareToFeed == nil || *post.ShareToFeed,
+		})
+		if err != nil {
+			recordPublishFailure(posts, postID, err)
+			return nil, err
+		}
+		if err := posts.MarkPostProcessing(postID, containerID); err != nil {
+			return nil, err
+		}
+		return posts.GetPost(postID)
+
+	case database.PostMediaCarousel:
+		items := make([]instagram.CarouselItem, 0, len(post.Assets))
+		for _, asset := range post.Assets {
+			if asset.MediaType == instagram.MediaTypeVideo {
+				items = append(items, instagram.CarouselItem{VideoURL: asset.MediaURL})
+			} else {
+				items = append(items, instagram.CarouselItem{ImageURL: asset.MediaURL})
+			}
+		}
+		containerID, err = client.CreateCarouselContainer(post.Caption, items)
+
+	default:
+		containerID, err = client.CreateImageContainer(post.Caption, post.MediaURL)
+	}
+	if err != nil {
+		recordPublishFailure(posts, postID, err)
+		return nil, err
+	}
+
+	// Record the container before publishing it, so that if we die before
+	// hearing back, recovery can ask Instagram whether it was published
+	if err := posts.SetPostContainer(postID, containerID); err != nil {
+		recordPublishFailure(posts, postID, err)
+		return nil, err
+	}
+
+	mediaID, err := client.PublishContainer(containerID)
+	if err != nil {
+		recordPublishFailure(posts, postID, err)
+		return nil, err
+	}
+
+	return finishPublishing(posts, client, postID, mediaID)
+}
+
+// finishPublishing records a post as live once Instagram has published it
+func finishPublishing(posts database.PostRepository, client *instagram.Client, postID int, mediaID string) (*database.Post, error) {
+	// The permalink is a nice-to-have; the post is live either way
+	var permalink string
+	if media, err := client.GetMedia(mediaID); err == nil {
+		permalink = media.Permalink
+	} else {
+		log.Printf("Failed to fetch permalink for media %s: %v", mediaID, err)
+	}
+
+	if err := posts.MarkPostPublished(postID, mediaID, permalink, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return posts.GetPost(postID)
+}
+
+// recordPublishFailure marks a post as failed, logging if even that fails
+func recordPublishFailure(posts database.PostRepository, postID int, cause error) {
+	if err := posts.MarkPostFailed(postID, cause.Error()); err != nil {
+		log.Printf("Failed to record publish failure for post %d: %v", postID, err)
+	}
+}
+
+// recoverStalePosts settles posts left publishing by a process that died after
+// claiming them. A post Instagram published in the meantime is marked posted;
+// any other is marked failed so that it can be published again.
+func recoverStalePosts(posts database.PostRepository, clients *postClients, stale []database.Post) {
+	for _, post := range stale {
+		client, err := clients.forPost(&post)
+		if err != nil {
+			log.Printf("Skipping stale publishing post %d: %v", post.ID, err)
+			continue
+		}
+
+		media, err := findPublishedMedia(client, &post)
+		if err != nil {
+			log.Printf("Failed to check whether stale post %d was published: %v", post.ID, err)
+			continue
+		}
+		if media == nil {
+			recordPublishFailure(posts, post.ID, errPublishInterrupted)
+			log.Printf("Publishing post %d was interrupted; marked it failed", post.ID)
+			continue
+		}
+
+		postedAt, err := media.PublishedAt()
+		if err != nil {
+			postedAt = time.Now()
+		}
+		if err := posts.MarkPostPublished(post.ID, media.ID, media.Permalink, postedAt); err != nil {
+			log.Printf("Failed to record published post %d: %v", post.ID, err)
+			continue
+		}
+		log.Printf("Post %d was published as media %s before publishing was interrupted", post.ID, media.ID)
+	}
+}
+
+// findPublishedMedia looks for the media a post's container was published as.
+// It returns nil if the post never got as far as a container or Instagram says
+// the container wasn't published. Instagram doesn't say which media a
+// container became, so that is found among the media published since the post
+// was claimed by its caption and type.
+func findPublishedMedia(client *instagram.Client, post *database.Post) (*instagram.Media, error) {
+	if post.ContainerID == "" {
+		return nil, nil
+	}
+
+	status, err := client.GetContainerStatus(post.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+	if status.StatusCode != instagram.ContainerStatusPublished {
+		return nil, nil
+	}
+
+	mediaType := instagram.MediaTypeImage
+	if post.MediaType == database.PostMediaCarousel {
+		mediaType = instagram.MediaTypeCarouselAlbum
+	}
+
+	query := instagram.MediaQuery{Limit: 100}
+	if post.ClaimedAt != nil {
+		// Allow for clock drift between us and Instagram
+		query.Since = post.ClaimedAt.Add(-5 * time.Minute)
+	}
+
+	it := client.IterateMedia(query)
+	for it.Next() {
+		if media := it.Media(); media.Caption == post.Caption && media.MediaType == mediaType {
+			return &media, nil
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("container %s was published, but its media wasn't found", post.ContainerID)
+}
+
+// checkProcessingPosts polls the containers of processing posts, publishing
+// the ones that finished and failing the ones that errored or timed out
+func checkProcessingPosts(posts database.PostRepository, clients *postClients, processing []database.Post) {
+	for _, post := range processing {
+		client, err := clients.forPost(&post)
+		if err != nil {
+			log.Printf("Skipping processing post %d: %v", post.ID, err)
+			continue
+		}
+
+		status, err := client.GetContainerStatus(post.ContainerID)
+		if err != nil {
+			log.Printf("Failed to check processing post %d: %v", post.ID, err)
+			continue
+		}
+
+		if err := posts.UpdatePostProcessingStatus(post.ID, status.StatusCode); err != nil {
+			log.Printf("Failed to update processing status of post %d: %v", post.ID, err)
+		}
+
+		switch status.StatusCode {
+		case instagram.ContainerStatusFinished:
+			mediaID, err := client.PublishContainer(post.ContainerID)
+			if err != nil {
+				// The container stays publishable, so leave the post processing
+				// and try again on the next tick
+				if instagram.IsRetryable(err) {
+					log.Printf("Publishing processed post %d will be retried: %v", post.ID, err)
+					continue
+				}
+				recordPublishFailure(posts, post.ID, err)
+				log.Printf("Failed to publish processed post %d: %v", post.ID, err)
+				continue
+			}
+			if _, err := finishPublishing(posts, client, post.ID, mediaID); err != nil {
+				log.Printf("Failed to record published post %d: %v", post.ID, err)
+				continue
+			}
+			log.Printf("Published processed post %d", post.ID)
+
+		case instagram.ContainerStatusError, instagram.ContainerStatusExpired:
+			err := &instagram.PublishError{ContainerID: post.ContainerID, StatusCode: status.StatusCode, Status: status.Status}
+			recordPublishFailure(posts, post.ID, err)
+			log.Printf("Processing failed for post %d: %v", post.ID, err)
+
+		default:
+			if post.ProcessingStartedAt != nil && time.Since(*post.ProcessingStartedAt) > client.ReelTimeout {
+				err := fmt.Errorf("timed out after %s waiting for media container %s (last status %s)",
+					client.ReelTimeout, post.ContainerID, status.StatusCode)
+				recordPublishFailure(posts, post.ID, err)
+				log.Printf("Processing timed out for post %d", post.ID)
+			}
+		}
+	}
+}
+
+// postClients hands out the Instagram client of each post's account during a
+// scheduler run, creating one client per account
+type postClients struct {
+	db      *database.DB
+	clients map[int]*instagram.Client
+}
+
+// forPost returns the client for a post. It fails while calls are being
+// deferred for the rate limit, which leaves due posts scheduled rather than
+// failing them against the limit.
+func (p *postClients) forPost(post *database.Post) (*instagram.Client, error) {
+	key := 0
+	if post.AccountID != nil {
+		key = *post.AccountID
+	}
+
+	client, ok := p.clients[key]
+	if !ok {
+		var err error
+		client, err = clientForPost(p.db, post)
+		if err != nil {
+			return nil, err
+		}
+		p.clients[key] = client
+	}
+
+	if err := client.CheckQuota(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// runPublishScheduler publishes due scheduled posts, finishes processing
+// Reels and recovers posts left publishing every interval
+func runPublishScheduler(db *database.DB, posts database.PostRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		due, err := posts.GetDuePosts(time.Now())
+		if err != nil {
+			log.Printf("Failed to load scheduled posts: %v", err)
+			continue
+		}
+
+		processing, err := posts.GetProcessingPosts()
+		if err != nil {
+			log.Printf("Failed to load processing posts: %v", err)
+			continue
+		}
+
+		stale, err := posts.GetStalePublishingPosts(time.Now().Add(-publishClaimTimeout))
+		if err != nil {
+			log.Printf("Failed to load stale publishing posts: %v", err)
+			continue
+		}
+
+		if len(due) == 0 && len(processing) == 0 && len(stale) == 0 {
+			continue
+		}
+
+		clients := &postClients{db: db, clients: make(map[int]*instagram.Client)}
+		recoverStalePosts(posts, clients, stale)
+		checkProcessingPosts(posts, clients, processing)
+
+		for _, post := range due {
+			client, err := clients.forPost(&post)
+			if err != nil {
+				log.Printf("Skipping scheduled post %d: %v", post.ID, err)
+				continue
+			}
+
+			published, err := publishPost(posts, client, post.ID)
+			if err != nil {
+				log.Printf("Failed to publish scheduled post %d: %v", post.ID, err)
+				continue
+			}
+			log.Printf("Scheduled post %d is now %s", post.ID, published.Status)
+		}
+	}
+}
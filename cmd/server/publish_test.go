@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+func TestFindPublishedMediaMatchesTheContainer(t *testing.T) {
+	instagram.ResetUsage()
+	t.Cleanup(instagram.ResetUsage)
+	server := instagramtest.NewServer()
+	t.Cleanup(server.Close)
+	client := server.Client()
+	posts := database.NewMemoryStore()
+
+	// Two posts with the same caption were being published when we died; only
+	// the first one's container got published
+	var published, unpublished, noContainer database.Post
+	for _, post := range []*database.Post{&published, &unpublished, &noContainer} {
+		post.Caption = "same caption"
+		post.MediaURL = "https://example.com/a.jpg"
+		post.Status = database.PostStatusDraft
+		if err := posts.SavePost(post); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := posts.ClaimPostForPublishing(post.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, post := range []*database.Post{&published, &unpublished} {
+		containerID, err := client.CreateImageContainer(post.Caption, post.MediaURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := posts.SetPostContainer(post.ID, containerID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored, _ := posts.GetPost(published.ID)
+	mediaID, err := client.PublishContainer(stored.ContainerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		post database.Post
+		want string
+	}{
+		{post: published, want: mediaID},
+		{post: unpublished},
+		{post: noContainer},
+	} {
+		stored, err := posts.GetPost(tt.post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		media, err := findPublishedMedia(client, stored)
+		if err != nil {
+			t.Fatalf("post %d: %v", stored.ID, err)
+		}
+		got := ""
+		if media != nil {
+			got = media.ID
+		}
+		if got != tt.want {
+			t.Errorf("post %d: found media %q, want %q", stored.ID, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/agents"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// listReplyDrafts lists the reply drafts
+func (s *server) listReplyDrafts(c *gin.Context) {
+	var accountID *int
+	if account := scopedAccount(c); account != nil {
+		accountID = &account.ID
+	}
+
+	drafts, err := s.db.GetReplyDrafts(c.Query("status"), accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   drafts,
+	})
+}
+
+// generateReplyDrafts drafts replies to comments that haven't been answered
+func (s *server) generateReplyDrafts(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(replyDraftBatchSize))
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid limit",
+		})
+		return
+	}
+
+	responder, err := agents.NewCommentResponder()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	drafts, err := draftReplies(s.db, responder, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   drafts,
+	})
+}
+
+// editReplyDraft changes the text of a reply draft, which is held to the
+// same length limit as drafted replies
+func (s *server) editReplyDraft(c *gin.Context) {
+	var request struct {
+		Reply string `json:"reply" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	request.Reply = strings.TrimSpace(request.Reply)
+	if request.Reply == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Reply must not be blank",
+		})
+		return
+	}
+	if length := len([]rune(request.Reply)); length > agents.MaxReplyLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Reply is %d characters, the limit is %d", length, agents.MaxReplyLength),
+		})
+		return
+	}
+
+	draft, ok := requestReplyDraft(c, s.db)
+	if !ok {
+		return
+	}
+
+	updated, err := s.db.UpdateReplyDraftText(draft.ID, request.Reply)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Draft is %s and can no longer be edited", draft.Status),
+		})
+		return
+	}
+
+	draft.Reply = request.Reply
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   draft,
+	})
+}
+
+// approveReplyDraft posts a reply draft
+func (s *server) approveReplyDraft(c *gin.Context) {
+	draft, ok := requestReplyDraft(c, s.db)
+	if !ok {
+		return
+	}
+
+	posted, err := postReplyDraft(s.db, draft)
+	if errors.Is(err, errReplyDraftDecided) || errors.Is(err, errCommentAlreadyReplied) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   posted,
+	})
+}
+
+// rejectReplyDraft rejects a reply draft
+func (s *server) rejectReplyDraft(c *gin.Context) {
+	draft, ok := requestReplyDraft(c, s.db)
+	if !ok {
+		return
+	}
+
+	rejected, err := s.db.RejectReplyDraft(draft.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !rejected {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Draft is %s and can no longer be rejected", draft.Status),
+		})
+		return
+	}
+
+	draft.Status = database.ReplyDraftRejected
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   draft,
+	})
+}
+
+// requestReplyDraft loads the reply draft named by the :draftId parameter,
+// writing an error response and returning false if it doesn't exist or
+// belongs to another account than the scoped one
+func requestReplyDraft(c *gin.Context, db *database.DB) (*database.ReplyDraft, bool) {
+	draftID, err := strconv.Atoi(c.Param("draftId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid draft ID",
+		})
+		return nil, false
+	}
+
+	draft, err := db.GetReplyDraft(draftID)
+	if account := scopedAccount(c); err == nil && account != nil &&
+		(draft.AccountID == nil || *draft.AccountID != account.ID) {
+		err = sql.ErrNoRows
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Reply draft not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+
+	return draft, true
+}
+
+// replyDraftBatchSize is how many comments are drafted for per run
+const replyDraftBatchSize = 20
+
+// errReplyDraftDecided is returned when approving a draft that was already
+// approved or rejected
+var errReplyDraftDecided = errors.New("reply draft has already been decided on")
+
+// errCommentAlreadyReplied is returned when approving a draft for a comment
+// that one of our accounts has replied to in the meantime
+var errCommentAlreadyReplied = errors.New("comment has already been replied to")
+
+// draftReplies drafts replies to up to limit comments that have none yet.
+// Spam and comments that need no reply are stored as skipped so they aren't
+// drafted for again.
+func draftReplies(db *database.DB, responder *agents.CommentResponder, limit int) ([]database.ReplyDraft, error) {
+	comments, err := db.GetCommentsAwaitingDraft(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	styles := make(map[int]agents.ReplyStyle)
+	drafts := []database.ReplyDraft{}
+	for _, comment := range comments {
+		style, err := replyStyle(db, comment.AccountID, styles)
+		if err != nil {
+			return drafts, err
+		}
+
+		verdict, err := responder.DraftReply(agents.CommentContext{
+			Username:    comment.Username,
+			Text:        comment.Text,
+			PostCaption: comment.PostCaption,
+		}, style)
+		if err != nil {
+			// Left undrafted, so the next run tries again
+			log.Printf("Failed to draft reply to comment %s: %v", comment.InstagramID, err)
+			continue
+		}
+
+		draft := database.ReplyDraft{
+			AccountID:       comment.AccountID,
+			CommentID:       comment.InstagramID,
+			MediaID:         comment.MediaID,
+			Reply:           verdict.Reply,
+			Status:          database.ReplyDraftPending,
+			Reason:          verdict.Reason,
+			CommentUsername: comment.Username,
+			CommentText:     comment.Text,
+		}
+		if verdict.Decision != agents.ReplyDecisionReply {
+			draft.Status = database.ReplyDraftSkipped
+			draft.Reason = fmt.Sprintf("%s: %s", verdict.Decision, verdict.Reason)
+		}
+
+		created, err := db.SaveReplyDraft(&draft)
+		if err != nil {
+			return drafts, err
+		}
+		if created {
+			drafts = append(drafts, draft)
+		}
+	}
+
+	return drafts, nil
+}
+
+// replyStyle returns the reply style of an account, or of the default account
+// if accountID is nil, caching it in styles
+func replyStyle(db *database.DB, accountID *int, styles map[int]agents.ReplyStyle) (agents.ReplyStyle, error) {
+	key := 0
+	if accountID != nil {
+		key = *accountID
+	}
+	if style, ok := styles[key]; ok {
+		return style, nil
+	}
+
+	var account *database.Account
+	var err error
+	if accountID != nil {
+		account, err = db.GetAccount(*accountID)
+	} else {
+		account, err = db.GetAccountByInstagramID(os.Getenv("INSTAGRAM_USER_ID"))
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return agents.ReplyStyle{}, err
+	}
+
+	style := agents.ReplyStyle{SarcasmLevel: 5}
+	if account != nil {
+		style = agents.ReplyStyle{Voice: account.Voice, SarcasmLevel: account.SarcasmLevel}
+	}
+	styles[key] = style
+	return style, nil
+}
+
+// postReplyDraft posts the reply of an approved draft with the client of the
+// account it belongs to. Claiming the draft first guarantees that a reply is
+// posted at most once, even if it is approved twice at the same time. A draft
+// whose last attempt ended in an unknown state is only posted again if the
+// comment's replies show it didn't go through.
+func postReplyDraft(db *database.DB, draft *database.ReplyDraft) (*database.ReplyDraft, error) {
+	wasUnknown := draft.Status == database.ReplyDraftUnknown
+
+	// Our reply from the unknown attempt may have been synced since, so unknown
+	// drafts are checked against Instagram instead
+	if !wasUnknown {
+		replied, err := db.HasAccountReply(draft.CommentID)
+		if err != nil {
+			return nil, err
+		}
+		if replied {
+			return nil, errCommentAlreadyReplied
+		}
+	}
+
+	claimed, err := db.ClaimReplyDraft(draft.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, errReplyDraftDecided
+	}
+
+	client, err := clientForAccount(db, draft.AccountID)
+	if err != nil {
+		recordReplyDraftFailure(db, draft.ID, err, wasUnknown)
+		return nil, err
+	}
+
+	if wasUnknown {
+		posted, err := findPostedReply(client, draft)
+		if err != nil {
+			recordReplyDraftFailure(db, draft.ID, err, true)
+			return nil, err
+		}
+		if posted != nil {
+			log.Printf("Reply to comment %s from draft %d was posted after all", draft.CommentID, draft.ID)
+			return finishReplyDraft(db, client, draft, posted.ID)
+		}
+	}
+
+	replyID, err := client.ReplyToComment(draft.CommentID, draft.Reply)
+	if err != nil {
+		recordReplyDraftFailure(db, draft.ID, err, replyOutcomeUnknown(err))
+		return nil, err
+	}
+
+	return finishReplyDraft(db, client, draft, replyID)
+}
+
+// finishReplyDraft records the posted reply of a draft and returns the draft
+func finishReplyDraft(db *database.DB, client *instagram.Client, draft *database.ReplyDraft, replyID string) (*database.ReplyDraft, error) {
+	if err := db.MarkReplyDraftPosted(draft.ID, replyID); err != nil {
+		log.Printf("Reply %s was posted but draft %d could not be updated: %v", replyID, draft.ID, err)
+	}
+
+	now := time.Now()
+	reply := database.Comment{
+		AccountID:   draft.AccountID,
+		InstagramID: replyID,
+		MediaID:     draft.MediaID,
+		ParentID:    draft.CommentID,
+		UserID:      client.UserID,
+		Text:        draft.Reply,
+		CommentedAt: &now,
+	}
+	if err := db.SaveFetchedComment(&reply); err != nil {
+		log.Printf("Failed to save reply %s to comment %s: %v", replyID, draft.CommentID, err)
+	}
+
+	return db.GetReplyDraft(draft.ID)
+}
+
+// findPostedReply looks through a comment's replies for the reply of a draft
+// posted by the client's account. It returns nil if there is none.
+func findPostedReply(client *instagram.Client, draft *database.ReplyDraft) (*instagram.Comment, error) {
+	replies, err := client.ListReplies(draft.CommentID, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := range replies {
+		if replies[i].From.ID == client.UserID && strings.TrimSpace(replies[i].Text) == strings.TrimSpace(draft.Reply) {
+			return &replies[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// replyOutcomeUnknown reports whether a failed reply may still have been
+// posted: the request got no answer, or one that doesn't say it was refused
+func replyOutcomeUnknown(err error) bool {
+	switch instagram.ErrorKindOf(err) {
+	case instagram.ErrorKindNetwork, instagram.ErrorKindTransient, instagram.ErrorKindUnknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordReplyDraftFailure marks a draft failed, or unknown if the reply may
+// have been posted, logging if that fails too
+func recordReplyDraftFailure(db *database.DB, draftID int, cause error, unknown bool) {
+	mark := db.MarkReplyDraftFailed
+	if unknown {
+		mark = db.MarkReplyDraftUnknown
+	}
+	if err := mark(draftID, cause.Error()); err != nil {
+		log.Printf("Failed to record failure of reply draft %d: %v", draftID, err)
+	}
+}
+
+// runReplyDrafter drafts replies to new comments every interval
+func runReplyDrafter(db *database.DB, responder *agents.CommentResponder, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		drafts, err := draftReplies(db, responder, replyDraftBatchSize)
+		if err != nil {
+			log.Printf("Failed to draft comment replies: %v", err)
+		}
+		if len(drafts) > 0 {
+			log.Printf("Drafted %d comment replies", len(drafts))
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/agents"
+)
+
+func TestEditReplyDraftEnforcesReplyLength(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The text is checked before the draft is loaded, so no database is needed
+	r := gin.New()
+	r.PUT("/api/reply-drafts/:draftId", (&server{}).editReplyDraft)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "too long", body: `{"reply": "` + strings.Repeat("é", agents.MaxReplyLength+1) + `"}`},
+		{name: "blank", body: `{"reply": "   "}`},
+		{name: "missing", body: `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/api/reply-drafts/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body)
+			}
+		})
+	}
+}
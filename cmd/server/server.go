@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+)
+
+// server holds what the HTTP handlers share. db is nil when the server runs
+// with STORAGE=memory.
+type server struct {
+	db        *database.DB
+	repos     database.Repositories
+	backfills *backfillJobs
+}
+
+// router sets up the web UI, webhook and API routes. The routes that need the
+// database are registered on their own groups, which answer 503 Service
+// Unavailable when the server runs on in-memory storage.
+func (s *server) router() *gin.Engine {
+	r := gin.Default()
+
+	// Configure CORS
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization"},
+		ExposeHeaders:    []string{"Content-Length"},
+		AllowCredentials: true,
+	}))
+
+	// Serve static files
+	r.Static("/static", "./web/static")
+	r.LoadHTMLGlob("web/templates/*")
+
+	// Web UI routes
+	r.GET("/", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "index.html", gin.H{
+			"title": "Instagram AI Agents",
+		})
+	})
+
+	r.GET("/dashboard", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "dashboard.html", gin.H{
+			"title": "Dashboard | Instagram AI Agents",
+		})
+	})
+
+	// API routes that work on in-memory storage
+	api := r.Group("/api")
+	{
+		// Tech Trend Analyzer routes
+		api.GET("/tech-trends", s.getTechTrends)
+		api.GET("/content-ideas", s.generateContentIdeas)
+		api.GET("/trends", s.getTrends)
+
+		// Sarcasm Enhancer routes
+		api.POST("/enhance-content", s.enhanceContent)
+
+		// Behind the Scenes Speculator routes
+		api.GET("/companies", s.listCompanies)
+		api.GET("/topics/:company", s.listTopics)
+		api.POST("/speculate", s.speculate)
+		api.GET("/speculations", s.listSpeculations)
+
+		// Instagram routes
+		api.GET("/instagram/media", s.listMedia)
+		api.POST("/instagram/backfill", s.backfillMedia)
+		api.GET("/instagram/backfill/:jobId", s.getBackfill)
+		api.GET("/instagram/usage", s.getUsage)
+		api.GET("/instagram/insights/:mediaId", s.getMediaInsights)
+		api.GET("/instagram/account-insights", s.getAccountInsights)
+
+		// Stored content routes
+		api.GET("/content-ideas/db", s.listContentIdeas)
+		api.POST("/content-ideas/db", s.saveContentIdea)
+		api.POST("/content-ideas/db/batch", s.saveContentIdeas)
+		api.GET("/posts", s.listPosts)
+		api.POST("/posts", s.createPost)
+		api.POST("/posts/:id/publish", s.publishPostNow)
+
+		// Analytics routes
+		api.GET("/analytics/:postId", s.getPostAnalytics)
+	}
+
+	withDB := r.Group("", requireDatabase(s.db))
+
+	// Instagram webhook routes. GET answers the subscription handshake and POST
+	// receives signed event deliveries.
+	withDB.GET("/webhooks/instagram", s.verifyWebhook)
+	withDB.POST("/webhooks/instagram", s.receiveWebhook)
+
+	// API routes that need the database
+	dbAPI := withDB.Group("/api")
+	{
+		// Instagram routes
+		dbAPI.GET("/instagram/token", s.getToken)
+		dbAPI.POST("/instagram/token", s.exchangeToken)
+		dbAPI.POST("/instagram/token/refresh", s.refreshToken)
+		dbAPI.POST("/instagram/account-insights/snapshot", s.snapshotAccountInsights)
+		dbAPI.GET("/instagram/account-insights/history", s.getInsightsHistory)
+		dbAPI.GET("/instagram/follower-growth", s.getFollowerGrowth)
+
+		// Hashtag routes. Instagram limits each account to 30 unique hashtags a
+		// week, so every lookup goes through the local ledger.
+		dbAPI.GET("/hashtags", s.listHashtags)
+		dbAPI.GET("/hashtags/quota", s.getHashtagQuota)
+		dbAPI.GET("/hashtags/search", s.searchHashtag)
+		dbAPI.GET("/hashtags/:name/media", s.getHashtagMedia)
+		dbAPI.POST("/hashtags/:name/track", s.trackHashtag)
+		dbAPI.DELETE("/hashtags/:name/track", s.untrackHashtag)
+		dbAPI.POST("/hashtags/snapshot", s.snapshotHashtags)
+
+		// Mention routes. Mentions arrive through webhooks and tags are synced
+		// from Instagram; both stay flagged until we respond or dismiss them.
+		dbAPI.GET("/mentions", s.listMentions)
+		dbAPI.POST("/mentions/sync", s.syncAccountMentions)
+		dbAPI.POST("/mentions/:mentionId/reply", s.replyToMention)
+		dbAPI.POST("/mentions/:mentionId/dismiss", s.setMentionNeedsResponse(false))
+		dbAPI.POST("/mentions/:mentionId/reopen", s.setMentionNeedsResponse(true))
+
+		// Competitor routes. Competitors are looked up through business discovery
+		// with the default account's client.
+		dbAPI.GET("/competitors", s.listCompetitors)
+		dbAPI.POST("/competitors", s.addCompetitor)
+		dbAPI.DELETE("/competitors/:id", s.removeCompetitor)
+		dbAPI.GET("/competitors/:id/snapshots", s.getCompetitorSnapshots)
+		dbAPI.POST("/competitors/snapshot", s.snapshotCompetitors)
+		dbAPI.GET("/competitors/compare", s.compareCompetitors)
+
+		// Stored content routes
+		dbAPI.GET("/content-ideas/db/:id/sources", s.getContentIdeaSources)
+		dbAPI.GET("/news/history", s.getNewsHistory)
+		dbAPI.GET("/posts/:id/comments", s.listComments)
+		dbAPI.POST("/posts/:id/comments/:commentId/replies", s.replyToComment)
+		dbAPI.POST("/posts/:id/comments/:commentId/hide", s.setCommentHidden(true))
+		dbAPI.POST("/posts/:id/comments/:commentId/unhide", s.setCommentHidden(false))
+		dbAPI.DELETE("/posts/:id/comments/:commentId", s.deleteComment)
+
+		// Reply draft routes. Drafts are written by the comment responder and only
+		// posted once approved.
+		dbAPI.GET("/reply-drafts", s.listReplyDrafts)
+		dbAPI.POST("/reply-drafts/generate", s.generateReplyDrafts)
+		dbAPI.PUT("/reply-drafts/:draftId", s.editReplyDraft)
+		dbAPI.POST("/reply-drafts/:draftId/approve", s.approveReplyDraft)
+		dbAPI.POST("/reply-drafts/:draftId/reject", s.rejectReplyDraft)
+
+		// Account routes. Each account has its own token; the routes under
+		// /accounts/:accountId act on that account instead of the default one.
+		dbAPI.GET("/accounts", s.listAccounts)
+		dbAPI.POST("/accounts", s.createAccount)
+
+		accountAPI := dbAPI.Group("/accounts/:accountId", loadAccount(s.db))
+		{
+			accountAPI.GET("", s.getAccount)
+			accountAPI.GET("/posts", s.listPosts)
+			accountAPI.POST("/posts", s.createPost)
+			accountAPI.POST("/posts/:id/publish", s.publishPostNow)
+			accountAPI.GET("/posts/:id/comments", s.listComments)
+			accountAPI.POST("/posts/:id/comments/:commentId/replies", s.replyToComment)
+			accountAPI.POST("/posts/:id/comments/:commentId/hide", s.setCommentHidden(true))
+			accountAPI.POST("/posts/:id/comments/:commentId/unhide", s.setCommentHidden(false))
+			accountAPI.DELETE("/posts/:id/comments/:commentId", s.deleteComment)
+			accountAPI.GET("/reply-drafts", s.listReplyDrafts)
+			accountAPI.PUT("/reply-drafts/:draftId", s.editReplyDraft)
+			accountAPI.POST("/reply-drafts/:draftId/approve", s.approveReplyDraft)
+			accountAPI.POST("/reply-drafts/:draftId/reject", s.rejectReplyDraft)
+			accountAPI.GET("/media", s.listMedia)
+			accountAPI.POST("/backfill", s.backfillMedia)
+			accountAPI.GET("/backfill/:jobId", s.getBackfill)
+			accountAPI.GET("/insights/:mediaId", s.getMediaInsights)
+			accountAPI.GET("/account-insights", s.getAccountInsights)
+			accountAPI.POST("/account-insights/snapshot", s.snapshotAccountInsights)
+			accountAPI.GET("/account-insights/history", s.getInsightsHistory)
+			accountAPI.GET("/follower-growth", s.getFollowerGrowth)
+			accountAPI.GET("/hashtags/quota", s.getHashtagQuota)
+			accountAPI.GET("/competitors/compare", s.compareCompetitors)
+			accountAPI.GET("/mentions", s.listMentions)
+			accountAPI.POST("/mentions/sync", s.syncAccountMentions)
+			accountAPI.POST("/mentions/:mentionId/reply", s.replyToMention)
+			accountAPI.POST("/mentions/:mentionId/dismiss", s.setMentionNeedsResponse(false))
+			accountAPI.POST("/mentions/:mentionId/reopen", s.setMentionNeedsResponse(true))
+			accountAPI.GET("/hashtags/search", s.searchHashtag)
+			accountAPI.GET("/hashtags/:name/media", s.getHashtagMedia)
+			accountAPI.GET("/usage", s.getAccountUsage)
+			accountAPI.GET("/token", s.getToken)
+			accountAPI.POST("/token", s.exchangeToken)
+			accountAPI.POST("/token/refresh", s.refreshToken)
+			accountAPI.GET("/analytics", s.getAccountAnalytics)
+		}
+	}
+
+	return r
+}
+
+// requireDatabase answers with 503 Service Unavailable when the server runs on
+// in-memory storage
+func requireDatabase(db *database.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if db != nil {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "This route needs a database, but the server is running with STORAGE=memory",
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// getToken reports the type and expiry of the stored access token, but not the
+// token itself
+func (s *server) getToken(c *gin.Context) {
+	token, err := s.db.GetAccessToken(requestInstagramUserID(c))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No access token stored for this account",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"data":    token,
+		"warning": tokenWarning(token),
+	})
+}
+
+// exchangeToken exchanges a short-lived token for a long-lived one and stores it
+func (s *server) exchangeToken(c *gin.Context) {
+	var request struct {
+		AccessToken string `json:"access_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	userID := requestInstagramUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "INSTAGRAM_USER_ID environment variable not set",
+		})
+		return
+	}
+
+	appSecret := os.Getenv("INSTAGRAM_APP_SECRET")
+	if appSecret == "" {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "INSTAGRAM_APP_SECRET environment variable not set",
+		})
+		return
+	}
+
+	exchanged, err := exchangeAccessToken(request.AccessToken, appSecret)
+	if err != nil {
+		respondInstagramError(c, err)
+		return
+	}
+
+	token := database.AccessToken{
+		UserID:      userID,
+		AccessToken: exchanged.AccessToken,
+		TokenType:   exchanged.TokenType,
+		ExpiresAt:   &exchanged.ExpiresAt,
+	}
+	if err := s.db.SaveAccessToken(&token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   token,
+	})
+}
+
+// refreshToken refreshes the stored access token
+func (s *server) refreshToken(c *gin.Context) {
+	token, err := s.db.GetAccessToken(requestInstagramUserID(c))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No access token stored for this account",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	refreshed, err := refreshAccessToken(s.db, token)
+	if err != nil {
+		c.JSON(instagramErrorStatus(err), gin.H{
+			"error":   err.Error(),
+			"kind":    instagram.ErrorKindOf(err),
+			"warning": tokenWarning(token),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   refreshed,
+	})
+}
+
+// tokenRefreshWindow is how long before expiry access tokens are refreshed
+const tokenRefreshWindow = 7 * 24 * time.Hour
+
+// exchangeAccessToken exchanges a short-lived token for a long-lived one with
+// the HTTP client configured for Graph API requests
+func exchangeAccessToken(shortLivedToken, appSecret string) (*instagram.Token, error) {
+	httpClient, err := instagram.NewHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return instagram.ExchangeToken(httpClient, "", shortLivedToken, appSecret)
+}
+
+// seedAccessToken stores INSTAGRAM_ACCESS_TOKEN if no token is stored yet. Its
+// expiry is unknown and Instagram Login has no endpoint to look it up, so the
+// refresher refreshes it once it has been stored for MinTokenAgeForRefresh.
+func seedAccessToken(db *database.DB) {
+	userID := os.Getenv("INSTAGRAM_USER_ID")
+	accessToken := os.Getenv("INSTAGRAM_ACCESS_TOKEN")
+	if userID == "" || accessToken == "" {
+		return
+	}
+
+	if _, err := db.GetAccessToken(userID); !errors.Is(err, sql.ErrNoRows) {
+		if err != nil {
+			log.Printf("Failed to load stored access token: %v", err)
+		}
+		return
+	}
+
+	if err := db.SaveAccessToken(&database.AccessToken{UserID: userID, AccessToken: accessToken}); err != nil {
+		log.Printf("Failed to store INSTAGRAM_ACCESS_TOKEN: %v", err)
+		return
+	}
+	log.Printf("Stored INSTAGRAM_ACCESS_TOKEN for user %s", userID)
+}
+
+// refreshAccessToken refreshes a stored token and stores the new one, recording
+// the error on the stored token if the refresh fails
+func refreshAccessToken(db *database.DB, token *database.AccessToken) (*database.AccessToken, error) {
+	client, err := instagram.NewClientFor(token.UserID, token.AccessToken)
+	if err == nil {
+		var refreshed *instagram.Token
+		refreshed, err = client.RefreshToken()
+		if err == nil {
+			updated := database.AccessToken{
+				UserID:      token.UserID,
+				AccessToken: refreshed.AccessToken,
+				TokenType:   refreshed.TokenType,
+				ExpiresAt:   &refreshed.ExpiresAt,
+			}
+			if err := db.SaveAccessToken(&updated); err != nil {
+				return nil, err
+			}
+			return &updated, nil
+		}
+	}
+
+	if recordErr := db.RecordTokenRefreshFailure(token.UserID, err.Error()); recordErr != nil {
+		log.Printf("Failed to record token refresh failure for user %s: %v", token.UserID, recordErr)
+	}
+	token.LastRefreshError = err.Error()
+	return nil, err
+}
+
+// runTokenRefresher refreshes access tokens nearing expiry every interval
+func runTokenRefresher(db *database.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		tokens, err := db.GetTokensDueForRefresh(now.Add(tokenRefreshWindow), now.Add(-instagram.MinTokenAgeForRefresh))
+		if err != nil {
+			log.Printf("Failed to load access tokens due for refresh: %v", err)
+		}
+
+		for i := range tokens {
+			refreshed, err := refreshAccessToken(db, &tokens[i])
+			if err != nil {
+				log.Printf("Failed to refresh access token for user %s: %v", tokens[i].UserID, err)
+				continue
+			}
+			log.Printf("Refreshed access token for user %s, now expires at %s",
+				refreshed.UserID, refreshed.ExpiresAt.Format(time.RFC3339))
+		}
+
+		<-ticker.C
+	}
+}
+
+// tokenWarning describes a problem with a stored token that needs attention,
+// or returns an empty string if there is none
+func tokenWarning(token *database.AccessToken) string {
+	now := time.Now()
+	switch {
+	case token.ExpiresAt != nil && token.ExpiresAt.Before(now):
+		return fmt.Sprintf("access token expired at %s; exchange a new one", token.ExpiresAt.Format(time.RFC3339))
+	case token.LastRefreshError != "":
+		return fmt.Sprintf("last token refresh failed: %s", token.LastRefreshError)
+	case token.ExpiresAt != nil && token.ExpiresAt.Before(now.Add(tokenRefreshWindow)):
+		return fmt.Sprintf("access token expires at %s and has not been refreshed yet", token.ExpiresAt.Format(time.RFC3339))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/igo-used/instagram-ai-agents/internal/database"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// verifyWebhook answers Instagram's webhook subscription handshake
+func (s *server) verifyWebhook(c *gin.Context) {
+	verifyToken := os.Getenv("INSTAGRAM_WEBHOOK_VERIFY_TOKEN")
+	if verifyToken == "" || c.Query("hub.mode") != "subscribe" ||
+		subtle.ConstantTimeCompare([]byte(c.Query("hub.verify_token")), []byte(verifyToken)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Webhook verification failed",
+		})
+		return
+	}
+
+	c.String(http.StatusOK, c.Query("hub.challenge"))
+}
+
+// receiveWebhook receives signed event deliveries. Failing makes Instagram
+// deliver the payload again, so saving must be idempotent.
+func (s *server) receiveWebhook(c *gin.Context) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err = instagram.VerifySignature(body, c.GetHeader("X-Hub-Signature-256"), os.Getenv("INSTAGRAM_APP_SECRET"))
+	if errors.Is(err, instagram.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	payload, err := instagram.ParseWebhook(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Failing makes Instagram deliver the payload again; saving is idempotent
+	if err := handleWebhook(s.db, payload); err != nil {
+		log.Printf("Failed to handle webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// maxWebhookBodySize caps the size of webhook deliveries
+const maxWebhookBodySize = 1 << 20
+
+// handleWebhook dispatches the changes of a webhook delivery to their handlers.
+// Fields without a handler are logged and skipped.
+func handleWebhook(db *database.DB, payload *instagram.WebhookPayload) error {
+	for _, entry := range payload.Entry {
+		var accountID *int
+		account, err := db.GetAccountByInstagramID(entry.ID)
+		switch {
+		case err == nil:
+			accountID = &account.ID
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+
+		for _, change := range entry.Changes {
+			var err error
+			switch change.Field {
+			case instagram.WebhookFieldComments, instagram.WebhookFieldLiveComments:
+				err = handleCommentEvent(db, accountID, change)
+			case instagram.WebhookFieldMentions:
+				err = handleMentionEvent(db, accountID, change)
+			case instagram.WebhookFieldStoryInsights:
+				err = handleStoryInsightsEvent(db, accountID, change)
+			default:
+				log.Printf("Ignoring webhook field %q for user %s", change.Field, entry.ID)
+			}
+			if err != nil {
+				return fmt.Errorf("%s event for user %s: %w", change.Field, entry.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleCommentEvent stores a new or edited comment
+func handleCommentEvent(db *database.DB, accountID *int, change instagram.WebhookChange) error {
+	event, err := change.Comment()
+	if err != nil {
+		return err
+	}
+
+	return db.SaveComment(&database.Comment{
+		AccountID:   accountID,
+		InstagramID: event.ID,
+		MediaID:     event.Media.ID,
+		ParentID:    event.ParentID,
+		UserID:      event.From.ID,
+		Username:    event.From.Username,
+		Text:        event.Text,
+		Live:        change.Field == instagram.WebhookFieldLiveComments,
+	})
+}
+
+// handleMentionEvent stores a mention of an account
+func handleMentionEvent(db *database.DB, accountID *int, change instagram.WebhookChange) error {
+	event, err := change.Mention()
+	if err != nil {
+		return err
+	}
+
+	return db.SaveMention(&database.Mention{
+		AccountID: accountID,
+		MediaID:   event.MediaID,
+		CommentID: event.CommentID,
+	})
+}
+
+// handleStoryInsightsEvent stores the final insights of an expired story
+func handleStoryInsightsEvent(db *database.DB, accountID *int, change instagram.WebhookChange) error {
+	event, err := change.StoryInsights()
+	if err != nil {
+		return err
+	}
+
+	return db.SaveStoryInsights(&database.StoryInsights{
+		AccountID:   accountID,
+		MediaID:     event.MediaID,
+		Impressions: event.Impressions,
+		Reach:       event.Reach,
+		Replies:     event.Replies,
+		Exits:       event.Exits,
+		TapsForward: event.TapsForward,
+		TapsBack:    event.TapsBack,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReceiveWebhookRejectsUnsignedDeliveries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("INSTAGRAM_APP_SECRET", "app-secret")
+
+	// The signature is checked before anything touches the database
+	r := gin.New()
+	r.POST("/webhooks/instagram", (&server{}).receiveWebhook)
+
+	for name, signature := range map[string]string{
+		"unsigned":     "",
+		"wrong secret": "sha256=0123456789abcdef",
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/instagram",
+				strings.NewReader(`{"object":"instagram","entry":[]}`))
+			if signature != "" {
+				req.Header.Set("X-Hub-Signature-256", signature)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("INSTAGRAM_WEBHOOK_VERIFY_TOKEN", "verify-me")
+
+	r := gin.New()
+	r.GET("/webhooks/instagram", (&server{}).verifyWebhook)
+
+	tests := []struct {
+		token string
+		want  int
+	}{
+		{token: "verify-me", want: http.StatusOK},
+		{token: "verify-you", want: http.StatusForbidden},
+		{token: "", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet,
+			"/webhooks/instagram?hub.mode=subscribe&hub.challenge=42&hub.verify_token="+tt.token, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("token %q: status = %d, want %d", tt.token, w.Code, tt.want)
+		}
+		if tt.want == http.StatusOK && w.Body.String() != "42" {
+			t.Errorf("body = %q, want the challenge", w.Body.String())
+		}
+	}
+}
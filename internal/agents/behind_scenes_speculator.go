@@ -2,13 +2,12 @@ package agents
 
 import (
 	"fmt"
-	"os"
 	"strings"
 )
 
 // BehindScenesSpeculator generates speculative "insider" content about tech companies
 type BehindScenesSpeculator struct {
-	OpenAIKey string
+	LLM       LLMProvider
 	Companies []string
 }
 
@@ -23,9 +22,9 @@ type SpeculationResult struct {
 
 // NewBehindScenesSpeculator creates a new behind scenes speculator
 func NewBehindScenesSpeculator() (*BehindScenesSpeculator, error) {
-	openAIKey := os.Getenv("OPENAI_API_KEY")
-	if openAIKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	llm, err := NewOpenAIProvider()
+	if err != nil {
+		return nil, err
 	}
 
 	// Default list of tech companies to speculate about
@@ -35,7 +34,7 @@ func NewBehindScenesSpeculator() (*BehindScenesSpeculator, error) {
 	}
 
 	return &BehindScenesSpeculator{
-		OpenAIKey: openAIKey,
+		LLM:       llm,
 		Companies: companies,
 	}, nil
 }
@@ -55,44 +54,35 @@ func (b *BehindScenesSpeculator) GenerateSpeculation(company string, topic strin
 		return nil, fmt.Errorf("invalid company: %s", company)
 	}
 
-	// In a real implementation, you would call the OpenAI API to generate the speculation
-	// For now, we'll return mock data
-
 	// Generate a headline based on the company and topic
 	headline := fmt.Sprintf("What's Really Happening Inside %s's %s Division", company, topic)
 
-	// Generate the speculation
-	speculation := fmt.Sprintf(`
-# Behind the Scenes at %s: %s
-
-According to sources familiar with the matter, %s has been working on a secret project in their %s division. 
-While the company has publicly stated that they're focused on incremental improvements, our insider sources 
-suggest that there's much more happening behind closed doors.
-
-## What We've Heard
-
-1. The team has reportedly been working nights and weekends on a breakthrough technology
-2. Several key engineers were pulled from other projects to work on this initiative
-3. The company has been quietly acquiring smaller startups with expertise in this area
-
-## Why This Matters
-
-If these rumors are true, %s could be positioning themselves to disrupt the entire industry. 
-Competitors should be worried, as this move could significantly shift market dynamics.
-
-## Timeline
-
-Our sources suggest we might see an announcement within the next 6-12 months, though the 
-company is known for keeping projects under wraps until the last minute.
-`, company, topic, company, topic, company)
+	systemPrompt := "You are a tech commentator writing playful, clearly speculative " +
+		"\"behind the scenes\" pieces about tech companies. Base your speculation on " +
+		"publicly observable signals such as job postings, patents, acquisitions and " +
+		"supply chain reports. Never present rumors as confirmed fact. Respond in markdown " +
+		"with the sections \"What We've Heard\", \"Why This Matters\" and \"Timeline\"."
+
+	userPrompt := fmt.Sprintf(
+		"Write a behind the scenes speculation piece titled %q about %s's %s efforts.",
+		headline, company, topic,
+	)
+
+	speculation, err := b.LLM.Complete([]Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}, CompletionOptions{Temperature: 0.9})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate speculation: %w", err)
+	}
 
 	// Add a disclaimer
 	disclaimer := "DISCLAIMER: This content is speculative and based on rumors and analysis. It should not be taken as confirmed fact or used for investment decisions."
 
-	// Add some mock sources
+	// The kinds of signals the speculation is asked to draw on
 	sources := []string{
-		"Anonymous industry insiders",
 		"Pattern analysis of recent job postings",
+		"Acquisition activity",
 		"Supply chain observations",
 		"Recent patent filings",
 	}
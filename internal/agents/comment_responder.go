@@ -0,0 +1,201 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// maxReplyAttempts is how many times the model is asked for a draft before giving up
+const maxReplyAttempts = 2
+
+// MaxReplyLength keeps replies, drafted or edited, well under Instagram's comment length limit
+const MaxReplyLength = 300
+
+// Reply decisions returned by the model
+const (
+	ReplyDecisionReply  = "reply"
+	ReplyDecisionSpam   = "spam"
+	ReplyDecisionIgnore = "ignore"
+)
+
+// replyDraftSchema describes the JSON object the model must return
+const replyDraftSchema = `{
+  "decision": "one of \"reply\", \"spam\" or \"ignore\"",
+  "reason": "string, a short explanation of the decision",
+  "reply": "string, the reply text when decision is \"reply\", otherwise empty"
+}`
+
+// CommentContext is a comment to draft a reply to
+type CommentContext struct {
+	Username string
+	Text     string
+	// PostCaption is the caption of the post the comment was left on, if known
+	PostCaption string
+}
+
+// ReplyStyle is the voice an account replies in
+type ReplyStyle struct {
+	// Voice describes the account's persona, e.g. "a jaded ex-FAANG engineer"
+	Voice        string
+	SarcasmLevel int
+}
+
+// ReplyDraft is the responder's verdict on a comment
+type ReplyDraft struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	Reply    string `json:"reply"`
+}
+
+// CommentResponder drafts replies to comments in an account's voice
+type CommentResponder struct {
+	LLM LLMProvider
+}
+
+// NewCommentResponder creates a new comment responder
+func NewCommentResponder() (*CommentResponder, error) {
+	llm, err := NewOpenAIProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CommentResponder{
+		LLM: llm,
+	}, nil
+}
+
+// spamPatterns match the usual giveaway, promotion and follow-for-follow comments
+var spamPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)https?://|www\.|\.(ly|gg|io|me)/`),
+	regexp.MustCompile(`(?i)\b(dm|message|inbox) (me|us)\b`),
+	regexp.MustCompile(`(?i)\b(check|see|visit) (out )?(my|our) (bio|profile|page)\b`),
+	regexp.MustCompile(`(?i)\b(promo code|for (a )?collab|paid promotion|brand ambassador)\b`),
+	regexp.MustCompile(`(?i)\b(follow (me|back)|f4f|l4l|sub4sub)\b`),
+	regexp.MustCompile(`(?i)\b(crypto|forex|bitcoin|nft|investment) (signals?|profits?|opportunity)\b`),
+	regexp.MustCompile(`(?i)\b(earn|make) \$?\d+`),
+}
+
+// IsLikelySpam flags obvious spam without asking the model
+func IsLikelySpam(text string) bool {
+	for _, pattern := range spamPatterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasWords reports whether text contains any letters or digits, as opposed to
+// being only emoji and punctuation
+func hasWords(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// DraftReply decides whether a comment deserves a reply and drafts one.
+// Obvious spam and emoji-only comments are decided without calling the model.
+func (r *CommentResponder) DraftReply(comment CommentContext, style ReplyStyle) (*ReplyDraft, error) {
+	if err := validateSarcasmLevel(style.SarcasmLevel); err != nil {
+		return nil, err
+	}
+
+	if IsLikelySpam(comment.Text) {
+		return &ReplyDraft{Decision: ReplyDecisionSpam, Reason: "matched a spam pattern"}, nil
+	}
+	if !hasWords(comment.Text) {
+		return &ReplyDraft{Decision: ReplyDecisionIgnore, Reason: "comment has no words to reply to"}, nil
+	}
+
+	voice := style.Voice
+	if voice == "" {
+		voice = "a witty tech commentator"
+	}
+
+	systemPrompt := fmt.Sprintf(
+		"You reply to comments on an Instagram tech commentary account. You write as %s. "+
+			"%s Be playful with the topic, never insult the commenter, and never make promises "+
+			"on behalf of the account. Keep replies to one or two sentences and under %d characters. "+
+			"Classify the comment as \"spam\" if it is promotional, a scam or a bot, as \"ignore\" if it "+
+			"needs no reply (e.g. abusive or off-topic), and as \"reply\" otherwise. "+
+			"Respond with only a JSON object matching this schema:\n%s",
+		voice, sarcasmInstruction(style.SarcasmLevel), MaxReplyLength, replyDraftSchema,
+	)
+
+	var userPrompt strings.Builder
+	if comment.PostCaption != "" {
+		fmt.Fprintf(&userPrompt, "Post caption:\n%s\n\n", comment.PostCaption)
+	}
+	fmt.Fprintf(&userPrompt, "Comment from @%s:\n%s", comment.Username, comment.Text)
+
+	messages := []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt.String()},
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxReplyAttempts; attempt++ {
+		raw, err := r.LLM.Complete(messages, CompletionOptions{Temperature: 0.8, JSONMode: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to draft reply: %w", err)
+		}
+
+		draft, err := parseReplyDraft(raw)
+		if err == nil {
+			return draft, nil
+		}
+		lastErr = err
+
+		messages = append(messages,
+			Message{Role: "assistant", Content: raw},
+			Message{Role: "user", Content: fmt.Sprintf(
+				"Your previous response was invalid: %v. Respond again with only a JSON object matching this schema:\n%s",
+				err, replyDraftSchema,
+			)},
+		)
+	}
+
+	return nil, fmt.Errorf("model returned an invalid reply draft after %d attempts: %w", maxReplyAttempts, lastErr)
+}
+
+// parseReplyDraft decodes and validates the model output
+func parseReplyDraft(raw string) (*ReplyDraft, error) {
+	payload := extractJSONObject(raw)
+	if payload == "" {
+		return nil, fmt.Errorf("response does not contain a JSON object")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payload)))
+	decoder.DisallowUnknownFields()
+
+	var draft ReplyDraft
+	if err := decoder.Decode(&draft); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON for the schema: %w", err)
+	}
+
+	draft.Decision = strings.ToLower(strings.TrimSpace(draft.Decision))
+	draft.Reply = strings.TrimSpace(draft.Reply)
+
+	switch draft.Decision {
+	case ReplyDecisionReply:
+		if draft.Reply == "" {
+			return nil, fmt.Errorf("reply is required when decision is %q", ReplyDecisionReply)
+		}
+		if len([]rune(draft.Reply)) > MaxReplyLength {
+			return nil, fmt.Errorf("reply is longer than %d characters", MaxReplyLength)
+		}
+	case ReplyDecisionSpam, ReplyDecisionIgnore:
+		draft.Reply = ""
+	default:
+		return nil, fmt.Errorf("decision must be one of %q, %q or %q", ReplyDecisionReply, ReplyDecisionSpam, ReplyDecisionIgnore)
+	}
+
+	return &draft, nil
+}
@@ -0,0 +1,157 @@
+package agents
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReplyDraft(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    ReplyDraft
+		wantErr string
+	}{
+		{
+			name: "reply",
+			raw:  `{"decision": "reply", "reason": "on topic", "reply": "  Bold of you to assume it compiles.  "}`,
+			want: ReplyDraft{Decision: ReplyDecisionReply, Reason: "on topic", Reply: "Bold of you to assume it compiles."},
+		},
+		{
+			name: "wrapped in prose and a code fence",
+			raw:  "Sure!\n```json\n{\"decision\": \" Reply \", \"reason\": \"\", \"reply\": \"Fair.\"}\n```",
+			want: ReplyDraft{Decision: ReplyDecisionReply, Reply: "Fair."},
+		},
+		{
+			name: "spam drops the reply",
+			raw:  `{"decision": "spam", "reason": "crypto", "reply": "thanks!"}`,
+			want: ReplyDraft{Decision: ReplyDecisionSpam, Reason: "crypto"},
+		},
+		{
+			name: "ignore",
+			raw:  `{"decision": "ignore", "reason": "abusive", "reply": ""}`,
+			want: ReplyDraft{Decision: ReplyDecisionIgnore, Reason: "abusive"},
+		},
+		{name: "no JSON", raw: "I'd rather not.", wantErr: "does not contain a JSON object"},
+		{name: "truncated JSON", raw: `{"decision": "reply", "reply": "Half`, wantErr: "does not contain a JSON object"},
+		{name: "malformed JSON", raw: `{"decision": reply}`, wantErr: "not valid JSON"},
+		{name: "unknown field", raw: `{"decision": "reply", "reply": "Hi", "mood": "smug"}`, wantErr: "not valid JSON"},
+		{name: "unknown decision", raw: `{"decision": "maybe", "reply": "Hi"}`, wantErr: "decision must be one of"},
+		{name: "missing reply", raw: `{"decision": "reply", "reply": "   "}`, wantErr: "reply is required"},
+		{
+			name:    "over-long reply",
+			raw:     `{"decision": "reply", "reply": "` + strings.Repeat("é", MaxReplyLength+1) + `"}`,
+			wantErr: "longer than",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			draft, err := parseReplyDraft(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseReplyDraft: %v", err)
+			}
+			if *draft != tt.want {
+				t.Errorf("draft = %+v, want %+v", *draft, tt.want)
+			}
+		})
+	}
+
+	// The limit counts characters, not bytes
+	atLimit := `{"decision": "reply", "reply": "` + strings.Repeat("é", MaxReplyLength) + `"}`
+	if _, err := parseReplyDraft(atLimit); err != nil {
+		t.Errorf("reply of exactly %d characters rejected: %v", MaxReplyLength, err)
+	}
+}
+
+func TestIsLikelySpam(t *testing.T) {
+	tests := []struct {
+		text string
+		spam bool
+	}{
+		{"Check out my page for more!", true},
+		{"DM me for a collab", true},
+		{"Great post, follow back?", true},
+		{"f4f anyone", true},
+		{"Visit bit.ly/free-stuff", true},
+		{"see https://example.com", true},
+		{"Crypto signals that actually work", true},
+		{"I earn $500 a day from home", true},
+		{"Looking for a brand ambassador", true},
+		{"This is why I still use vim", false},
+		{"The M3 benchmarks look fake to me", false},
+		{"Message received, Apple", false},
+		{"My profile of this chip would be different", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsLikelySpam(tt.text); got != tt.spam {
+			t.Errorf("IsLikelySpam(%q) = %v, want %v", tt.text, got, tt.spam)
+		}
+	}
+}
+
+func TestDraftReplyRetriesInvalidOutput(t *testing.T) {
+	llm := newScriptedLLM(t,
+		`{"decision": "reply"}`,
+		`{"decision": "reply", "reason": "on topic", "reply": "Tabs. Obviously."}`,
+	)
+	responder := &CommentResponder{LLM: llm}
+
+	draft, err := responder.DraftReply(CommentContext{Username: "dev", Text: "tabs or spaces?"}, ReplyStyle{SarcasmLevel: 5})
+	if err != nil {
+		t.Fatalf("DraftReply: %v", err)
+	}
+	if draft.Reply != "Tabs. Obviously." {
+		t.Errorf("reply = %q", draft.Reply)
+	}
+	if len(llm.requests) != 2 {
+		t.Fatalf("made %d requests, want 2", len(llm.requests))
+	}
+	retry := llm.requests[1].Messages
+	if last := retry[len(retry)-1].Content; !strings.Contains(last, "reply is required") {
+		t.Errorf("retry prompt %q doesn't explain the error", last)
+	}
+}
+
+func TestDraftReplyGivesUp(t *testing.T) {
+	llm := newScriptedLLM(t, "no", "still no")
+	responder := &CommentResponder{LLM: llm}
+
+	_, err := responder.DraftReply(CommentContext{Username: "dev", Text: "tabs or spaces?"}, ReplyStyle{SarcasmLevel: 5})
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("err = %v, want it to give up after 2 attempts", err)
+	}
+}
+
+func TestDraftReplySkipsTheModel(t *testing.T) {
+	llm := newScriptedLLM(t)
+	responder := &CommentResponder{LLM: llm}
+
+	tests := []struct {
+		text     string
+		decision string
+	}{
+		{"follow me for daily memes", ReplyDecisionSpam},
+		{"🔥🔥🔥!!", ReplyDecisionIgnore},
+	}
+	for _, tt := range tests {
+		draft, err := responder.DraftReply(CommentContext{Text: tt.text}, ReplyStyle{SarcasmLevel: 5})
+		if err != nil {
+			t.Fatalf("DraftReply(%q): %v", tt.text, err)
+		}
+		if draft.Decision != tt.decision {
+			t.Errorf("DraftReply(%q) decision = %q, want %q", tt.text, draft.Decision, tt.decision)
+		}
+	}
+	if len(llm.requests) != 0 {
+		t.Errorf("made %d requests, want none", len(llm.requests))
+	}
+}
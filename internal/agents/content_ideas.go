@@ -0,0 +1,151 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxIdeaAttempts is how many times the model is asked for ideas before giving up
+const maxIdeaAttempts = 3
+
+// contentIdeasSchema describes the JSON object the model must return
+const contentIdeasSchema = `{
+  "ideas": [
+    {
+      "headline": "string, required, at most 120 characters",
+      "content": "string, required, the caption body",
+      "talking_points": ["string", "... 1 to 6 items"],
+      "hashtags": ["#string", "... 1 to 30 items"],
+      "source_stories": "array of the integer story numbers the idea is based on, e.g. [1, 3]"
+    }
+  ]
+}`
+
+// generatedIdea is a content idea as returned by the model
+type generatedIdea struct {
+	ContentIdea
+	// SourceStories are the 1-based numbers of the stories in the prompt
+	SourceStories []int `json:"source_stories"`
+}
+
+// contentIdeasResponse is the envelope the model returns ideas in
+type contentIdeasResponse struct {
+	Ideas []generatedIdea `json:"ideas"`
+}
+
+// requestContentIdeas asks the LLM for ideas and re-prompts with the validation
+// error whenever the response does not match the schema
+func requestContentIdeas(llm LLMProvider, messages []Message, storyCount int) ([]generatedIdea, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxIdeaAttempts; attempt++ {
+		raw, err := llm.Complete(messages, CompletionOptions{Temperature: 0.7, JSONMode: true})
+		if err != nil {
+			return nil, err
+		}
+
+		ideas, err := parseContentIdeas(raw, storyCount)
+		if err == nil {
+			return ideas, nil
+		}
+		lastErr = err
+
+		messages = append(messages,
+			Message{Role: "assistant", Content: raw},
+			Message{Role: "user", Content: fmt.Sprintf(
+				"Your previous response was invalid: %v. Respond again with only a JSON object matching this schema:\n%s",
+				err, contentIdeasSchema,
+			)},
+		)
+	}
+
+	return nil, fmt.Errorf("model returned invalid content ideas after %d attempts: %w", maxIdeaAttempts, lastErr)
+}
+
+// parseContentIdeas decodes and validates the model output, repairing the
+// common cases of markdown fences and prose around the JSON object
+func parseContentIdeas(raw string, storyCount int) ([]generatedIdea, error) {
+	payload := extractJSONObject(raw)
+	if payload == "" {
+		return nil, fmt.Errorf("response does not contain a JSON object")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(payload)))
+	decoder.DisallowUnknownFields()
+
+	var response contentIdeasResponse
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON for the schema: %w", err)
+	}
+
+	if len(response.Ideas) == 0 {
+		return nil, fmt.Errorf("\"ideas\" must contain at least one idea")
+	}
+
+	for i := range response.Ideas {
+		if err := validateContentIdea(&response.Ideas[i].ContentIdea); err != nil {
+			return nil, fmt.Errorf("idea %d: %w", i+1, err)
+		}
+		for _, story := range response.Ideas[i].SourceStories {
+			if story < 1 || story > storyCount {
+				return nil, fmt.Errorf("idea %d: \"source_stories\" must be story numbers between 1 and %d", i+1, storyCount)
+			}
+		}
+	}
+
+	return response.Ideas, nil
+}
+
+// validateContentIdea checks the required fields and normalizes hashtags
+func validateContentIdea(idea *ContentIdea) error {
+	idea.Headline = strings.TrimSpace(idea.Headline)
+	idea.Content = strings.TrimSpace(idea.Content)
+
+	if idea.Headline == "" {
+		return fmt.Errorf("\"headline\" is required")
+	}
+	if utf8.RuneCountInString(idea.Headline) > 120 {
+		return fmt.Errorf("\"headline\" must be at most 120 characters")
+	}
+	if idea.Content == "" {
+		return fmt.Errorf("\"content\" is required")
+	}
+
+	points := idea.TalkingPoints[:0]
+	for _, point := range idea.TalkingPoints {
+		if point = strings.TrimSpace(point); point != "" {
+			points = append(points, point)
+		}
+	}
+	if len(points) == 0 || len(points) > 6 {
+		return fmt.Errorf("\"talking_points\" must contain 1 to 6 items")
+	}
+	idea.TalkingPoints = points
+
+	hashtags := idea.Hashtags[:0]
+	for _, tag := range idea.Hashtags {
+		tag = strings.Join(strings.Fields(tag), "")
+		tag = strings.TrimLeft(tag, "#")
+		if tag != "" {
+			hashtags = append(hashtags, "#"+tag)
+		}
+	}
+	if len(hashtags) == 0 || len(hashtags) > 30 {
+		return fmt.Errorf("\"hashtags\" must contain 1 to 30 items")
+	}
+	idea.Hashtags = hashtags
+
+	return nil
+}
+
+// extractJSONObject returns the outermost {...} block in s, or "" if there is none
+func extractJSONObject(s string) string {
+	start := strings.Index(s, "{")
+	end := strings.LastIndex(s, "}")
+	if start == -1 || end < start {
+		return ""
+	}
+	return s[start : end+1]
+}
@@ -0,0 +1,197 @@
+package agents
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ideaJSON builds a model response with one idea per headline
+func ideaJSON(headlines ...string) string {
+	var ideas []string
+	for _, headline := range headlines {
+		ideas = append(ideas, fmt.Sprintf(
+			`{"headline": %q, "content": "Body", "talking_points": ["point"], "hashtags": ["#tech"], "source_stories": [1]}`,
+			headline,
+		))
+	}
+	return `{"ideas": [` + strings.Join(ideas, ", ") + `]}`
+}
+
+func TestParseContentIdeas(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr string
+	}{
+		{name: "valid", raw: ideaJSON("One", "Two"), want: []string{"One", "Two"}},
+		{
+			name: "fenced and wrapped in prose",
+			raw:  "Here are your ideas:\n```json\n" + ideaJSON("Fenced") + "\n```\nEnjoy!",
+			want: []string{"Fenced"},
+		},
+		{name: "no JSON", raw: "I can't help with that.", wantErr: "does not contain a JSON object"},
+		{name: "cut off mid-response", raw: `{"ideas": [{"headline": "Half`, wantErr: "does not contain a JSON object"},
+		{name: "malformed JSON", raw: `{"ideas": [{"headline": Unquoted}]}`, wantErr: "not valid JSON"},
+		{name: "wrong type", raw: `{"ideas": {"headline": "Not a list"}}`, wantErr: "not valid JSON"},
+		{name: "unknown field", raw: `{"ideas": [], "notes": "extra"}`, wantErr: "not valid JSON"},
+		{name: "no ideas", raw: `{"ideas": []}`, wantErr: "at least one idea"},
+		{
+			name:    "partial idea",
+			raw:     `{"ideas": [{"headline": "Only a headline"}]}`,
+			wantErr: `idea 1: "content" is required`,
+		},
+		{
+			name:    "story out of range",
+			raw:     `{"ideas": [{"headline": "H", "content": "C", "talking_points": ["p"], "hashtags": ["#t"], "source_stories": [3]}]}`,
+			wantErr: "between 1 and 2",
+		},
+		{
+			name:    "second idea invalid",
+			raw:     ideaJSON("Fine", strings.Repeat("x", 121)),
+			wantErr: `idea 2: "headline" must be at most 120 characters`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ideas, err := parseContentIdeas(tt.raw, 2)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseContentIdeas: %v", err)
+			}
+			var headlines []string
+			for _, idea := range ideas {
+				headlines = append(headlines, idea.Headline)
+			}
+			if !reflect.DeepEqual(headlines, tt.want) {
+				t.Errorf("headlines = %v, want %v", headlines, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateContentIdea(t *testing.T) {
+	valid := func() ContentIdea {
+		return ContentIdea{Headline: "Headline", Content: "Body", TalkingPoints: []string{"point"}, Hashtags: []string{"#tech"}}
+	}
+	many := func(n int, item string) []string {
+		items := make([]string, n)
+		for i := range items {
+			items[i] = fmt.Sprintf("%s%d", item, i)
+		}
+		return items
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(*ContentIdea)
+		wantErr string
+	}{
+		{name: "valid", edit: func(*ContentIdea) {}},
+		{name: "blank headline", edit: func(i *ContentIdea) { i.Headline = "  " }, wantErr: `"headline" is required`},
+		{name: "headline of 120 characters", edit: func(i *ContentIdea) { i.Headline = strings.Repeat("é", 120) }},
+		{name: "headline too long", edit: func(i *ContentIdea) { i.Headline = strings.Repeat("é", 121) }, wantErr: "at most 120"},
+		{name: "blank content", edit: func(i *ContentIdea) { i.Content = "\n" }, wantErr: `"content" is required`},
+		{name: "only blank talking points", edit: func(i *ContentIdea) { i.TalkingPoints = []string{" ", ""} }, wantErr: `"talking_points"`},
+		{name: "too many talking points", edit: func(i *ContentIdea) { i.TalkingPoints = many(7, "point") }, wantErr: `"talking_points"`},
+		{name: "no hashtags", edit: func(i *ContentIdea) { i.Hashtags = nil }, wantErr: `"hashtags"`},
+		{name: "only empty hashtags", edit: func(i *ContentIdea) { i.Hashtags = []string{"#", " # "} }, wantErr: `"hashtags"`},
+		{name: "too many hashtags", edit: func(i *ContentIdea) { i.Hashtags = many(31, "tag") }, wantErr: `"hashtags"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idea := valid()
+			tt.edit(&idea)
+			err := validateContentIdea(&idea)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("err = %v, want none", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateContentIdeaNormalizes(t *testing.T) {
+	idea := ContentIdea{
+		Headline:      "  Headline \n",
+		Content:       " Body ",
+		TalkingPoints: []string{" first ", "", "second"},
+		Hashtags:      []string{"tech", "##ai", " machine learning ", "#"},
+	}
+	if err := validateContentIdea(&idea); err != nil {
+		t.Fatalf("validateContentIdea: %v", err)
+	}
+
+	want := ContentIdea{
+		Headline:      "Headline",
+		Content:       "Body",
+		TalkingPoints: []string{"first", "second"},
+		Hashtags:      []string{"#tech", "#ai", "#machinelearning"},
+	}
+	if !reflect.DeepEqual(idea, want) {
+		t.Errorf("idea = %+v, want %+v", idea, want)
+	}
+}
+
+func TestRequestContentIdeasRepairsTheSchema(t *testing.T) {
+	llm := newScriptedLLM(t,
+		"Sorry, here you go: {\"ideas\": [{\"headline\": \"Missing the rest\"}]}",
+		ideaJSON(strings.Repeat("Long ", 30)),
+		ideaJSON("Third time lucky"),
+	)
+	prompt := []Message{{Role: "user", Content: "ideas please"}}
+
+	ideas, err := requestContentIdeas(llm, prompt, 1)
+	if err != nil {
+		t.Fatalf("requestContentIdeas: %v", err)
+	}
+	if len(ideas) != 1 || ideas[0].Headline != "Third time lucky" || !reflect.DeepEqual(ideas[0].SourceStories, []int{1}) {
+		t.Errorf("ideas = %+v, want the third response", ideas)
+	}
+
+	if len(llm.requests) != 3 {
+		t.Fatalf("made %d requests, want 3", len(llm.requests))
+	}
+	for i, request := range llm.requests {
+		if request.ResponseFormat == nil || request.ResponseFormat.Type != "json_object" {
+			t.Errorf("request %d not in JSON mode", i+1)
+		}
+	}
+
+	// Each retry replays the conversation with the rejected response and why it was rejected
+	retry := llm.requests[2].Messages
+	if len(retry) != 5 {
+		t.Fatalf("third request has %d messages, want 5", len(retry))
+	}
+	if retry[1].Role != "assistant" || !strings.Contains(retry[1].Content, "Missing the rest") {
+		t.Errorf("first response not replayed: %+v", retry[1])
+	}
+	for i, want := range map[int]string{2: `"content" is required`, 4: "at most 120 characters"} {
+		if retry[i].Role != "user" || !strings.Contains(retry[i].Content, want) || !strings.Contains(retry[i].Content, `"ideas"`) {
+			t.Errorf("message %d = %+v, want the error %q and the schema", i, retry[i], want)
+		}
+	}
+	if prompt[0].Content != "ideas please" || len(prompt) != 1 {
+		t.Errorf("caller's prompt modified: %+v", prompt)
+	}
+}
+
+func TestRequestContentIdeasGivesUp(t *testing.T) {
+	llm := newScriptedLLM(t, "nope", `{"ideas": []}`, `{"ideas": [`)
+
+	_, err := requestContentIdeas(llm, []Message{{Role: "user", Content: "ideas please"}}, 1)
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") || !strings.Contains(err.Error(), "does not contain a JSON object") {
+		t.Errorf("err = %v, want the last error after 3 attempts", err)
+	}
+}
@@ -0,0 +1,409 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultFeedMaxItems   = 20
+	maxFeedContentLength  = 1000
+	feedUserAgent         = "instagram-ai-agents/1.0 (+https://github.com/igo-used/instagram-ai-agents)"
+	maxFeedResponseLength = 10 << 20
+)
+
+// FeedConfig configures a single RSS or Atom feed
+type FeedConfig struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	MaxItems int    `json:"max_items"`
+	// Keywords limits the feed to items mentioning at least one keyword (case-insensitive)
+	Keywords []string `json:"keywords"`
+}
+
+// FeedSource reads an RSS 2.0 or Atom feed
+type FeedSource struct {
+	Config     FeedConfig
+	HTTPClient *http.Client
+}
+
+// feedState remembers the validators and items from the last successful fetch
+type feedState struct {
+	etag         string
+	lastModified string
+	items        []NewsItem
+}
+
+// feedCache is shared across FeedSource instances since the analyzer is
+// created per request; it keeps conditional GETs working between requests
+var feedCache = struct {
+	sync.Mutex
+	states map[string]*feedState
+}{states: make(map[string]*feedState)}
+
+// NewFeedSource creates a new feed source
+func NewFeedSource(config FeedConfig) *FeedSource {
+	if config.MaxItems <= 0 {
+		config.MaxItems = defaultFeedMaxItems
+	}
+
+	return &FeedSource{
+		Config:     config,
+		HTTPClient: &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+// LoadFeedConfigs reads feed configuration from the JSON file named by
+// NEWS_FEEDS_FILE, returning no feeds when the variable is not set
+func LoadFeedConfigs() ([]FeedConfig, error) {
+	path := os.Getenv("NEWS_FEEDS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feeds file: %w", err)
+	}
+
+	var configs []FeedConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid feeds file %s: %w", path, err)
+	}
+
+	for i, config := range configs {
+		if config.URL == "" {
+			return nil, fmt.Errorf("feed %d in %s has no url", i+1, path)
+		}
+	}
+
+	return configs, nil
+}
+
+// Name returns the configured feed name, falling back to the feed URL
+func (f *FeedSource) Name() string {
+	if f.Config.Name == "" {
+		return f.Config.URL
+	}
+	return f.Config.Name
+}
+
+// Fetch downloads and parses the feed, reusing the cached items when the
+// server answers the conditional GET with 304 Not Modified
+func (f *FeedSource) Fetch() ([]NewsItem, error) {
+	req, err := http.NewRequest(http.MethodGet, f.Config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", feedUserAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8")
+
+	feedCache.Lock()
+	cached := feedCache.states[f.Config.URL]
+	feedCache.Unlock()
+
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	httpClient := f.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.items, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed request failed with status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxFeedResponseLength))
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := parseFeed(body, f.Config.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	items = filterFeedItems(items, f.Config.Keywords)
+	if len(items) > f.Config.MaxItems {
+		items = items[:f.Config.MaxItems]
+	}
+
+	feedCache.Lock()
+	feedCache.states[f.Config.URL] = &feedState{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		items:        items,
+	}
+	feedCache.Unlock()
+
+	return items, nil
+}
+
+// filterFeedItems keeps the items mentioning at least one keyword
+func filterFeedItems(items []NewsItem, keywords []string) []NewsItem {
+	if len(keywords) == 0 {
+		return items
+	}
+
+	var filtered []NewsItem
+	for _, item := range items {
+		text := strings.ToLower(item.Title + " " + item.Content)
+		for _, keyword := range keywords {
+			if strings.Contains(text, strings.ToLower(keyword)) {
+				filtered = append(filtered, item)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// rssFeed is the subset of RSS 2.0 we read
+type rssFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			GUID        string `xml:"guid"`
+			Description string `xml:"description"`
+			Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			PubDate     string `xml:"pubDate"`
+			Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// atomFeed is the subset of Atom we read
+type atomFeed struct {
+	Title   string `xml:"title"`
+	Entries []struct {
+		Title string `xml:"title"`
+		ID    string `xml:"id"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// parseFeed detects whether data is RSS or Atom and converts it to news items
+func parseFeed(data []byte, sourceName string) ([]NewsItem, error) {
+	root, err := feedRootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "rss":
+		var feed rssFeed
+		if err := newFeedDecoder(data).Decode(&feed); err != nil {
+			return nil, fmt.Errorf("invalid rss feed: %w", err)
+		}
+
+		if sourceName == "" {
+			sourceName = sanitizeFeedText(feed.Channel.Title)
+		}
+
+		items := make([]NewsItem, 0, len(feed.Channel.Items))
+		for _, entry := range feed.Channel.Items {
+			link := strings.TrimSpace(entry.Link)
+			if link == "" && strings.HasPrefix(entry.GUID, "http") {
+				link = strings.TrimSpace(entry.GUID)
+			}
+
+			content := entry.Description
+			if content == "" {
+				content = entry.Encoded
+			}
+
+			published := entry.PubDate
+			if published == "" {
+				published = entry.Date
+			}
+
+			items = append(items, NewsItem{
+				Title:       sanitizeFeedText(entry.Title),
+				Source:      sourceName,
+				URL:         link,
+				Content:     truncateText(sanitizeFeedText(content), maxFeedContentLength),
+				PublishedAt: parseFeedDate(published),
+			})
+		}
+		return items, nil
+
+	case "feed":
+		var feed atomFeed
+		if err := newFeedDecoder(data).Decode(&feed); err != nil {
+			return nil, fmt.Errorf("invalid atom feed: %w", err)
+		}
+
+		if sourceName == "" {
+			sourceName = sanitizeFeedText(feed.Title)
+		}
+
+		items := make([]NewsItem, 0, len(feed.Entries))
+		for _, entry := range feed.Entries {
+			var link string
+			for _, l := range entry.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+			if link == "" && strings.HasPrefix(entry.ID, "http") {
+				link = entry.ID
+			}
+
+			content := entry.Summary
+			if content == "" {
+				content = entry.Content
+			}
+
+			published := entry.Published
+			if published == "" {
+				published = entry.Updated
+			}
+
+			items = append(items, NewsItem{
+				Title:       sanitizeFeedText(entry.Title),
+				Source:      sourceName,
+				URL:         strings.TrimSpace(link),
+				Content:     truncateText(sanitizeFeedText(content), maxFeedContentLength),
+				PublishedAt: parseFeedDate(published),
+			})
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root)
+	}
+}
+
+// feedRootElement returns the local name of the document's root element
+func feedRootElement(data []byte) (string, error) {
+	decoder := newFeedDecoder(data)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("invalid feed: %w", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// newFeedDecoder creates a lenient XML decoder that also accepts Latin-1 feeds
+func newFeedDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-8", "utf8", "us-ascii", "ascii":
+			return input, nil
+		case "iso-8859-1", "latin1", "latin-1", "windows-1252":
+			raw, err := ioutil.ReadAll(input)
+			if err != nil {
+				return nil, err
+			}
+			buf := make([]byte, 0, len(raw))
+			for _, b := range raw {
+				buf = utf8.AppendRune(buf, rune(b))
+			}
+			return bytes.NewReader(buf), nil
+		default:
+			return nil, fmt.Errorf("unsupported feed charset: %s", charset)
+		}
+	}
+	return decoder
+}
+
+// feedDateLayouts are the date formats seen in the wild, most common first
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 06 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseFeedDate parses a feed timestamp, returning the zero time if it's unrecognised
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var (
+	feedScriptPattern     = regexp.MustCompile(`(?is)<(script|style|iframe)[^>]*>.*?</(script|style|iframe)>`)
+	feedTagPattern        = regexp.MustCompile(`(?s)<[^>]*>`)
+	feedWhitespacePattern = regexp.MustCompile(`[\s\p{Zs}]+`)
+)
+
+// sanitizeFeedText strips markup, decodes entities and collapses whitespace
+func sanitizeFeedText(value string) string {
+	value = feedScriptPattern.ReplaceAllString(value, " ")
+	value = feedTagPattern.ReplaceAllString(value, " ")
+	value = html.UnescapeString(value)
+	value = feedWhitespacePattern.ReplaceAllString(value, " ")
+	return strings.TrimSpace(value)
+}
+
+// truncateText shortens s to at most limit runes, ending on a word boundary
+func truncateText(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+
+	cut := string(runes[:limit])
+	if i := strings.LastIndex(cut, " "); i > limit/2 {
+		cut = cut[:i]
+	}
+	return cut + "..."
+}
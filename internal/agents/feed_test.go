@@ -0,0 +1,242 @@
+package agents
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rssFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Fixture &amp; Friends</title>
+	<item>
+		<title>Chips get &lt;b&gt;faster&lt;/b&gt;</title>
+		<link>https://news.example/chips</link>
+		<description><![CDATA[<p>Benchmarks&nbsp;are <em>in</em></p><script>track()</script>]]></description>
+		<pubDate>Wed, 01 May 2024 10:00:00 +0000</pubDate>
+	</item>
+	<item>
+		<title>Robots learn to fold laundry</title>
+		<guid>https://news.example/robots</guid>
+		<content:encoded><![CDATA[<div>Slowly.</div>]]></content:encoded>
+		<dc:date>2024-05-01T09:00:00Z</dc:date>
+	</item>
+</channel>
+</rss>`
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Atom Fixture</title>
+	<entry>
+		<title>Compilers, explained</title>
+		<id>tag:news.example,2024:1</id>
+		<link rel="self" href="https://news.example/self/1"/>
+		<link rel="alternate" href="https://news.example/compilers"/>
+		<summary>A &lt;i&gt;gentle&lt;/i&gt; intro</summary>
+		<published>2024-05-01T08:00:00Z</published>
+	</entry>
+	<entry>
+		<title>Databases, explained</title>
+		<id>https://news.example/databases</id>
+		<content type="html">Tables &amp;amp; rows</content>
+		<updated>2024-05-01T07:00:00Z</updated>
+	</entry>
+</feed>`
+
+// newFeedFixture serves body as a feed with the given content type
+func newFeedFixture(t *testing.T, contentType, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFeedSourceRSS(t *testing.T) {
+	server := newFeedFixture(t, "application/rss+xml", rssFixture)
+
+	news, err := NewFeedSource(FeedConfig{URL: server.URL}).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := []NewsItem{
+		{
+			Title:       "Chips get faster",
+			Source:      "Fixture & Friends",
+			URL:         "https://news.example/chips",
+			Content:     "Benchmarks are in",
+			PublishedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:       "Robots learn to fold laundry",
+			Source:      "Fixture & Friends",
+			URL:         "https://news.example/robots",
+			Content:     "Slowly.",
+			PublishedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+		},
+	}
+	assertNewsItems(t, news, want)
+}
+
+func TestFeedSourceAtom(t *testing.T) {
+	server := newFeedFixture(t, "application/atom+xml", atomFixture)
+
+	news, err := NewFeedSource(FeedConfig{URL: server.URL}).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := []NewsItem{
+		{
+			Title:       "Compilers, explained",
+			Source:      "Atom Fixture",
+			URL:         "https://news.example/compilers",
+			Content:     "A gentle intro",
+			PublishedAt: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:       "Databases, explained",
+			Source:      "Atom Fixture",
+			URL:         "https://news.example/databases",
+			Content:     "Tables & rows",
+			PublishedAt: time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC),
+		},
+	}
+	assertNewsItems(t, news, want)
+}
+
+func TestFeedSourceConfig(t *testing.T) {
+	server := newFeedFixture(t, "application/rss+xml", rssFixture)
+
+	news, err := NewFeedSource(FeedConfig{Name: "Configured", URL: server.URL, Keywords: []string{"ROBOTS", "gpu"}}).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(news) != 1 || news[0].URL != "https://news.example/robots" || news[0].Source != "Configured" {
+		t.Errorf("got %+v, want only the robots story from Configured", news)
+	}
+
+	news, err = NewFeedSource(FeedConfig{URL: server.URL, MaxItems: 1}).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(news) != 1 {
+		t.Errorf("got %d items, want MaxItems of 1", len(news))
+	}
+}
+
+func TestFeedSourceNotModified(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Wed, 01 May 2024 10:00:00 GMT")
+		w.Write([]byte(rssFixture))
+	}))
+	defer server.Close()
+
+	// Sources are created per request, so the cache has to outlive them
+	first, err := NewFeedSource(FeedConfig{URL: server.URL}).Fetch()
+	if err != nil {
+		t.Fatalf("first Fetch: %v", err)
+	}
+	second, err := NewFeedSource(FeedConfig{URL: server.URL}).Fetch()
+	if err != nil {
+		t.Fatalf("second Fetch: %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("made %d requests, want 2", len(requests))
+	}
+	if got := requests[1].Header.Get("If-Modified-Since"); got != "Wed, 01 May 2024 10:00:00 GMT" {
+		t.Errorf("If-Modified-Since = %q, want the Last-Modified of the first response", got)
+	}
+	if requests[0].Header.Get("If-None-Match") != "" {
+		t.Errorf("first request was conditional")
+	}
+	assertNewsItems(t, second, first)
+}
+
+func TestFeedSourceCharsets(t *testing.T) {
+	latin1 := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<rss><channel><title>Caf\xe9 News</title><item><title>Na\xefve caf\xe9 robots</title>" +
+		"<link>https://news.example/cafe</link></item></channel></rss>"
+	server := newFeedFixture(t, "application/rss+xml", latin1)
+
+	news, err := NewFeedSource(FeedConfig{URL: server.URL}).Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(news) != 1 || news[0].Title != "Naïve café robots" || news[0].Source != "Café News" {
+		t.Errorf("got %+v, want the Latin-1 text decoded", news)
+	}
+
+	unsupported := `<?xml version="1.0" encoding="Shift_JIS"?><rss><channel></channel></rss>`
+	server = newFeedFixture(t, "application/rss+xml", unsupported)
+	if _, err := NewFeedSource(FeedConfig{URL: server.URL}).Fetch(); err == nil || !strings.Contains(err.Error(), "charset") {
+		t.Errorf("err = %v, want an unsupported charset error", err)
+	}
+}
+
+func TestFeedSourceErrors(t *testing.T) {
+	server := newFeedFixture(t, "text/html", "<html><body>Not a feed</body></html>")
+	if _, err := NewFeedSource(FeedConfig{URL: server.URL}).Fetch(); err == nil {
+		t.Errorf("html page accepted as a feed")
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer failing.Close()
+	if _, err := NewFeedSource(FeedConfig{URL: failing.URL}).Fetch(); err == nil {
+		t.Errorf("410 response accepted")
+	}
+}
+
+func TestSanitizeFeedText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"<p>Hello <b>world</b></p>", "Hello world"},
+		{"Before<script type=\"text/javascript\">alert('x')</script>after", "Before after"},
+		{"<STYLE>p { color: red }</STYLE>Styled", "Styled"},
+		{"<iframe src=\"https://ads.example\">ad</iframe>Clean", "Clean"},
+		{"Fish &amp; chips &#8211; &quot;cheap&quot;", "Fish & chips – \"cheap\""},
+		{"  lots \n\n of\t space  ", "lots of space"},
+		{"non&nbsp;breaking\u00a0space", "non breaking space"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeFeedText(tt.value); got != tt.want {
+			t.Errorf("sanitizeFeedText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("word ", maxFeedContentLength)
+	if got := truncateText(long, maxFeedContentLength); len(got) > maxFeedContentLength+3 || !strings.HasSuffix(got, "word...") {
+		t.Errorf("truncateText cut %d runes to %q..., want a word boundary", len(long), got[:20])
+	}
+}
+
+// assertNewsItems compares news items field by field
+func assertNewsItems(t *testing.T, got, want []NewsItem) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d items, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Title != want[i].Title || got[i].Source != want[i].Source || got[i].URL != want[i].URL ||
+			got[i].Content != want[i].Content || !got[i].PublishedAt.Equal(want[i].PublishedAt) {
+			t.Errorf("item %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message represents a single chat message sent to an LLM
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CompletionOptions tunes a single completion request
+type CompletionOptions struct {
+	Temperature float64
+	MaxTokens   int
+	// JSONMode asks the provider to return a single JSON object
+	JSONMode bool
+}
+
+// LLMProvider generates text from a list of chat messages
+type LLMProvider interface {
+	Complete(messages []Message, opts CompletionOptions) (string, error)
+}
+
+// OpenAIProvider talks to any OpenAI-compatible chat completions API
+type OpenAIProvider struct {
+	APIKey     string
+	BaseURL    string
+	Model      string
+	HTTPClient *http.Client
+}
+
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4"
+	defaultOpenAITimeout = 60 * time.Second
+)
+
+// NewOpenAIProvider creates a new OpenAI-compatible provider from the environment.
+// OPENAI_API_KEY is required; OPENAI_BASE_URL, OPENAI_MODEL and
+// OPENAI_TIMEOUT_SECONDS are optional overrides.
+func NewOpenAIProvider() (*OpenAIProvider, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	}
+
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+
+	model := os.Getenv("OPENAI_MODEL")
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+
+	timeout := defaultOpenAITimeout
+	if raw := os.Getenv("OPENAI_TIMEOUT_SECONDS"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid OPENAI_TIMEOUT_SECONDS: %q", raw)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	return &OpenAIProvider{
+		APIKey:     apiKey,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Model:      model,
+		HTTPClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Complete sends the messages to the chat completions endpoint and returns the first choice
+func (p *OpenAIProvider) Complete(messages []Message, opts CompletionOptions) (string, error) {
+	type responseFormat struct {
+		Type string `json:"type"`
+	}
+
+	type request struct {
+		Model          string          `json:"model"`
+		Messages       []Message       `json:"messages"`
+		Temperature    *float64        `json:"temperature,omitempty"`
+		MaxTokens      int             `json:"max_tokens,omitempty"`
+		ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	}
+
+	req := request{
+		Model:     p.Model,
+		Messages:  messages,
+		MaxTokens: opts.MaxTokens,
+	}
+	if opts.Temperature > 0 {
+		req.Temperature = &opts.Temperature
+	}
+	if opts.JSONMode {
+		req.ResponseFormat = &responseFormat{Type: "json_object"}
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: defaultOpenAITimeout}
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("llm request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("llm request failed with status %d", resp.StatusCode)
+		}
+		return "", fmt.Errorf("invalid llm response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if response.Error != nil {
+			return "", fmt.Errorf("llm request failed with status %d: %s", resp.StatusCode, response.Error.Message)
+		}
+		return "", fmt.Errorf("llm request failed with status %d", resp.StatusCode)
+	}
+
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("llm response contained no choices")
+	}
+
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
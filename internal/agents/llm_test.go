@@ -0,0 +1,194 @@
+package agents
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProvider creates a provider talking to a stand-in chat completions
+// server that answers with handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *OpenAIProvider {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return &OpenAIProvider{
+		APIKey:     "test-key",
+		BaseURL:    server.URL,
+		Model:      "test-model",
+		HTTPClient: server.Client(),
+	}
+}
+
+// completionRequest is the body the provider sends
+type completionRequest struct {
+	Model          string    `json:"model"`
+	Messages       []Message `json:"messages"`
+	Temperature    *float64  `json:"temperature"`
+	MaxTokens      int       `json:"max_tokens"`
+	ResponseFormat *struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+}
+
+func writeChoice(w http.ResponseWriter, content string) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []interface{}{
+			map[string]interface{}{"message": Message{Role: "assistant", Content: content}},
+		},
+	})
+}
+
+// scriptedLLM answers chat completions with replies in turn and records the requests
+type scriptedLLM struct {
+	*OpenAIProvider
+	requests []completionRequest
+}
+
+func newScriptedLLM(t *testing.T, replies ...string) *scriptedLLM {
+	t.Helper()
+	llm := &scriptedLLM{}
+	llm.OpenAIProvider = newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var request completionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		llm.requests = append(llm.requests, request)
+
+		if len(llm.requests) > len(replies) {
+			t.Errorf("unexpected completion request %d", len(llm.requests))
+			http.Error(w, "no more replies", http.StatusInternalServerError)
+			return
+		}
+		writeChoice(w, replies[len(llm.requests)-1])
+	})
+	return llm
+}
+
+func TestCompleteSendsRequest(t *testing.T) {
+	var got completionRequest
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("path = %s, want /chat/completions", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Authorization = %q", auth)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		writeChoice(w, "  hello there \n")
+	})
+
+	reply, err := provider.Complete([]Message{{Role: "user", Content: "hi"}}, CompletionOptions{Temperature: 0.5, MaxTokens: 50})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if reply != "hello there" {
+		t.Errorf("reply = %q, want trimmed content", reply)
+	}
+	if got.Model != "test-model" || len(got.Messages) != 1 || got.Messages[0].Content != "hi" {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if got.Temperature == nil || *got.Temperature != 0.5 || got.MaxTokens != 50 {
+		t.Errorf("options not sent: %+v", got)
+	}
+	if got.ResponseFormat != nil {
+		t.Errorf("response_format sent without JSON mode")
+	}
+}
+
+func TestCompleteJSONMode(t *testing.T) {
+	var got completionRequest
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		writeChoice(w, `{"ideas": []}`)
+	})
+
+	reply, err := provider.Complete([]Message{{Role: "user", Content: "json please"}}, CompletionOptions{JSONMode: true})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" {
+		t.Errorf("response_format = %+v, want json_object", got.ResponseFormat)
+	}
+	if got.Temperature != nil {
+		t.Errorf("zero temperature should be left to the provider, got %v", *got.Temperature)
+	}
+	if reply != `{"ideas": []}` {
+		t.Errorf("reply = %q", reply)
+	}
+}
+
+func TestCompleteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"error body", http.StatusUnauthorized, `{"error": {"message": "Incorrect API key", "type": "invalid_request_error"}}`, "status 401: Incorrect API key"},
+		{"non-json body", http.StatusBadGateway, `<html>bad gateway</html>`, "status 502"},
+		{"json without error", http.StatusTooManyRequests, `{}`, "status 429"},
+		{"invalid success body", http.StatusOK, `not json`, "invalid llm response"},
+		{"no choices", http.StatusOK, `{"choices": []}`, "no choices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := provider.Complete([]Message{{Role: "user", Content: "hi"}}, CompletionOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompleteTimeout(t *testing.T) {
+	release := make(chan struct{})
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		writeChoice(w, "too late")
+	})
+	defer close(release)
+	provider.HTTPClient.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := provider.Complete([]Message{{Role: "user", Content: "hi"}}, CompletionOptions{})
+	if err == nil || !strings.Contains(err.Error(), "llm request failed") {
+		t.Fatalf("err = %v, want a request failure", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timed out after %s, want about 50ms", elapsed)
+	}
+}
+
+func TestNewOpenAIProviderConfig(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "key")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:1234/v1/")
+	t.Setenv("OPENAI_MODEL", "local-model")
+	t.Setenv("OPENAI_TIMEOUT_SECONDS", "7")
+
+	provider, err := NewOpenAIProvider()
+	if err != nil {
+		t.Fatalf("NewOpenAIProvider: %v", err)
+	}
+	if provider.BaseURL != "http://localhost:1234/v1" || provider.Model != "local-model" {
+		t.Errorf("unexpected provider %+v", provider)
+	}
+	if provider.HTTPClient.Timeout != 7*time.Second {
+		t.Errorf("timeout = %s, want 7s", provider.HTTPClient.Timeout)
+	}
+
+	t.Setenv("OPENAI_TIMEOUT_SECONDS", "soon")
+	if _, err := NewOpenAIProvider(); err == nil {
+		t.Errorf("invalid timeout accepted")
+	}
+}
@@ -0,0 +1,60 @@
+package agents
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// NewsSource is a provider of tech news items
+type NewsSource interface {
+	// Name identifies the source in logs and error messages
+	Name() string
+	// Fetch returns the latest items from the source
+	Fetch() ([]NewsItem, error)
+}
+
+// fetchFromSources fetches from every source and merges the results newest first.
+// It only fails when every source fails, so one broken feed doesn't blank the trend feed.
+func fetchFromSources(sources []NewsSource) ([]NewsItem, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no news sources configured")
+	}
+
+	var news []NewsItem
+	var failures []string
+	seen := make(map[string]bool)
+
+	for _, source := range sources {
+		items, err := source.Fetch()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", source.Name(), err))
+			continue
+		}
+
+		for _, item := range items {
+			if item.URL != "" {
+				if seen[item.URL] {
+					continue
+				}
+				seen[item.URL] = true
+			}
+			news = append(news, item)
+		}
+	}
+
+	if len(failures) == len(sources) {
+		return nil, fmt.Errorf("all news sources failed: %s", strings.Join(failures, "; "))
+	}
+
+	for _, failure := range failures {
+		log.Printf("news source failed: %s", failure)
+	}
+
+	sort.SliceStable(news, func(i, j int) bool {
+		return news[i].PublishedAt.After(news[j].PublishedAt)
+	})
+
+	return news, nil
+}
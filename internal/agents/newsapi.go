@@ -0,0 +1,267 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNewsAPIBaseURL  = "https://newsapi.org/v2"
+	defaultNewsAPIPageSize = 50
+	defaultNewsAPIMaxPages = 2
+	maxNewsAPIPageSize     = 100
+)
+
+// NewsAPISource fetches articles from newsapi.org.
+// When Category is set it queries top-headlines, otherwise it searches
+// everything matching Query. Articles outside the From/To window are left
+// out; top-headlines takes no window, so its articles are filtered after fetching.
+type NewsAPISource struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+
+	Query    string
+	Category string
+	Country  string
+	Language string
+	SortBy   string
+	From     time.Time
+	To       time.Time
+	PageSize int
+	MaxPages int
+}
+
+// NewsAPIError is an error response returned by NewsAPI
+type NewsAPIError struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *NewsAPIError) Error() string {
+	return fmt.Sprintf("newsapi error %s (status %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the request may succeed if tried again later
+func (e *NewsAPIError) Retryable() bool {
+	return e.Code == "rateLimited" || e.Code == "unexpectedError" || e.StatusCode >= 500
+}
+
+// NewNewsAPISource creates a NewsAPI source configured from the environment.
+// NEWS_API_KEY is required; NEWS_API_BASE_URL, NEWS_API_QUERY, NEWS_API_CATEGORY,
+// NEWS_API_COUNTRY, NEWS_API_LANGUAGE and NEWS_API_WINDOW_HOURS are optional.
+func NewNewsAPISource() (*NewsAPISource, error) {
+	apiKey := os.Getenv("NEWS_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("NEWS_API_KEY environment variable not set")
+	}
+
+	baseURL := os.Getenv("NEWS_API_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultNewsAPIBaseURL
+	}
+
+	source := &NewsAPISource{
+		APIKey:     apiKey,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Query:      os.Getenv("NEWS_API_QUERY"),
+		Category:   os.Getenv("NEWS_API_CATEGORY"),
+		Country:    os.Getenv("NEWS_API_COUNTRY"),
+		Language:   os.Getenv("NEWS_API_LANGUAGE"),
+		PageSize:   defaultNewsAPIPageSize,
+		MaxPages:   defaultNewsAPIMaxPages,
+	}
+
+	// Default to US technology headlines when nothing more specific is configured
+	if source.Query == "" && source.Category == "" {
+		source.Category = "technology"
+	}
+	if source.Category != "" && source.Country == "" {
+		source.Country = "us"
+	}
+
+	if raw := os.Getenv("NEWS_API_WINDOW_HOURS"); raw != "" {
+		hours, err := strconv.Atoi(raw)
+		if err != nil || hours <= 0 {
+			return nil, fmt.Errorf("invalid NEWS_API_WINDOW_HOURS: %q", raw)
+		}
+		source.From = time.Now().Add(-time.Duration(hours) * time.Hour)
+	}
+
+	return source, nil
+}
+
+// Name returns the source name
+func (s *NewsAPISource) Name() string {
+	return "newsapi"
+}
+
+// Fetch pages through NewsAPI results up to MaxPages
+func (s *NewsAPISource) Fetch() ([]NewsItem, error) {
+	pageSize := s.PageSize
+	if pageSize <= 0 || pageSize > maxNewsAPIPageSize {
+		pageSize = defaultNewsAPIPageSize
+	}
+
+	maxPages := s.MaxPages
+	if maxPages <= 0 {
+		maxPages = 1
+	}
+
+	var news []NewsItem
+	fetched := 0
+	for page := 1; page <= maxPages; page++ {
+		items, count, total, err := s.fetchPage(page, pageSize)
+		if err != nil {
+			// Free plans cap how deep you can page; keep what we already have
+			if apiErr, ok := err.(*NewsAPIError); ok && apiErr.Code == "maximumResultsReached" && page > 1 {
+				break
+			}
+			return nil, err
+		}
+
+		news = append(news, items...)
+		fetched += count
+
+		// Count the articles NewsAPI returned, since filtering shrinks full pages
+		if count < pageSize || fetched >= total {
+			break
+		}
+	}
+
+	return news, nil
+}
+
+// fetchPage fetches a single page and returns its items, the number of
+// articles on the page before filtering and the total result count
+func (s *NewsAPISource) fetchPage(page, pageSize int) ([]NewsItem, int, int, error) {
+	params := url.Values{}
+	endpoint := s.BaseURL + "/everything"
+
+	if s.Category != "" {
+		endpoint = s.BaseURL + "/top-headlines"
+		params.Set("category", s.Category)
+		if s.Country != "" {
+			params.Set("country", s.Country)
+		}
+	} else {
+		if s.Language != "" {
+			params.Set("language", s.Language)
+		}
+		sortBy := s.SortBy
+		if sortBy == "" {
+			sortBy = "publishedAt"
+		}
+		params.Set("sortBy", sortBy)
+		if !s.From.IsZero() {
+			params.Set("from", s.From.UTC().Format(time.RFC3339))
+		}
+		if !s.To.IsZero() {
+			params.Set("to", s.To.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if s.Query != "" {
+		params.Set("q", s.Query)
+	}
+	params.Set("pageSize", strconv.Itoa(pageSize))
+	params.Set("page", strconv.Itoa(page))
+
+	req, err := http.NewRequest(http.MethodGet, endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	// Send the key as a header so it doesn't end up in proxy logs
+	req.Header.Set("X-Api-Key", s.APIKey)
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var response struct {
+		Status       string `json:"status"`
+		Code         string `json:"code"`
+		Message      string `json:"message"`
+		TotalResults int    `json:"totalResults"`
+		Articles     []struct {
+			Source struct {
+				Name string `json:"name"`
+			} `json:"source"`
+			Title       string    `json:"title"`
+			Description string    `json:"description"`
+			Content     string    `json:"content"`
+			URL         string    `json:"url"`
+			PublishedAt time.Time `json:"publishedAt"`
+		} `json:"articles"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, 0, 0, &NewsAPIError{StatusCode: resp.StatusCode, Code: "unexpectedError", Message: http.StatusText(resp.StatusCode)}
+		}
+		return nil, 0, 0, fmt.Errorf("invalid newsapi response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || response.Status == "error" {
+		return nil, 0, 0, &NewsAPIError{StatusCode: resp.StatusCode, Code: response.Code, Message: response.Message}
+	}
+
+	news := make([]NewsItem, 0, len(response.Articles))
+	for _, article := range response.Articles {
+		// NewsAPI reports removed articles with a placeholder title
+		if article.URL == "" || article.Title == "[Removed]" {
+			continue
+		}
+		if !s.inWindow(article.PublishedAt) {
+			continue
+		}
+
+		content := article.Description
+		if content == "" {
+			content = article.Content
+		}
+
+		news = append(news, NewsItem{
+			Title:       article.Title,
+			Source:      article.Source.Name,
+			URL:         article.URL,
+			Content:     content,
+			PublishedAt: article.PublishedAt,
+		})
+	}
+
+	return news, len(response.Articles), response.TotalResults, nil
+}
+
+// inWindow reports whether an article published at publishedAt falls within
+// the From/To window. Undated articles are kept.
+func (s *NewsAPISource) inWindow(publishedAt time.Time) bool {
+	if publishedAt.IsZero() {
+		return true
+	}
+	if !s.From.IsZero() && publishedAt.Before(s.From) {
+		return false
+	}
+	return s.To.IsZero() || !publishedAt.After(s.To)
+}
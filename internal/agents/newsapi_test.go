@@ -0,0 +1,281 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newsAPIArticle is an article served by the fixture
+type newsAPIArticle struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+// newsAPIFixture is a local stand-in for newsapi.org
+type newsAPIFixture struct {
+	*httptest.Server
+	articles []newsAPIArticle
+	// errStatus and errCode make every request fail when set
+	errStatus int
+	errCode   string
+	// maxPage makes pages past it fail with maximumResultsReached
+	maxPage  int
+	requests []url.URL
+}
+
+func newNewsAPIFixture(t *testing.T, articles []newsAPIArticle) *newsAPIFixture {
+	t.Helper()
+	fixture := &newsAPIFixture{articles: articles}
+	fixture.Server = httptest.NewServer(http.HandlerFunc(fixture.serve))
+	t.Cleanup(fixture.Close)
+	return fixture
+}
+
+func (f *newsAPIFixture) serve(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, *r.URL)
+
+	fail := func(status int, code string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "code": code, "message": "fixture error " + code})
+	}
+
+	if r.Header.Get("X-Api-Key") != "test-key" {
+		fail(http.StatusUnauthorized, "apiKeyInvalid")
+		return
+	}
+	if f.errStatus != 0 {
+		fail(f.errStatus, f.errCode)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if f.maxPage > 0 && page > f.maxPage {
+		fail(http.StatusUpgradeRequired, "maximumResultsReached")
+		return
+	}
+
+	start := (page - 1) * pageSize
+	end := start + pageSize
+	if start > len(f.articles) {
+		start = len(f.articles)
+	}
+	if end > len(f.articles) {
+		end = len(f.articles)
+	}
+
+	type article struct {
+		newsAPIArticle
+		Source struct {
+			Name string `json:"name"`
+		} `json:"source"`
+	}
+	articles := make([]article, 0, end-start)
+	for _, a := range f.articles[start:end] {
+		served := article{newsAPIArticle: a}
+		served.Source.Name = "Fixture News"
+		articles = append(articles, served)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "ok",
+		"totalResults": len(f.articles),
+		"articles":     articles,
+	})
+}
+
+func (f *newsAPIFixture) source() *NewsAPISource {
+	return &NewsAPISource{
+		APIKey:     "test-key",
+		BaseURL:    f.URL,
+		HTTPClient: f.Client(),
+		Query:      "golang",
+		PageSize:   2,
+		MaxPages:   5,
+	}
+}
+
+// fixtureArticles returns n articles published an hour apart, newest first
+func fixtureArticles(n int, newest time.Time) []newsAPIArticle {
+	articles := make([]newsAPIArticle, 0, n)
+	for i := 0; i < n; i++ {
+		articles = append(articles, newsAPIArticle{
+			Title:       fmt.Sprintf("Story %d", i+1),
+			URL:         fmt.Sprintf("https://news.example/%d", i+1),
+			Description: "Something happened",
+			PublishedAt: newest.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+	return articles
+}
+
+func TestNewsAPIPaging(t *testing.T) {
+	fixture := newNewsAPIFixture(t, fixtureArticles(5, time.Now()))
+
+	news, err := fixture.source().Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(news) != 5 {
+		t.Fatalf("got %d items, want 5", len(news))
+	}
+	if len(fixture.requests) != 3 {
+		t.Errorf("made %d requests, want 3 pages", len(fixture.requests))
+	}
+	if news[0].Source != "Fixture News" || news[0].Content != "Something happened" {
+		t.Errorf("unexpected item %+v", news[0])
+	}
+
+	query := fixture.requests[0].Query()
+	if fixture.requests[0].Path != "/everything" || query.Get("q") != "golang" || query.Get("sortBy") != "publishedAt" {
+		t.Errorf("unexpected request %s", fixture.requests[0].String())
+	}
+}
+
+func TestNewsAPIPagingPastRemovedArticles(t *testing.T) {
+	articles := fixtureArticles(4, time.Now())
+	articles[1].Title = "[Removed]"
+	fixture := newNewsAPIFixture(t, articles)
+
+	news, err := fixture.source().Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	// The first page shrinks to one item but was full, so paging goes on
+	if len(news) != 3 {
+		t.Fatalf("got %d items, want 3", len(news))
+	}
+	if len(fixture.requests) != 2 {
+		t.Errorf("made %d requests, want 2", len(fixture.requests))
+	}
+}
+
+func TestNewsAPIMaximumResultsKeepsEarlierPages(t *testing.T) {
+	fixture := newNewsAPIFixture(t, fixtureArticles(6, time.Now()))
+	fixture.maxPage = 1
+
+	news, err := fixture.source().Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(news) != 2 {
+		t.Errorf("got %d items, want the 2 from the first page", len(news))
+	}
+}
+
+func TestNewsAPIErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		code      string
+		retryable bool
+	}{
+		{http.StatusUnauthorized, "apiKeyInvalid", false},
+		{http.StatusBadRequest, "parameterInvalid", false},
+		{http.StatusTooManyRequests, "rateLimited", true},
+		{http.StatusInternalServerError, "unexpectedError", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			fixture := newNewsAPIFixture(t, fixtureArticles(3, time.Now()))
+			fixture.errStatus, fixture.errCode = tt.status, tt.code
+
+			_, err := fixture.source().Fetch()
+			apiErr, ok := err.(*NewsAPIError)
+			if !ok {
+				t.Fatalf("err = %v, want a *NewsAPIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Code != tt.code || apiErr.Retryable() != tt.retryable {
+				t.Errorf("got %+v retryable=%v", apiErr, apiErr.Retryable())
+			}
+		})
+	}
+}
+
+func TestNewsAPINonJSONError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>bad gateway</html>", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	source := &NewsAPISource{APIKey: "test-key", BaseURL: server.URL, Query: "golang"}
+	_, err := source.Fetch()
+	apiErr, ok := err.(*NewsAPIError)
+	if !ok || apiErr.StatusCode != http.StatusBadGateway || !apiErr.Retryable() {
+		t.Errorf("err = %v, want a retryable 502 *NewsAPIError", err)
+	}
+}
+
+func TestNewsAPIDateWindow(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	fixture := newNewsAPIFixture(t, fixtureArticles(6, now))
+	from := now.Add(-150 * time.Minute)
+
+	// everything takes the window as parameters
+	source := fixture.source()
+	source.From = from
+	source.To = now
+	if _, err := source.Fetch(); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	query := fixture.requests[0].Query()
+	if query.Get("from") != from.Format(time.RFC3339) || query.Get("to") != now.Format(time.RFC3339) {
+		t.Errorf("window not sent: %s", fixture.requests[0].String())
+	}
+
+	// top-headlines takes no window, so articles outside it are filtered out
+	fixture.requests = nil
+	source = fixture.source()
+	source.Query = ""
+	source.Category = "technology"
+	source.Country = "us"
+	source.From = from
+	news, err := source.Fetch()
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	query = fixture.requests[0].Query()
+	if fixture.requests[0].Path != "/top-headlines" || query.Get("category") != "technology" || query.Get("from") != "" {
+		t.Errorf("unexpected request %s", fixture.requests[0].String())
+	}
+	if len(news) != 3 {
+		t.Fatalf("got %d items, want the 3 published since %s", len(news), from)
+	}
+	for _, item := range news {
+		if item.PublishedAt.Before(from) {
+			t.Errorf("item %q published at %s is outside the window", item.Title, item.PublishedAt)
+		}
+	}
+}
+
+func TestNewNewsAPISourceConfig(t *testing.T) {
+	t.Setenv("NEWS_API_KEY", "key")
+	t.Setenv("NEWS_API_QUERY", "")
+	t.Setenv("NEWS_API_CATEGORY", "")
+	t.Setenv("NEWS_API_COUNTRY", "")
+	t.Setenv("NEWS_API_WINDOW_HOURS", "24")
+
+	source, err := NewNewsAPISource()
+	if err != nil {
+		t.Fatalf("NewNewsAPISource: %v", err)
+	}
+	if source.Category != "technology" || source.Country != "us" {
+		t.Errorf("defaults not applied: %+v", source)
+	}
+	if window := time.Since(source.From); window < 23*time.Hour || window > 25*time.Hour {
+		t.Errorf("From is %s ago, want 24h", window)
+	}
+
+	t.Setenv("NEWS_API_WINDOW_HOURS", "-1")
+	if _, err := NewNewsAPISource(); err == nil {
+		t.Errorf("invalid window accepted")
+	}
+}
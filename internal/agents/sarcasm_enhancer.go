@@ -2,77 +2,71 @@ package agents
 
 import (
 	"fmt"
-	"os"
 )
 
+// Sarcasm levels range from subtle wit to extreme sarcasm
+const (
+	MinSarcasmLevel = 1
+	MaxSarcasmLevel = 10
+)
+
+// validateSarcasmLevel checks a sarcasm level is on the scale
+func validateSarcasmLevel(level int) error {
+	if level < MinSarcasmLevel || level > MaxSarcasmLevel {
+		return fmt.Errorf("sarcasm level must be between %d and %d", MinSarcasmLevel, MaxSarcasmLevel)
+	}
+	return nil
+}
+
+// sarcasmInstruction describes a sarcasm level to the model
+func sarcasmInstruction(level int) string {
+	return fmt.Sprintf(
+		"On a scale of %d-%d, your sarcasm level is set to %d. "+
+			"%d is subtle wit, %d is extreme sarcasm.",
+		MinSarcasmLevel, MaxSarcasmLevel, level, MinSarcasmLevel, MaxSarcasmLevel,
+	)
+}
+
 // SarcasmEnhancer adds witty and sarcastic elements to content
 type SarcasmEnhancer struct {
-	OpenAIKey string
+	LLM LLMProvider
 }
 
 // NewSarcasmEnhancer creates a new sarcasm enhancer
 func NewSarcasmEnhancer() (*SarcasmEnhancer, error) {
-	openAIKey := os.Getenv("OPENAI_API_KEY")
-	if openAIKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	llm, err := NewOpenAIProvider()
+	if err != nil {
+		return nil, err
 	}
 
 	return &SarcasmEnhancer{
-		OpenAIKey: openAIKey,
+		LLM: llm,
 	}, nil
 }
 
 // EnhanceContent adds sarcastic elements to the provided content
 func (s *SarcasmEnhancer) EnhanceContent(content string, sarcasmLevel int) (string, error) {
-	if sarcasmLevel < 1 || sarcasmLevel > 10 {
-		return "", fmt.Errorf("sarcasm level must be between 1 and 10")
-	}
-
-	// Prepare the request to OpenAI API
-	type Message struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	}
-
-	type Request struct {
-		Model    string    `json:"model"`
-		Messages []Message `json:"messages"`
+	if err := validateSarcasmLevel(sarcasmLevel); err != nil {
+		return "", err
 	}
 
 	// Create the system prompt based on sarcasm level
-	systemPrompt := fmt.Sprintf(
-		"You are a tech commentator who adds witty and sarcastic elements to content. "+
-			"On a scale of 1-10, your sarcasm level is set to %d. "+
-			"1 is subtle wit, 10 is extreme sarcasm. "+
-			"Maintain the factual accuracy while adding humor.",
-		sarcasmLevel,
-	)
+	systemPrompt := "You are a tech commentator who adds witty and sarcastic elements to content. " +
+		sarcasmInstruction(sarcasmLevel) + " " +
+		"Maintain the factual accuracy while adding humor."
 
 	userPrompt := fmt.Sprintf(
 		"Enhance the following tech commentary with witty and sarcastic elements:\n\n%s",
 		content,
 	)
 
-	request := Request{
-		Model: "gpt-4",
-		Messages: []Message{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		},
+	enhancedContent, err := s.LLM.Complete([]Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}, CompletionOptions{Temperature: 0.8})
+	if err != nil {
+		return "", fmt.Errorf("failed to enhance content: %w", err)
 	}
 
-	// In a real implementation, you would serialize the request and call the OpenAI API
-	// For now, we'll just use the request object without sending it
-	_ = request
-
-	// In a real implementation, you would call the OpenAI API
-	// For now, we'll simulate a response
-	enhancedContent := fmt.Sprintf(
-		"[Sarcasm Level %d] Enhanced version of:\n\n%s\n\n"+
-			"Oh great, another day, another tech 'innovation' that promises to change our lives "+
-			"but will probably just change how many notifications we ignore.",
-		sarcasmLevel, content,
-	)
-
 	return enhancedContent, nil
 }
@@ -6,12 +6,12 @@ import (
 
 // Speculator represents the Behind the Scenes Speculator agent
 type Speculator struct {
-	// Add any fields you need
+	LLM LLMProvider
 }
 
-// NewSpeculator creates a new Speculator agent
-func NewSpeculator() *Speculator {
-	return &Speculator{}
+// NewSpeculator creates a new Speculator agent backed by the given LLM provider
+func NewSpeculator(llm LLMProvider) *Speculator {
+	return &Speculator{LLM: llm}
 }
 
 // GetCompanies returns a list of companies that can be speculated about
@@ -80,26 +80,21 @@ func (s *Speculator) GetTopics(company string) ([]string, error) {
 
 // GenerateSpeculation generates speculation about a company and topic
 func (s *Speculator) GenerateSpeculation(company, topic string) (map[string]interface{}, error) {
-	// In a real implementation, this would call an AI service
+	prompt := fmt.Sprintf(
+		"Write a short markdown piece titled \"Behind the Scenes at %s\" speculating about "+
+			"what %s is doing in %s that hasn't been publicly announced yet. Include the sections "+
+			"\"What We're Hearing\", \"Potential Timeline\" and \"Market Impact\". "+
+			"Make it clear that this is speculation.",
+		company, company, topic,
+	)
 
-	// For now, return mock data
-	speculation := fmt.Sprintf(`# Behind the Scenes at %s
-
-According to our analysis and industry sources, %s is making significant moves in %s that haven't been publicly announced yet.
-
-## What We're Hearing
-
-1. Internal teams have been reorganized to prioritize this area
-2. Key talent has been hired from competitors
-3. Research budgets have increased substantially
-
-## Potential Timeline
-
-We expect to see the first public announcements within 3-6 months, with actual products or services launching by the end of the year.
-
-## Market Impact
-
-If successful, this initiative could significantly impact %s's market position and potentially disrupt current industry leaders.`, company, company, topic, company)
+	speculation, err := s.LLM.Complete([]Message{
+		{Role: "system", Content: "You are a witty tech commentator who writes clearly labelled speculation."},
+		{Role: "user", Content: prompt},
+	}, CompletionOptions{Temperature: 0.9})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate speculation: %w", err)
+	}
 
 	return map[string]interface{}{
 		"headline":    fmt.Sprintf("What's Really Happening with %s's %s", company, topic),
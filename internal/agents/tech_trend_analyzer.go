@@ -3,20 +3,26 @@ package agents
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
 // TechTrendAnalyzer identifies emerging tech trends and generates content ideas
 type TechTrendAnalyzer struct {
-	NewsAPIKey string
+	Sources []NewsSource
+	LLM     LLMProvider
+	// HotHashtags are hashtags heating up on Instagram, suggested to the model
+	// for ideas they fit
+	HotHashtags []string
 }
 
 // NewsItem represents a tech news item
 type NewsItem struct {
-	Title   string `json:"title"`
-	Source  string `json:"source"`
-	URL     string `json:"url"`
-	Content string `json:"content"`
+	Title       string    `json:"title"`
+	Source      string    `json:"source"`
+	URL         string    `json:"url"`
+	Content     string    `json:"content"`
+	PublishedAt time.Time `json:"published_at"`
 }
 
 // ContentIdea represents a generated content idea
@@ -25,68 +31,135 @@ type ContentIdea struct {
 	Content       string   `json:"content"`
 	TalkingPoints []string `json:"talking_points"`
 	Hashtags      []string `json:"hashtags"`
+	// SourceURLs links the idea back to the news stories that inspired it
+	SourceURLs []string `json:"source_urls"`
 }
 
 // NewTechTrendAnalyzer creates a new tech trend analyzer
 func NewTechTrendAnalyzer() (*TechTrendAnalyzer, error) {
-	newsAPIKey := os.Getenv("NEWS_API_KEY")
-	if newsAPIKey == "" {
-		return nil, fmt.Errorf("NEWS_API_KEY environment variable not set")
+	var sources []NewsSource
+
+	// NewsAPI is optional as long as at least one feed is configured
+	if os.Getenv("NEWS_API_KEY") != "" {
+		newsAPI, err := NewNewsAPISource()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, newsAPI)
+	}
+
+	feeds, err := LoadFeedConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, feed := range feeds {
+		sources = append(sources, NewFeedSource(feed))
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no news sources configured: set NEWS_API_KEY or NEWS_FEEDS_FILE")
+	}
+
+	llm, err := NewOpenAIProvider()
+	if err != nil {
+		return nil, err
 	}
 
 	return &TechTrendAnalyzer{
-		NewsAPIKey: newsAPIKey,
+		Sources: sources,
+		LLM:     llm,
 	}, nil
 }
 
-// FetchTechNews fetches the latest tech news
+// FetchTechNews fetches the latest tech news from all configured sources
 func (t *TechTrendAnalyzer) FetchTechNews() ([]NewsItem, error) {
-	// In a real implementation, you would call the News API
-	// For now, we'll return mock data
-	news := []NewsItem{
-		{
-			Title:   "Apple Announces New AR Glasses",
-			Source:  "TechCrunch",
-			URL:     "https://techcrunch.com/apple-ar-glasses",
-			Content: "Apple has announced their new AR glasses, set to release next year...",
-		},
-		{
-			Title:   "Microsoft's AI Investment Reaches $10B",
-			Source:  "The Verge",
-			URL:     "https://theverge.com/microsoft-ai-investment",
-			Content: "Microsoft has increased their investment in AI to $10 billion...",
-		},
-		{
-			Title:   "Twitter Launches New API Pricing",
-			Source:  "Wired",
-			URL:     "https://wired.com/twitter-api-pricing",
-			Content: "Twitter has announced new API pricing tiers for developers...",
-		},
+	return fetchFromSources(t.Sources)
+}
+
+// GenerateContentIdeas generates structured content ideas based on tech news
+func (t *TechTrendAnalyzer) GenerateContentIdeas(news []NewsItem) ([]ContentIdea, error) {
+	return t.generateIdeas(news, nil)
+}
+
+// GenerateTrendingContentIdeas generates content ideas aimed at the given
+// rising topics, using their supporting articles as the source stories
+func (t *TechTrendAnalyzer) GenerateTrendingContentIdeas(trends []Trend, maxStories int) ([]ContentIdea, error) {
+	var news []NewsItem
+	seen := make(map[string]bool)
+
+	for _, trend := range trends {
+		for _, article := range trend.Articles {
+			if seen[article.URL] {
+				continue
+			}
+			seen[article.URL] = true
+			news = append(news, article)
+			break
+		}
+		if maxStories > 0 && len(news) >= maxStories {
+			break
+		}
 	}
 
-	return news, nil
+	return t.generateIdeas(news, trends)
 }
 
-// GenerateContentIdeas generates content ideas based on tech news
-func (t *TechTrendAnalyzer) GenerateContentIdeas(news []NewsItem) (string, error) {
-	// In a real implementation, you would use an AI service to generate ideas
-	// For now, we'll return mock data
-	
-	// Create a formatted string with content ideas
-	ideas := fmt.Sprintf("# Content Ideas Generated on %s\n\n", time.Now().Format("January 2, 2006"))
-	
+// generateIdeas prompts the LLM for ideas about news, steering it towards
+// the rising topics when trends are given
+func (t *TechTrendAnalyzer) generateIdeas(news []NewsItem, trends []Trend) ([]ContentIdea, error) {
+	if len(news) == 0 {
+		return nil, fmt.Errorf("no news items to generate ideas from")
+	}
+
+	var stories strings.Builder
 	for i, item := range news {
-		ideas += fmt.Sprintf("## Idea %d: %s\n\n", i+1, item.Title)
-		ideas += "### Talking Points\n"
-		ideas += "- What this means for consumers\n"
-		ideas += "- Behind the scenes analysis\n"
-		ideas += "- Potential impact on the industry\n\n"
-		ideas += "### Sarcastic Angle\n"
-		ideas += fmt.Sprintf("\"Oh great, %s - just what we needed to make our lives more 'convenient'.\"\n\n", 
-			item.Title)
-		ideas += "### Hashtags\n"
-		ideas += "#TechCommentary #SarcasticTech #BehindTheScenes\n\n"
+		fmt.Fprintf(&stories, "%d. %s (%s)\n%s\n\n", i+1, item.Title, item.Source, item.Content)
 	}
-	
+
+	systemPrompt := "You are a content strategist for a sarcastic tech commentary Instagram account. " +
+		"For each news story, write one content idea with a punchy headline, a caption with a " +
+		"sarcastic angle, talking points, hashtags and the numbers of the stories it is based on. " +
+		"Respond with only a JSON object matching " +
+		"this schema:\n" + contentIdeasSchema
+
+	userPrompt := fmt.Sprintf(
+		"Today is %s. Generate content ideas for these tech stories:\n\n%s",
+		time.Now().Format("January 2, 2006"), stories.String(),
+	)
+
+	if len(trends) > 0 {
+		terms := make([]string, 0, len(trends))
+		for _, trend := range trends {
+			terms = append(terms, fmt.Sprintf("%s (%d articles, up from %d)", trend.Term, trend.CurrentCount, trend.PreviousCount))
+		}
+		userPrompt += "These topics are rising fastest right now, so angle the ideas towards them:\n- " +
+			strings.Join(terms, "\n- ")
+	}
+
+	if len(t.HotHashtags) > 0 {
+		userPrompt += "\n\nThese hashtags are heating up on Instagram; include the ones that fit an idea: " +
+			strings.Join(t.HotHashtags, " ")
+	}
+
+	generated, err := requestContentIdeas(t.LLM, []Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}, len(news))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content ideas: %w", err)
+	}
+
+	ideas := make([]ContentIdea, 0, len(generated))
+	for _, g := range generated {
+		idea := g.ContentIdea
+		idea.SourceURLs = nil
+		for _, story := range g.SourceStories {
+			if url := news[story-1].URL; url != "" {
+				idea.SourceURLs = append(idea.SourceURLs, url)
+			}
+		}
+		ideas = append(ideas, idea)
+	}
+
 	return ideas, nil
 }
@@ -0,0 +1,370 @@
+package agents
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Trend is a term whose coverage is rising across recent news
+type Trend struct {
+	Term string `json:"term"`
+	// Kind is "entity" for capitalised names and "keyword" for everything else
+	Kind          string     `json:"kind"`
+	Score         float64    `json:"score"`
+	CurrentCount  int        `json:"current_count"`
+	PreviousCount int        `json:"previous_count"`
+	Acceleration  float64    `json:"acceleration"`
+	Articles      []NewsItem `json:"articles"`
+}
+
+// TrendEngine scores terms by how much faster they're being mentioned in
+// the current window than in the window before it, with recent articles
+// weighted more heavily than older ones
+type TrendEngine struct {
+	// Window is the length of the current and previous comparison windows
+	Window time.Duration
+	// HalfLife controls how quickly an article's weight decays with age
+	HalfLife time.Duration
+	// MinArticles is how many current articles a term needs to be considered
+	MinArticles int
+	// MaxArticles caps the supporting articles returned per trend
+	MaxArticles int
+	// UndatedWeight is the weight of articles without a publish date, which
+	// count towards the current window; dated articles weigh up to 1
+	UndatedWeight float64
+	// Now returns the reference time; it defaults to time.Now
+	Now func() time.Time
+}
+
+// termStats accumulates the counts for one term
+type termStats struct {
+	display  string
+	kind     string
+	current  int
+	previous int
+	weight   float64
+	articles []NewsItem
+}
+
+// NewTrendEngine creates a trend engine comparing the last day with the day before
+func NewTrendEngine() *TrendEngine {
+	return &TrendEngine{
+		Window:        24 * time.Hour,
+		HalfLife:      12 * time.Hour,
+		MinArticles:   2,
+		MaxArticles:   5,
+		UndatedWeight: 0.5,
+		Now:           time.Now,
+	}
+}
+
+// Detect ranks the rising terms in news, which should cover at least the
+// last two windows. Articles without a publish date count towards the current
+// window with UndatedWeight. At most limit trends are returned.
+func (e *TrendEngine) Detect(news []NewsItem, limit int) []Trend {
+	now := time.Now()
+	if e.Now != nil {
+		now = e.Now()
+	}
+	currentStart := now.Add(-e.Window)
+	previousStart := now.Add(-2 * e.Window)
+
+	stats := make(map[string]*termStats)
+
+	for _, item := range news {
+		published := item.PublishedAt
+		if published.After(now) {
+			published = now
+		}
+		if !published.IsZero() && published.Before(previousStart) {
+			continue
+		}
+
+		// Undated articles may be old, so they don't get the full weight of a fresh one
+		inCurrent := published.IsZero() || !published.Before(currentStart)
+		weight := e.UndatedWeight
+		if !published.IsZero() {
+			weight = math.Exp2(-now.Sub(published).Hours() / e.HalfLife.Hours())
+		}
+
+		// Each article counts once per term however often it repeats it
+		for key, term := range extractTerms(item.Title, item.Content) {
+			s, ok := stats[key]
+			if !ok {
+				s = &termStats{display: term.display, kind: term.kind}
+				stats[key] = s
+			} else if term.kind == "entity" && s.kind != "entity" {
+				// Prefer the capitalised spelling once any article names it
+				s.display, s.kind = term.display, term.kind
+			}
+
+			if inCurrent {
+				s.current++
+				s.weight += weight
+				s.articles = append(s.articles, item)
+			} else {
+				s.previous++
+			}
+		}
+	}
+
+	var trends []Trend
+	for _, s := range stats {
+		if s.current < e.MinArticles || s.current <= s.previous {
+			continue
+		}
+
+		acceleration := float64(s.current-s.previous) / float64(s.previous+1)
+		score := s.weight * math.Log2(2+acceleration)
+		if s.kind == "entity" {
+			score *= 1.25
+		}
+
+		sort.SliceStable(s.articles, func(i, j int) bool {
+			return s.articles[i].PublishedAt.After(s.articles[j].PublishedAt)
+		})
+
+		trends = append(trends, Trend{
+			Term:          s.display,
+			Kind:          s.kind,
+			Score:         math.Round(score*100) / 100,
+			CurrentCount:  s.current,
+			PreviousCount: s.previous,
+			Acceleration:  math.Round(acceleration*100) / 100,
+			Articles:      supportingArticles(s.articles, e.MaxArticles),
+		})
+	}
+
+	sort.SliceStable(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		// On ties rank phrases above the words they contain so they can be collapsed
+		wordsI, wordsJ := len(strings.Fields(trends[i].Term)), len(strings.Fields(trends[j].Term))
+		if wordsI != wordsJ {
+			return wordsI > wordsJ
+		}
+		return trends[i].Term < trends[j].Term
+	})
+
+	trends = collapseSubsumedTrends(trends)
+	if limit > 0 && len(trends) > limit {
+		trends = trends[:limit]
+	}
+
+	return trends
+}
+
+// supportingArticles picks up to max articles, skipping repeated headlines
+// from outlets syndicating the same story
+func supportingArticles(articles []NewsItem, max int) []NewsItem {
+	seen := make(map[string]bool)
+	var picked []NewsItem
+	for _, article := range articles {
+		key := strings.ToLower(article.Title)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		picked = append(picked, article)
+		if max > 0 && len(picked) >= max {
+			break
+		}
+	}
+	return picked
+}
+
+// collapseSubsumedTrends drops single words that only trend because a
+// higher-ranked phrase containing them does
+func collapseSubsumedTrends(trends []Trend) []Trend {
+	var kept []Trend
+	for _, trend := range trends {
+		subsumed := false
+		if !strings.Contains(trend.Term, " ") {
+			word := strings.ToLower(trend.Term)
+			for _, k := range kept {
+				if k.CurrentCount >= trend.CurrentCount && containsWord(strings.ToLower(k.Term), word) {
+					subsumed = true
+					break
+				}
+			}
+		}
+		if !subsumed {
+			kept = append(kept, trend)
+		}
+	}
+	return kept
+}
+
+// containsWord reports whether phrase contains word as a whole word
+func containsWord(phrase, word string) bool {
+	for _, w := range strings.Fields(phrase) {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// extractedTerm is a term found in an article
+type extractedTerm struct {
+	display string
+	kind    string
+}
+
+// extractTerms finds the keywords, keyword pairs and named entities in an
+// article, keyed by their lowercase form
+func extractTerms(title, content string) map[string]extractedTerm {
+	terms := make(map[string]extractedTerm)
+
+	// Title Case headlines capitalise every word, so only trust their
+	// capitalisation when the headline is in sentence case
+	addTerms(terms, title, !isTitleCase(title))
+	addTerms(terms, content, true)
+
+	return terms
+}
+
+// addTerms adds the terms found in text to terms
+func addTerms(terms map[string]extractedTerm, text string, withEntities bool) {
+	for _, sentence := range splitSentences(text) {
+		words := tokenize(sentence)
+
+		// Entities: runs of capitalised words, ignoring a lone capitalised
+		// stopword at the start of a sentence
+		var run []string
+		flush := func() {
+			if len(run) > 0 && len(run) <= 4 {
+				phrase := strings.Join(run, " ")
+				if !(len(run) == 1 && stopwords[strings.ToLower(phrase)]) && len(phrase) > 1 {
+					terms[strings.ToLower(phrase)] = extractedTerm{display: phrase, kind: "entity"}
+				}
+			}
+			run = run[:0]
+		}
+		if withEntities {
+			for _, word := range words {
+				if isEntityWord(word) && !stopwords[strings.ToLower(word)] {
+					run = append(run, word)
+				} else {
+					flush()
+				}
+			}
+			flush()
+		}
+
+		// Keywords: content words and adjacent pairs of content words
+		var previous string
+		for _, word := range words {
+			lower := strings.ToLower(word)
+			if stopwords[lower] || len([]rune(lower)) < 3 || isNumeric(lower) {
+				previous = ""
+				continue
+			}
+
+			if _, ok := terms[lower]; !ok {
+				terms[lower] = extractedTerm{display: lower, kind: "keyword"}
+			}
+			if previous != "" {
+				pair := previous + " " + lower
+				if _, ok := terms[pair]; !ok {
+					terms[pair] = extractedTerm{display: pair, kind: "keyword"}
+				}
+			}
+			previous = lower
+		}
+	}
+}
+
+// isTitleCase reports whether most words in text start with a capital letter
+func isTitleCase(text string) bool {
+	words := tokenize(text)
+	if len(words) < 3 {
+		return false
+	}
+
+	capitalised := 0
+	for _, word := range words {
+		if isEntityWord(word) {
+			capitalised++
+		}
+	}
+	return capitalised*10 >= len(words)*6
+}
+
+// splitSentences splits text on sentence-ending punctuation
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '!' || r == '?' || r == '\n' || r == ':' || r == ';'
+	})
+}
+
+// tokenize splits a sentence into words, keeping inner hyphens and digits
+// so names like "GPT-5" and "M3" survive
+func tokenize(sentence string) []string {
+	fields := strings.FieldsFunc(sentence, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '-' && r != '\''
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.Trim(field, "-'")
+		field = strings.TrimSuffix(field, "'s")
+		if field != "" {
+			words = append(words, field)
+		}
+	}
+	return words
+}
+
+// isEntityWord reports whether word looks like part of a name
+func isEntityWord(word string) bool {
+	for _, r := range word {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
+
+// isNumeric reports whether word is made only of digits
+func isNumeric(word string) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// stopwords are common English and newsroom words that never make a trend
+var stopwords = toSet(
+	"a", "about", "above", "after", "again", "against", "all", "also", "am", "an", "and", "any",
+	"are", "as", "at", "be", "because", "been", "before", "being", "below", "between", "both",
+	"but", "by", "can", "could", "did", "do", "does", "doing", "down", "during", "each", "even",
+	"few", "for", "from", "further", "get", "gets", "got", "had", "has", "have", "having", "he",
+	"her", "here", "hers", "him", "his", "how", "i", "if", "in", "into", "is", "it", "its",
+	"itself", "just", "like", "made", "make", "makes", "many", "may", "me", "might", "more",
+	"most", "much", "must", "my", "no", "nor", "not", "now", "of", "off", "on", "once", "one",
+	"only", "or", "other", "our", "ours", "out", "over", "own", "same", "she", "should", "so",
+	"some", "still", "such", "than", "that", "the", "their", "theirs", "them", "then", "there",
+	"these", "they", "this", "those", "through", "to", "too", "under", "until", "up", "us",
+	"very", "was", "way", "we", "were", "what", "when", "where", "which", "while", "who", "whom",
+	"why", "will", "with", "without", "would", "yet", "you", "your", "yours",
+	// Newsroom filler
+	"according", "announced", "announces", "back", "big", "chars", "company", "day", "days",
+	"first", "last", "latest", "launch", "launches", "new", "news", "next", "people", "read",
+	"report", "reported", "reports", "said", "say", "says", "set", "since", "time", "today",
+	"two", "week", "weeks", "year", "years", "monday", "tuesday", "wednesday", "thursday",
+	"friday", "saturday", "sunday",
+)
+
+// toSet builds a lookup set from words
+func toSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
@@ -0,0 +1,143 @@
+package agents
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrendEngineDetect(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// story is an article published age ago
+	story := func(title string, age time.Duration) NewsItem {
+		return NewsItem{Title: title, PublishedAt: now.Add(-age)}
+	}
+	undated := func(title string) NewsItem {
+		return NewsItem{Title: title}
+	}
+	repeat := func(n int, item NewsItem) []NewsItem {
+		items := make([]NewsItem, n)
+		for i := range items {
+			items[i] = item
+		}
+		return items
+	}
+	concat := func(groups ...[]NewsItem) []NewsItem {
+		var all []NewsItem
+		for _, group := range groups {
+			all = append(all, group...)
+		}
+		return all
+	}
+
+	tests := []struct {
+		name string
+		news []NewsItem
+		want []Trend
+	}{
+		{
+			name: "weight halves every half-life",
+			news: concat(
+				repeat(2, story("Quantum", 0)),
+				repeat(2, story("Fusion", 12*time.Hour)),
+			),
+			want: []Trend{
+				{Term: "Quantum", Kind: "entity", Score: 5, CurrentCount: 2, Acceleration: 2},
+				{Term: "Fusion", Kind: "entity", Score: 2.5, CurrentCount: 2, Acceleration: 2},
+			},
+		},
+		{
+			name: "compares the current window with the previous one",
+			news: concat(
+				repeat(3, story("Rust", 0)),
+				repeat(1, story("Rust", 30*time.Hour)),
+				// As common as the day before, so not rising
+				repeat(2, story("Cobol", 0)),
+				repeat(2, story("Cobol", 30*time.Hour)),
+				// Older than both windows, so ignored
+				repeat(2, story("Perl", 0)),
+				repeat(3, story("Perl", 72*time.Hour)),
+			),
+			want: []Trend{
+				{Term: "Rust", Kind: "entity", Score: 5.94, CurrentCount: 3, PreviousCount: 1, Acceleration: 1},
+				{Term: "Perl", Kind: "entity", Score: 5, CurrentCount: 2, Acceleration: 2},
+			},
+		},
+		{
+			name: "undated stories count as current at a reduced weight",
+			news: concat(
+				repeat(2, undated("Undated")),
+				// Future dates are clamped to now
+				repeat(2, story("Future", -time.Hour)),
+			),
+			want: []Trend{
+				{Term: "Future", Kind: "entity", Score: 5, CurrentCount: 2, Acceleration: 2},
+				{Term: "Undated", Kind: "entity", Score: 2.5, CurrentCount: 2, Acceleration: 2},
+			},
+		},
+		{
+			name: "collapses words into the phrases they trend with",
+			news: concat(
+				repeat(2, story("vision pro", 0)),
+				repeat(1, story("vision", 0)),
+			),
+			want: []Trend{
+				// "vision" has articles of its own, "pro" only trends as part of the phrase
+				{Term: "vision", Kind: "keyword", Score: 6.97, CurrentCount: 3, Acceleration: 3},
+				{Term: "vision pro", Kind: "keyword", Score: 4, CurrentCount: 2, Acceleration: 2},
+			},
+		},
+		{
+			name: "needs more than one article",
+			news: []NewsItem{story("Lonely", 0)},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewTrendEngine()
+			engine.Now = func() time.Time { return now }
+
+			trends := engine.Detect(tt.news, 0)
+			if len(trends) != len(tt.want) {
+				t.Fatalf("got %d trends %v, want %d", len(trends), terms(trends), len(tt.want))
+			}
+			for i, got := range trends {
+				got.Articles = nil
+				if !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("trend %d = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrendEngineDetectLimit(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	engine := NewTrendEngine()
+	engine.Now = func() time.Time { return now }
+
+	var news []NewsItem
+	for _, title := range []string{"Quantum", "Quantum", "Fusion", "Fusion", "Solar", "Solar"} {
+		news = append(news, NewsItem{Title: title, URL: "https://example.com/" + title, PublishedAt: now})
+	}
+
+	trends := engine.Detect(news, 2)
+	if len(trends) != 2 {
+		t.Fatalf("got trends %v, want 2", terms(trends))
+	}
+	if len(trends[0].Articles) != 1 {
+		t.Errorf("supporting articles = %d, want repeated headlines listed once", len(trends[0].Articles))
+	}
+}
+
+// terms lists the terms of trends for failure messages
+func terms(trends []Trend) []string {
+	var list []string
+	for _, trend := range trends {
+		list = append(list, trend.Term)
+	}
+	return list
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AccountInsightsSnapshot is one day's insights of an account
+type AccountInsightsSnapshot struct {
+	ID        int       `json:"id"`
+	AccountID int       `json:"account_id"`
+	Date      time.Time `json:"date"`
+	// FollowersCount, FollowsCount and MediaCount are the totals when the
+	// snapshot was taken
+	FollowersCount int `json:"followers_count"`
+	FollowsCount   int `json:"follows_count"`
+	MediaCount     int `json:"media_count"`
+	// The metrics are nil when the API doesn't offer them for the account
+	NewFollowers  *int `json:"new_followers"`
+	ProfileViews  *int `json:"profile_views"`
+	WebsiteClicks *int `json:"website_clicks"`
+	ReachDay      *int `json:"reach_day"`
+	ReachWeek     *int `json:"reach_week"`
+	Reach28Days   *int `json:"reach_28_days"`
+	// Audience breakdowns are counts of followers keyed by city, country and
+	// gender.age
+	AudienceCities    map[string]int `json:"audience_cities"`
+	AudienceCountries map[string]int `json:"audience_countries"`
+	AudienceAgeGender map[string]int `json:"audience_age_gender"`
+	RecordedAt        time.Time      `json:"recorded_at"`
+}
+
+// FollowerGrowthPoint is one day of an account's follower growth curve
+type FollowerGrowthPoint struct {
+	Date      time.Time `json:"date"`
+	Followers int       `json:"followers"`
+	// Change is the difference from the previous snapshot, or nil for the first
+	Change *int `json:"change"`
+	// NewFollowers, Reach and ProfileViews are nil when they weren't available
+	NewFollowers *int `json:"new_followers"`
+	Reach        *int `json:"reach"`
+	ProfileViews *int `json:"profile_views"`
+	// PostsPublished is how many of the account's posts went out that day
+	PostsPublished int `json:"posts_published"`
+}
+
+// accountInsightsColumns are the columns selected by scanAccountInsights, in order
+const accountInsightsColumns = `id, account_id, date, followers_count, follows_count, media_count,
+	new_followers, profile_views, website_clicks, reach_day, reach_week, reach_28_days,
+	audience_cities, audience_countries, audience_age_gender, recorded_at`
+
+// scanAccountInsights scans a row selected with accountInsightsColumns
+func scanAccountInsights(row rowScanner, snapshot *AccountInsightsSnapshot) error {
+	var cities, countries, ageGender []byte
+
+	err := row.Scan(
+		&snapshot.ID,
+		&snapshot.AccountID,
+		&snapshot.Date,
+		&snapshot.FollowersCount,
+		&snapshot.FollowsCount,
+		&snapshot.MediaCount,
+		&snapshot.NewFollowers,
+		&snapshot.ProfileViews,
+		&snapshot.WebsiteClicks,
+		&snapshot.ReachDay,
+		&snapshot.ReachWeek,
+		&snapshot.Reach28Days,
+		&cities,
+		&countries,
+		&ageGender,
+		&snapshot.RecordedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(cities, &snapshot.AudienceCities); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(countries, &snapshot.AudienceCountries); err != nil {
+		return err
+	}
+	return json.Unmarshal(ageGender, &snapshot.AudienceAgeGender)
+}
+
+// audienceJSON encodes an audience breakdown, storing nil as an empty object
+func audienceJSON(values map[string]int) ([]byte, error) {
+	if values == nil {
+		values = map[string]int{}
+	}
+	return json.Marshal(values)
+}
+
+// SaveAccountInsightsSnapshot stores the snapshot of an account for a day,
+// replacing one taken earlier the same day
+func (db *DB) SaveAccountInsightsSnapshot(snapshot *AccountInsightsSnapshot) error {
+	cities, err := audienceJSON(snapshot.AudienceCities)
+	if err != nil {
+		return err
+	}
+	countries, err := audienceJSON(snapshot.AudienceCountries)
+	if err != nil {
+		return err
+	}
+	ageGender, err := audienceJSON(snapshot.AudienceAgeGender)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO account_insights (account_id, date, followers_count, follows_count, media_count,
+			new_followers, profile_views, website_clicks, reach_day, reach_week, reach_28_days,
+			audience_cities, audience_countries, audience_age_gender)
+		VALUES ($1, $2::DATE, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (account_id, date) DO UPDATE SET
+			followers_count = EXCLUDED.followers_count,
+			follows_count = EXCLUDED.follows_count,
+			media_count = EXCLUDED.media_count,
+			new_followers = EXCLUDED.new_followers,
+			profile_views = EXCLUDED.profile_views,
+			website_clicks = EXCLUDED.website_clicks,
+			reach_day = EXCLUDED.reach_day,
+			reach_week = EXCLUDED.reach_week,
+			reach_28_days = EXCLUDED.reach_28_days,
+			audience_cities = EXCLUDED.audience_cities,
+			audience_countries = EXCLUDED.audience_countries,
+			audience_age_gender = EXCLUDED.audience_age_gender,
+			recorded_at = NOW()
+		RETURNING id, date, recorded_at
+	`
+
+	return db.QueryRow(
+		query,
+		snapshot.AccountID,
+		snapshot.Date.Format("2006-01-02"),
+		snapshot.FollowersCount,
+		snapshot.FollowsCount,
+		snapshot.MediaCount,
+		snapshot.NewFollowers,
+		snapshot.ProfileViews,
+		snapshot.WebsiteClicks,
+		snapshot.ReachDay,
+		snapshot.ReachWeek,
+		snapshot.Reach28Days,
+		cities,
+		countries,
+		ageGender,
+	).Scan(&snapshot.ID, &snapshot.Date, &snapshot.RecordedAt)
+}
+
+// GetAccountInsightsSnapshots gets the snapshots of an account from since on, oldest first
+func (db *DB) GetAccountInsightsSnapshots(accountID int, since time.Time) ([]AccountInsightsSnapshot, error) {
+	query := `
+		SELECT ` + accountInsightsColumns + `
+		FROM account_insights
+		WHERE account_id = $1 AND date >= $2::DATE
+		ORDER BY date
+	`
+
+	rows, err := db.Query(query, accountID, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []AccountInsightsSnapshot{}
+	for rows.Next() {
+		var snapshot AccountInsightsSnapshot
+		if err := scanAccountInsights(rows, &snapshot); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// GetAccountsWithoutSnapshot gets the accounts that have no snapshot for a day
+func (db *DB) GetAccountsWithoutSnapshot(date time.Time) ([]Account, error) {
+	query := `
+		SELECT ` + accountColumns + `
+		FROM accounts a
+		WHERE NOT EXISTS (
+			SELECT 1 FROM account_insights s WHERE s.account_id = a.id AND s.date = $1::DATE
+		)
+		ORDER BY id
+	`
+
+	rows, err := db.Query(query, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []Account
+	for rows.Next() {
+		var account Account
+		if err := scanAccount(rows, &account); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// GetFollowerGrowth gets the follower growth curve of an account from since
+// on, with the number of posts published each day alongside
+func (db *DB) GetFollowerGrowth(accountID int, since time.Time) ([]FollowerGrowthPoint, error) {
+	query := `
+		SELECT s.date, s.followers_count, s.change, s.new_followers, s.reach_day, s.profile_views,
+			COALESCE(p.published, 0)
+		FROM (
+			SELECT date, followers_count, new_followers, reach_day, profile_views,
+				followers_count - LAG(followers_count) OVER (ORDER BY date) AS change
+			FROM account_insights
+			WHERE account_id = $1
+		) s
+		LEFT JOIN (
+			SELECT posted_at::DATE AS day, COUNT(*) AS published
+			FROM posts
+			WHERE account_id = $1 AND status = 'posted' AND posted_at IS NOT NULL
+			GROUP BY posted_at::DATE
+		) p ON p.day = s.date
+		WHERE s.date >= $2::DATE
+		ORDER BY s.date
+	`
+
+	rows, err := db.Query(query, accountID, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []FollowerGrowthPoint{}
+	for rows.Next() {
+		var point FollowerGrowthPoint
+		err := rows.Scan(
+			&point.Date,
+			&point.Followers,
+			&point.Change,
+			&point.NewFollowers,
+			&point.Reach,
+			&point.ProfileViews,
+			&point.PostsPublished,
+		)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
@@ -0,0 +1,119 @@
+package database
+
+import "time"
+
+// Account is an Instagram account managed by the agents. Its access token is
+// stored in instagram_tokens under InstagramUserID.
+type Account struct {
+	ID              int    `json:"id"`
+	InstagramUserID string `json:"instagram_user_id"`
+	Username        string `json:"username"`
+	Name            string `json:"name"`
+	// Voice and SarcasmLevel set the style drafted comment replies are written in
+	Voice        string    `json:"voice"`
+	SarcasmLevel int       `json:"sarcasm_level"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// accountColumns are the columns selected by scanAccount, in order
+const accountColumns = `id, instagram_user_id, username, name, voice, sarcasm_level, created_at`
+
+// scanAccount scans a row selected with accountColumns
+func scanAccount(row rowScanner, account *Account) error {
+	return row.Scan(
+		&account.ID,
+		&account.InstagramUserID,
+		&account.Username,
+		&account.Name,
+		&account.Voice,
+		&account.SarcasmLevel,
+		&account.CreatedAt,
+	)
+}
+
+// SaveAccount creates an account, or updates the account with the same
+// Instagram user ID. Empty fields leave the stored values unchanged.
+func (db *DB) SaveAccount(account *Account) error {
+	query := `
+		INSERT INTO accounts (instagram_user_id, username, name, voice, sarcasm_level)
+		VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, 0), 5))
+		ON CONFLICT (instagram_user_id) DO UPDATE SET
+			username = COALESCE(NULLIF(EXCLUDED.username, ''), accounts.username),
+			name = COALESCE(NULLIF(EXCLUDED.name, ''), accounts.name),
+			voice = COALESCE(NULLIF($4, ''), accounts.voice),
+			sarcasm_level = COALESCE(NULLIF($5, 0), accounts.sarcasm_level)
+		RETURNING ` + accountColumns
+
+	row := db.QueryRow(query, account.InstagramUserID, account.Username, account.Name, account.Voice, account.SarcasmLevel)
+	return scanAccount(row, account)
+}
+
+// GetAccounts gets all accounts
+func (db *DB) GetAccounts() ([]Account, error) {
+	rows, err := db.Query(`SELECT ` + accountColumns + ` FROM accounts ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []Account
+	for rows.Next() {
+		var account Account
+		if err := scanAccount(rows, &account); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// GetAccount gets an account by ID. It returns sql.ErrNoRows if there is none.
+func (db *DB) GetAccount(id int) (*Account, error) {
+	var account Account
+	if err := scanAccount(db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE id = $1`, id), &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetAccountByInstagramID gets an account by its Instagram user ID.
+// It returns sql.ErrNoRows if there is none.
+func (db *DB) GetAccountByInstagramID(instagramUserID string) (*Account, error) {
+	var account Account
+	row := db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE instagram_user_id = $1`, instagramUserID)
+	if err := scanAccount(row, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// AssignUnownedPosts moves posts and analytics without an account to the given
+// account, returning how many posts were moved
+func (db *DB) AssignUnownedPosts(accountID int) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE posts SET account_id = $1 WHERE account_id IS NULL`, accountID)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE analytics a SET account_id = p.account_id
+		FROM posts p
+		WHERE a.post_id = p.id AND a.account_id IS NULL AND p.account_id IS NOT NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, tx.Commit()
+}
@@ -0,0 +1,183 @@
+package database
+
+import "time"
+
+// Comment is a comment on one of our media
+type Comment struct {
+	ID          int    `json:"id"`
+	AccountID   *int   `json:"account_id"`
+	InstagramID string `json:"instagram_id"`
+	MediaID     string `json:"media_id"`
+	// ParentID is set for replies to another comment
+	ParentID string `json:"parent_id"`
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Text     string `json:"text"`
+	// Live is set for comments made during a live broadcast
+	Live bool `json:"live"`
+	// CommentedAt, LikeCount and Hidden are only known once the comment has been
+	// fetched from Instagram; webhooks don't include them
+	CommentedAt *time.Time `json:"commented_at"`
+	LikeCount   int        `json:"like_count"`
+	Hidden      bool       `json:"hidden"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	// Replies are filled in by GetMediaComments
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+// commentColumns are the columns selected by scanComment, in order
+const commentColumns = `id, account_id, instagram_id, media_id, parent_id, user_id, username, text, live,
+	commented_at, like_count, hidden, deleted_at, received_at`
+
+// scanComment scans a row selected with commentColumns
+func scanComment(row rowScanner, comment *Comment) error {
+	return row.Scan(
+		&comment.ID,
+		&comment.AccountID,
+		&comment.InstagramID,
+		&comment.MediaID,
+		&comment.ParentID,
+		&comment.UserID,
+		&comment.Username,
+		&comment.Text,
+		&comment.Live,
+		&comment.CommentedAt,
+		&comment.LikeCount,
+		&comment.Hidden,
+		&comment.DeletedAt,
+		&comment.ReceivedAt,
+	)
+}
+
+// SaveComment stores a comment, updating the text if it was stored before.
+// Webhooks can be delivered more than once, so saving is idempotent.
+func (db *DB) SaveComment(comment *Comment) error {
+	query := `
+		INSERT INTO comments (account_id, instagram_id, media_id, parent_id, user_id, username, text, live)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (instagram_id) DO UPDATE SET
+			text = EXCLUDED.text,
+			account_id = COALESCE(comments.account_id, EXCLUDED.account_id)
+		RETURNING id, received_at
+	`
+
+	return db.QueryRow(
+		query,
+		comment.AccountID,
+		comment.InstagramID,
+		comment.MediaID,
+		comment.ParentID,
+		comment.UserID,
+		comment.Username,
+		comment.Text,
+		comment.Live,
+	).Scan(&comment.ID, &comment.ReceivedAt)
+}
+
+// SaveFetchedComment stores a comment fetched from Instagram, overwriting the
+// stored copy with its current text, likes and visibility
+func (db *DB) SaveFetchedComment(comment *Comment) error {
+	query := `
+		INSERT INTO comments (account_id, instagram_id, media_id, parent_id, user_id, username, text,
+			commented_at, like_count, hidden)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (instagram_id) DO UPDATE SET
+			account_id = COALESCE(comments.account_id, EXCLUDED.account_id),
+			parent_id = EXCLUDED.parent_id,
+			user_id = EXCLUDED.user_id,
+			username = EXCLUDED.username,
+			text = EXCLUDED.text,
+			commented_at = EXCLUDED.commented_at,
+			like_count = EXCLUDED.like_count,
+			hidden = EXCLUDED.hidden
+		RETURNING id, received_at
+	`
+
+	return db.QueryRow(
+		query,
+		comment.AccountID,
+		comment.InstagramID,
+		comment.MediaID,
+		comment.ParentID,
+		comment.UserID,
+		comment.Username,
+		comment.Text,
+		comment.CommentedAt,
+		comment.LikeCount,
+		comment.Hidden,
+	).Scan(&comment.ID, &comment.ReceivedAt)
+}
+
+// GetComment gets a comment by its Instagram ID. It returns sql.ErrNoRows if there is none.
+func (db *DB) GetComment(instagramID string) (*Comment, error) {
+	var comment Comment
+	row := db.QueryRow(`SELECT `+commentColumns+` FROM comments WHERE instagram_id = $1`, instagramID)
+	if err := scanComment(row, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// GetMediaComments gets the comments on a media that haven't been deleted,
+// oldest first, with replies nested under the comment they reply to
+func (db *DB) GetMediaComments(mediaID string) ([]Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments
+		WHERE media_id = $1 AND deleted_at IS NULL
+		ORDER BY COALESCE(commented_at, received_at), id
+	`
+
+	rows, err := db.Query(query, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Comment
+	for rows.Next() {
+		var comment Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, err
+		}
+		all = append(all, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Replies can only be one level deep on Instagram
+	replies := make(map[string][]Comment)
+	for _, comment := range all {
+		if comment.ParentID != "" {
+			replies[comment.ParentID] = append(replies[comment.ParentID], comment)
+		}
+	}
+
+	comments := []Comment{}
+	for _, comment := range all {
+		if comment.ParentID == "" {
+			comment.Replies = replies[comment.InstagramID]
+			comments = append(comments, comment)
+		}
+	}
+
+	return comments, nil
+}
+
+// SetCommentHidden records whether a comment is hidden
+func (db *DB) SetCommentHidden(instagramID string, hidden bool) error {
+	_, err := db.Exec(`UPDATE comments SET hidden = $1 WHERE instagram_id = $2`, hidden, instagramID)
+	return err
+}
+
+// MarkCommentDeleted records that a comment was deleted, along with its
+// replies which Instagram deletes with it
+func (db *DB) MarkCommentDeleted(instagramID string) error {
+	_, err := db.Exec(`
+		UPDATE comments SET deleted_at = NOW()
+		WHERE (instagram_id = $1 OR parent_id = $1) AND deleted_at IS NULL
+	`, instagramID)
+	return err
+}
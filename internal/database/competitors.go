@@ -0,0 +1,261 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Competitor is another account we benchmark our posting against
+type Competitor struct {
+	ID          int       `json:"id"`
+	Username    string    `json:"username"`
+	InstagramID string    `json:"instagram_id"`
+	Name        string    `json:"name"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Latest is the most recent snapshot, filled in by GetCompetitors
+	Latest *CompetitorSnapshot `json:"latest,omitempty"`
+}
+
+// CompetitorSnapshot is a competitor's public stats at one point in time,
+// based on a sample of their recent media
+type CompetitorSnapshot struct {
+	ID             int     `json:"id"`
+	CompetitorID   int     `json:"competitor_id"`
+	FollowersCount int     `json:"followers_count"`
+	MediaCount     int     `json:"media_count"`
+	MediaSampled   int     `json:"media_sampled"`
+	PostsPerWeek   float64 `json:"posts_per_week"`
+	// Formats counts the sampled media by format
+	Formats        map[string]int `json:"formats"`
+	AvgLikes       float64        `json:"avg_likes"`
+	AvgComments    float64        `json:"avg_comments"`
+	EngagementRate float64        `json:"engagement_rate"`
+	RecordedAt     time.Time      `json:"recorded_at"`
+}
+
+// competitorColumns are the columns selected by scanCompetitor, in order
+const competitorColumns = `id, username, instagram_id, name, created_at`
+
+// scanCompetitor scans a row selected with competitorColumns
+func scanCompetitor(row rowScanner, competitor *Competitor) error {
+	return row.Scan(
+		&competitor.ID,
+		&competitor.Username,
+		&competitor.InstagramID,
+		&competitor.Name,
+		&competitor.CreatedAt,
+	)
+}
+
+// competitorSnapshotColumns are the columns selected by scanCompetitorSnapshot, in order
+const competitorSnapshotColumns = `id, competitor_id, followers_count, media_count, media_sampled,
+	posts_per_week, formats, avg_likes, avg_comments, engagement_rate, recorded_at`
+
+// scanCompetitorSnapshot scans a row selected with competitorSnapshotColumns
+func scanCompetitorSnapshot(row rowScanner, snapshot *CompetitorSnapshot) error {
+	var formats []byte
+
+	err := row.Scan(
+		&snapshot.ID,
+		&snapshot.CompetitorID,
+		&snapshot.FollowersCount,
+		&snapshot.MediaCount,
+		&snapshot.MediaSampled,
+		&snapshot.PostsPerWeek,
+		&formats,
+		&snapshot.AvgLikes,
+		&snapshot.AvgComments,
+		&snapshot.EngagementRate,
+		&snapshot.RecordedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(formats, &snapshot.Formats)
+}
+
+// SaveCompetitor adds a competitor, or updates the Instagram ID and name of
+// the competitor with the same username
+func (db *DB) SaveCompetitor(competitor *Competitor) error {
+	query := `
+		INSERT INTO competitors (username, instagram_id, name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (username) DO UPDATE SET
+			instagram_id = EXCLUDED.instagram_id,
+			name = EXCLUDED.name
+		RETURNING ` + competitorColumns
+
+	return scanCompetitor(db.QueryRow(query, competitor.Username, competitor.InstagramID, competitor.Name), competitor)
+}
+
+// GetCompetitors gets all competitors with their latest snapshot
+func (db *DB) GetCompetitors() ([]Competitor, error) {
+	rows, err := db.Query(`SELECT ` + competitorColumns + ` FROM competitors ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	competitors := []Competitor{}
+	for rows.Next() {
+		var competitor Competitor
+		if err := scanCompetitor(rows, &competitor); err != nil {
+			return nil, err
+		}
+		competitors = append(competitors, competitor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	latest, err := db.latestCompetitorSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for i := range competitors {
+		if snapshot, ok := latest[competitors[i].ID]; ok {
+			competitors[i].Latest = &snapshot
+		}
+	}
+
+	return competitors, nil
+}
+
+// latestCompetitorSnapshots gets the latest snapshot of each competitor, keyed by competitor ID
+func (db *DB) latestCompetitorSnapshots() (map[int]CompetitorSnapshot, error) {
+	query := `
+		SELECT DISTINCT ON (competitor_id) ` + competitorSnapshotColumns + `
+		FROM competitor_snapshots
+		ORDER BY competitor_id, recorded_at DESC
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[int]CompetitorSnapshot)
+	for rows.Next() {
+		var snapshot CompetitorSnapshot
+		if err := scanCompetitorSnapshot(rows, &snapshot); err != nil {
+			return nil, err
+		}
+		latest[snapshot.CompetitorID] = snapshot
+	}
+
+	return latest, rows.Err()
+}
+
+// GetCompetitor gets a competitor by ID. It returns sql.ErrNoRows if there is none.
+func (db *DB) GetCompetitor(id int) (*Competitor, error) {
+	var competitor Competitor
+	if err := scanCompetitor(db.QueryRow(`SELECT `+competitorColumns+` FROM competitors WHERE id = $1`, id), &competitor); err != nil {
+		return nil, err
+	}
+	return &competitor, nil
+}
+
+// DeleteCompetitor removes a competitor and its snapshots. It reports false if
+// there was no such competitor.
+func (db *DB) DeleteCompetitor(id int) (bool, error) {
+	result, err := db.Exec(`DELETE FROM competitors WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+// GetCompetitorsDueForSnapshot gets the competitors without a snapshot taken
+// after the given time
+func (db *DB) GetCompetitorsDueForSnapshot(takenAfter time.Time) ([]Competitor, error) {
+	query := `
+		SELECT ` + competitorColumns + `
+		FROM competitors c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM competitor_snapshots s WHERE s.competitor_id = c.id AND s.recorded_at > $1
+		)
+		ORDER BY id
+	`
+
+	rows, err := db.Query(query, takenAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var competitors []Competitor
+	for rows.Next() {
+		var competitor Competitor
+		if err := scanCompetitor(rows, &competitor); err != nil {
+			return nil, err
+		}
+		competitors = append(competitors, competitor)
+	}
+
+	return competitors, rows.Err()
+}
+
+// SaveCompetitorSnapshot stores a snapshot of a competitor
+func (db *DB) SaveCompetitorSnapshot(snapshot *CompetitorSnapshot) error {
+	formats := snapshot.Formats
+	if formats == nil {
+		formats = map[string]int{}
+	}
+	encoded, err := json.Marshal(formats)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO competitor_snapshots (competitor_id, followers_count, media_count, media_sampled,
+			posts_per_week, formats, avg_likes, avg_comments, engagement_rate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, recorded_at
+	`
+
+	return db.QueryRow(
+		query,
+		snapshot.CompetitorID,
+		snapshot.FollowersCount,
+		snapshot.MediaCount,
+		snapshot.MediaSampled,
+		snapshot.PostsPerWeek,
+		encoded,
+		snapshot.AvgLikes,
+		snapshot.AvgComments,
+		snapshot.EngagementRate,
+	).Scan(&snapshot.ID, &snapshot.RecordedAt)
+}
+
+// GetCompetitorSnapshots gets the snapshots of a competitor taken since the given time, oldest first
+func (db *DB) GetCompetitorSnapshots(competitorID int, since time.Time) ([]CompetitorSnapshot, error) {
+	query := `
+		SELECT ` + competitorSnapshotColumns + `
+		FROM competitor_snapshots
+		WHERE competitor_id = $1 AND recorded_at >= $2
+		ORDER BY recorded_at
+	`
+
+	rows, err := db.Query(query, competitorID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []CompetitorSnapshot{}
+	for rows.Next() {
+		var snapshot CompetitorSnapshot
+		if err := scanCompetitorSnapshot(rows, &snapshot); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
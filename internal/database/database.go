@@ -6,7 +6,7 @@ import (
 	"os"
 	"time"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 )
 
 // DB is a wrapper around sql.DB
@@ -49,60 +49,6 @@ func New() (*DB, error) {
 	return &DB{db}, nil
 }
 
-// Initialize creates the necessary tables if they don't exist
-func (db *DB) Initialize() error {
-	// Create content_ideas table
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS content_ideas (
-			id SERIAL PRIMARY KEY,
-			headline TEXT NOT NULL,
-			content TEXT NOT NULL,
-			talking_points TEXT[] NOT NULL,
-			hashtags TEXT[] NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create posts table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS posts (
-			id SERIAL PRIMARY KEY,
-			instagram_id TEXT,
-			caption TEXT NOT NULL,
-			media_url TEXT,
-			permalink TEXT,
-			status TEXT NOT NULL,
-			scheduled_at TIMESTAMP,
-			posted_at TIMESTAMP,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Create analytics table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS analytics (
-			id SERIAL PRIMARY KEY,
-			post_id INTEGER REFERENCES posts(id),
-			engagement INTEGER NOT NULL,
-			impressions INTEGER NOT NULL,
-			reach INTEGER NOT NULL,
-			saved INTEGER NOT NULL,
-			recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 // ContentIdea represents a content idea in the database
 type ContentIdea struct {
 	ID            int       `json:"id"`
@@ -110,32 +56,72 @@ type ContentIdea struct {
 	Content       string    `json:"content"`
 	TalkingPoints []string  `json:"talking_points"`
 	Hashtags      []string  `json:"hashtags"`
+	SourceURLs    []string  `json:"source_urls"`
 	CreatedAt     time.Time `json:"created_at"`
 }
 
-// SaveContentIdea saves a content idea to the database
+// SaveContentIdea saves a content idea to the database and links it to the
+// stored news items matching its source URLs
 func (db *DB) SaveContentIdea(idea *ContentIdea) error {
+	ideas := []ContentIdea{*idea}
+	if err := db.SaveContentIdeas(ideas); err != nil {
+		return err
+	}
+	*idea = ideas[0]
+	return nil
+}
+
+// SaveContentIdeas saves several content ideas in one transaction, so either
+// all of them are saved or none are, filling in their IDs
+func (db *DB) SaveContentIdeas(ideas []ContentIdea) error {
 	query := `
     INSERT INTO content_ideas (headline, content, talking_points, hashtags)
     VALUES ($1, $2, $3, $4)
     RETURNING id, created_at
 	`
 
-	return db.QueryRow(
-		query,
-		idea.Headline,
-		idea.Content,
-		idea.TalkingPoints,
-		idea.Hashtags,
-	).Scan(&idea.ID, &idea.CreatedAt)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range ideas {
+		idea := &ideas[i]
+		err = tx.QueryRow(
+			query,
+			idea.Headline,
+			idea.Content,
+			pq.Array(idea.TalkingPoints),
+			pq.Array(idea.Hashtags),
+		).Scan(&idea.ID, &idea.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		if len(idea.SourceURLs) > 0 {
+			if err := linkContentIdeaSources(tx, idea.ID, idea.SourceURLs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
 }
 
 // GetContentIdeas gets all content ideas from the database
 func (db *DB) GetContentIdeas() ([]ContentIdea, error) {
 	query := `
-		SELECT id, headline, content, talking_points, hashtags, created_at
-		FROM content_ideas
-		ORDER BY created_at DESC
+		SELECT i.id, i.headline, i.content, i.talking_points, i.hashtags,
+			ARRAY(
+				SELECT n.url FROM content_idea_news l
+				JOIN news_items n ON n.id = l.news_item_id
+				WHERE l.content_idea_id = i.id
+				ORDER BY n.id
+			),
+			i.created_at
+		FROM content_ideas i
+		ORDER BY i.created_at DESC
 	`
 
 	rows, err := db.Query(query)
@@ -151,8 +137,9 @@ func (db *DB) GetContentIdeas() ([]ContentIdea, error) {
 			&idea.ID,
 			&idea.Headline,
 			&idea.Content,
-			&idea.TalkingPoints,
-			&idea.Hashtags,
+			pq.Array(&idea.TalkingPoints),
+			pq.Array(&idea.Hashtags),
+			pq.Array(&idea.SourceURLs),
 			&idea.CreatedAt,
 		)
 		if err != nil {
@@ -166,26 +153,119 @@ func (db *DB) GetContentIdeas() ([]ContentIdea, error) {
 
 // Post represents a post in the database
 type Post struct {
-	ID          int        `json:"id"`
+	ID int `json:"id"`
+	// AccountID is the account the post belongs to; posts from before accounts
+	// existed have none and use the default account
+	AccountID   *int       `json:"account_id"`
 	InstagramID string     `json:"instagram_id"`
 	Caption     string     `json:"caption"`
 	MediaURL    string     `json:"media_url"`
 	Permalink   string     `json:"permalink"`
-	Status      string     `json:"status"` // draft, scheduled, posted
+	Status      string     `json:"status"` // draft, scheduled, publishing, processing, posted, failed
 	ScheduledAt *time.Time `json:"scheduled_at"`
 	PostedAt    *time.Time `json:"posted_at"`
-	CreatedAt   time.Time  `json:"created_at"`
+	LastError   string     `json:"last_error"`
+	MediaType   string     `json:"media_type"` // IMAGE, CAROUSEL_ALBUM or REELS
+	// CoverURL, ThumbOffsetMs and ShareToFeed only apply to Reels
+	CoverURL      string `json:"cover_url"`
+	ThumbOffsetMs int    `json:"thumb_offset_ms"`
+	ShareToFeed   *bool  `json:"share_to_feed"`
+	// ContainerID is the container the post is published from, and
+	// ProcessingStatus tracks a Reel's container while Instagram processes the video
+	ContainerID         string     `json:"container_id"`
+	ProcessingStatus    string     `json:"processing_status"`
+	ProcessingStartedAt *time.Time `json:"processing_started_at"`
+	// ClaimedAt is when publishing the post last started
+	ClaimedAt *time.Time `json:"claimed_at"`
+	// Assets are the ordered slides of a carousel post; single media posts have none
+	Assets    []PostAsset `json:"assets"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// PostAsset is one slide of a carousel post
+type PostAsset struct {
+	ID        int    `json:"id"`
+	PostID    int    `json:"post_id"`
+	Position  int    `json:"position"`
+	MediaURL  string `json:"media_url"`
+	MediaType string `json:"media_type"` // IMAGE or VIDEO
+}
+
+// Post statuses
+const (
+	PostStatusDraft      = "draft"
+	PostStatusScheduled  = "scheduled"
+	PostStatusPublishing = "publishing"
+	PostStatusProcessing = "processing"
+	PostStatusPosted     = "posted"
+	PostStatusFailed     = "failed"
+)
+
+// Post media types
+const (
+	PostMediaImage    = "IMAGE"
+	PostMediaCarousel = "CAROUSEL_ALBUM"
+	PostMediaReels    = "REELS"
+)
+
+// postColumns is the column list scanned by scanPost
+const postColumns = `id, account_id, COALESCE(instagram_id, ''), caption, COALESCE(media_url, ''), COALESCE(permalink, ''),
+		status, scheduled_at, posted_at, last_error, media_type, cover_url, thumb_offset_ms, share_to_feed,
+		container_id, processing_status, processing_started_at, claimed_at, created_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-// SavePost saves a post to the database
+// scanPost scans a row selected with postColumns
+func scanPost(row rowScanner, post *Post) error {
+	return row.Scan(
+		&post.ID,
+		&post.AccountID,
+		&post.InstagramID,
+		&post.Caption,
+		&post.MediaURL,
+		&post.Permalink,
+		&post.Status,
+		&post.ScheduledAt,
+		&post.PostedAt,
+		&post.LastError,
+		&post.MediaType,
+		&post.CoverURL,
+		&post.ThumbOffsetMs,
+		&post.ShareToFeed,
+		&post.ContainerID,
+		&post.ProcessingStatus,
+		&post.ProcessingStartedAt,
+		&post.ClaimedAt,
+		&post.CreatedAt,
+	)
+}
+
+// SavePost saves a post and its carousel assets to the database
 func (db *DB) SavePost(post *Post) error {
 	query := `
-    INSERT INTO posts (instagram_id, caption, media_url, permalink, status, scheduled_at, posted_at)
-    VALUES ($1, $2, $3, $4, $5, $6, $7)
-    RETURNING id, created_at
+    INSERT INTO posts (instagram_id, caption, media_url, permalink, status, scheduled_at, posted_at,
+        media_type, cover_url, thumb_offset_ms, share_to_feed, account_id)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, TRUE), $12)
+    RETURNING id, share_to_feed, created_at
 `
 
-	return db.QueryRow(
+	if post.MediaType == "" {
+		post.MediaType = PostMediaImage
+		if len(post.Assets) > 0 {
+			post.MediaType = PostMediaCarousel
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
 		query,
 		post.InstagramID,
 		post.Caption,
@@ -194,18 +274,169 @@ func (db *DB) SavePost(post *Post) error {
 		post.Status,
 		post.ScheduledAt,
 		post.PostedAt,
-	).Scan(&post.ID, &post.CreatedAt)
+		post.MediaType,
+		post.CoverURL,
+		post.ThumbOffsetMs,
+		post.ShareToFeed,
+		post.AccountID,
+	).Scan(&post.ID, &post.ShareToFeed, &post.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i := range post.Assets {
+		asset := &post.Assets[i]
+		asset.PostID = post.ID
+		asset.Position = i + 1
+		if asset.MediaType == "" {
+			asset.MediaType = "IMAGE"
+		}
+
+		err := tx.QueryRow(`
+			INSERT INTO post_assets (post_id, position, media_url, media_type)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, asset.PostID, asset.Position, asset.MediaURL, asset.MediaType).Scan(&asset.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveImportedPost stores a post that already exists on Instagram, updating the
+// stored copy when the media was imported before. It reports whether the post is new.
+func (db *DB) SaveImportedPost(post *Post) (bool, error) {
+	var existingID int
+	err := db.QueryRow(`
+		UPDATE posts SET caption = $1, media_url = $2, permalink = $3, media_type = $4,
+			account_id = COALESCE(account_id, $6)
+		WHERE instagram_id = $5
+		RETURNING id
+	`, post.Caption, post.MediaURL, post.Permalink, post.MediaType, post.InstagramID, post.AccountID).Scan(&existingID)
+	if err == nil {
+		post.ID = existingID
+		return false, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	if err := db.SavePost(post); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// loadPostAssets fills in the carousel assets of the given posts
+func (db *DB) loadPostAssets(posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(posts))
+	index := make(map[int]int, len(posts))
+	for i, post := range posts {
+		ids = append(ids, int64(post.ID))
+		index[post.ID] = i
+	}
+
+	rows, err := db.Query(`
+		SELECT id, post_id, position, media_url, media_type
+		FROM post_assets
+		WHERE post_id = ANY($1)
+		ORDER BY post_id, position
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var asset PostAsset
+		if err := rows.Scan(&asset.ID, &asset.PostID, &asset.Position, &asset.MediaURL, &asset.MediaType); err != nil {
+			return err
+		}
+		post := &posts[index[asset.PostID]]
+		post.Assets = append(post.Assets, asset)
+	}
+
+	return rows.Err()
 }
 
 // GetPosts gets all posts from the database
 func (db *DB) GetPosts() ([]Post, error) {
-	query := `
-		SELECT id, instagram_id, caption, media_url, permalink, status, scheduled_at, posted_at, created_at
+	return db.queryPosts(`SELECT ` + postColumns + ` FROM posts ORDER BY created_at DESC`)
+}
+
+// GetAccountPosts gets the posts of an account
+func (db *DB) GetAccountPosts(accountID int) ([]Post, error) {
+	return db.queryPosts(`
+		SELECT `+postColumns+`
 		FROM posts
+		WHERE account_id = $1
 		ORDER BY created_at DESC
+	`, accountID)
+}
+
+// queryPosts runs a query selecting postColumns and loads the posts' assets
+func (db *DB) queryPosts(query string, args ...interface{}) ([]Post, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		if err := scanPost(rows, &post); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadPostAssets(posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetPost gets a single post by ID
+func (db *DB) GetPost(id int) (*Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts
+		WHERE id = $1
 	`
 
-	rows, err := db.Query(query)
+	posts := make([]Post, 1)
+	if err := scanPost(db.QueryRow(query, id), &posts[0]); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadPostAssets(posts); err != nil {
+		return nil, err
+	}
+
+	return &posts[0], nil
+}
+
+// GetDuePosts gets the scheduled posts whose scheduled time has passed
+func (db *DB) GetDuePosts(now time.Time) ([]Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts
+		WHERE status = $1 AND scheduled_at <= $2
+		ORDER BY scheduled_at
+	`
+
+	rows, err := db.Query(query, PostStatusScheduled, now)
 	if err != nil {
 		return nil, err
 	}
@@ -214,30 +445,133 @@ func (db *DB) GetPosts() ([]Post, error) {
 	var posts []Post
 	for rows.Next() {
 		var post Post
-		err := rows.Scan(
-			&post.ID,
-			&post.InstagramID,
-			&post.Caption,
-			&post.MediaURL,
-			&post.Permalink,
-			&post.Status,
-			&post.ScheduledAt,
-			&post.PostedAt,
-			&post.CreatedAt,
-		)
-		if err != nil {
+		if err := scanPost(rows, &post); err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadPostAssets(posts); err != nil {
+		return nil, err
+	}
 
 	return posts, nil
 }
 
+// ClaimPostForPublishing marks a post as publishing unless it is already being
+// published or has been posted. It reports whether the claim succeeded, which
+// stops the scheduler and a manual publish from posting the same post twice.
+func (db *DB) ClaimPostForPublishing(id int) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE posts SET status = $1, last_error = '', container_id = '', claimed_at = NOW()
+		WHERE id = $2 AND status IN ($3, $4, $5)
+	`, PostStatusPublishing, id, PostStatusDraft, PostStatusScheduled, PostStatusFailed)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// GetStalePublishingPosts gets the posts claimed for publishing before
+// claimedBefore that are still publishing, which happens when the process
+// publishing them died
+func (db *DB) GetStalePublishingPosts(claimedBefore time.Time) ([]Post, error) {
+	return db.queryPosts(`
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE status = $1 AND (claimed_at IS NULL OR claimed_at < $2)
+		ORDER BY claimed_at
+	`, PostStatusPublishing, claimedBefore)
+}
+
+// GetProcessingPosts gets the posts whose media is still being processed by Instagram
+func (db *DB) GetProcessingPosts() ([]Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts
+		WHERE status = $1
+		ORDER BY processing_started_at
+	`
+
+	rows, err := db.Query(query, PostStatusProcessing)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		var post Post
+		if err := scanPost(rows, &post); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadPostAssets(posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// SetPostContainer records the container a publishing post is about to be
+// published from, so that an interrupted publish can be checked on Instagram
+func (db *DB) SetPostContainer(id int, containerID string) error {
+	_, err := db.Exec(`UPDATE posts SET container_id = $1 WHERE id = $2`, containerID, id)
+	return err
+}
+
+// MarkPostProcessing records the container a post was uploaded to while Instagram processes it
+func (db *DB) MarkPostProcessing(id int, containerID string) error {
+	_, err := db.Exec(`
+		UPDATE posts SET status = $1, container_id = $2, processing_status = 'IN_PROGRESS',
+			processing_started_at = NOW(), last_error = ''
+		WHERE id = $3
+	`, PostStatusProcessing, containerID, id)
+	return err
+}
+
+// UpdatePostProcessingStatus records the latest container status of a processing post
+func (db *DB) UpdatePostProcessingStatus(id int, processingStatus string) error {
+	_, err := db.Exec(`UPDATE posts SET processing_status = $1 WHERE id = $2`, processingStatus, id)
+	return err
+}
+
+// MarkPostPublished records the Instagram media a post was published as
+func (db *DB) MarkPostPublished(id int, instagramID, permalink string, postedAt time.Time) error {
+	_, err := db.Exec(`
+		UPDATE posts SET status = $1, instagram_id = $2, permalink = $3, posted_at = $4, last_error = ''
+		WHERE id = $5
+	`, PostStatusPosted, instagramID, permalink, postedAt, id)
+	return err
+}
+
+// MarkPostFailed records why publishing a post failed
+func (db *DB) MarkPostFailed(id int, reason string) error {
+	_, err := db.Exec(`
+		UPDATE posts SET status = $1, last_error = $2
+		WHERE id = $3
+	`, PostStatusFailed, reason, id)
+	return err
+}
+
 // Analytics represents analytics data in the database
 type Analytics struct {
 	ID          int       `json:"id"`
 	PostID      int       `json:"post_id"`
+	AccountID   *int      `json:"account_id"`
 	Engagement  int       `json:"engagement"`
 	Impressions int       `json:"impressions"`
 	Reach       int       `json:"reach"`
@@ -248,9 +582,9 @@ type Analytics struct {
 // SaveAnalytics saves analytics data to the database
 func (db *DB) SaveAnalytics(analytics *Analytics) error {
 	query := `
-    INSERT INTO analytics (post_id, engagement, impressions, reach, saved)
-    VALUES ($1, $2, $3, $4, $5)
-    RETURNING id, recorded_at
+    INSERT INTO analytics (post_id, engagement, impressions, reach, saved, account_id)
+    VALUES ($1, $2, $3, $4, $5, COALESCE($6, (SELECT account_id FROM posts WHERE id = $1)))
+    RETURNING id, account_id, recorded_at
 `
 
 	return db.QueryRow(
@@ -260,19 +594,33 @@ func (db *DB) SaveAnalytics(analytics *Analytics) error {
 		analytics.Impressions,
 		analytics.Reach,
 		analytics.Saved,
-	).Scan(&analytics.ID, &analytics.RecordedAt)
+		analytics.AccountID,
+	).Scan(&analytics.ID, &analytics.AccountID, &analytics.RecordedAt)
 }
 
 // GetAnalyticsForPost gets all analytics data for a post
 func (db *DB) GetAnalyticsForPost(postID int) ([]Analytics, error) {
-	query := `
-    SELECT id, post_id, engagement, impressions, reach, saved, recorded_at
+	return db.queryAnalytics(`
+    SELECT id, post_id, account_id, engagement, impressions, reach, saved, recorded_at
     FROM analytics
     WHERE post_id = $1
     ORDER BY recorded_at DESC
-`
+`, postID)
+}
+
+// GetAccountAnalytics gets all analytics data for the posts of an account
+func (db *DB) GetAccountAnalytics(accountID int) ([]Analytics, error) {
+	return db.queryAnalytics(`
+    SELECT id, post_id, account_id, engagement, impressions, reach, saved, recorded_at
+    FROM analytics
+    WHERE account_id = $1
+    ORDER BY recorded_at DESC
+`, accountID)
+}
 
-	rows, err := db.Query(query, postID)
+// queryAnalytics runs a query selecting analytics rows
+func (db *DB) queryAnalytics(query string, args ...interface{}) ([]Analytics, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -284,6 +632,7 @@ func (db *DB) GetAnalyticsForPost(postID int) ([]Analytics, error) {
 		err := rows.Scan(
 			&data.ID,
 			&data.PostID,
+			&data.AccountID,
 			&data.Engagement,
 			&data.Impressions,
 			&data.Reach,
@@ -296,7 +645,7 @@ func (db *DB) GetAnalyticsForPost(postID int) ([]Analytics, error) {
 		analyticsData = append(analyticsData, data)
 	}
 
-	return analyticsData, nil
+	return analyticsData, rows.Err()
 }
 
 // Add these methods to database.go
@@ -351,24 +700,3 @@ func (db *DB) GetRecentSpeculations(limit int) ([]map[string]interface{}, error)
 
 	return results, nil
 }
-
-// InitSchema initializes the database schema
-func (db *DB) InitSchema() error {
-	// Add speculations table to your existing Initialize method
-	_, err := db.Exec(`
-        CREATE TABLE IF NOT EXISTS speculations (
-            id SERIAL PRIMARY KEY,
-            company VARCHAR(255) NOT NULL,
-            topic VARCHAR(255) NOT NULL,
-            headline TEXT NOT NULL,
-            content TEXT NOT NULL,
-            created_at TIMESTAMP NOT NULL DEFAULT NOW()
-        )
-    `)
-	if err != nil {
-		return err
-	}
-
-	// Call your existing Initialize method
-	return db.Initialize()
-}
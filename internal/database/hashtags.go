@@ -0,0 +1,295 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+// Hashtag is an Instagram hashtag whose ID we have looked up
+type Hashtag struct {
+	HashtagID string `json:"hashtag_id"`
+	Name      string `json:"name"`
+	// Tracked hashtags are snapshotted periodically
+	Tracked   bool      `json:"tracked"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HashtagQuery is a hashtag in an Instagram user's weekly query window
+type HashtagQuery struct {
+	// HashtagID is empty if the hashtag was reserved but its search failed
+	HashtagID string `json:"hashtag_id"`
+	Name      string `json:"name"`
+	// FirstQueriedAt is the first query within the window; the hashtag frees
+	// up its slot a week after it
+	FirstQueriedAt time.Time `json:"first_queried_at"`
+}
+
+// HashtagSnapshot is the activity of a hashtag at one point in time
+type HashtagSnapshot struct {
+	ID        int    `json:"id"`
+	HashtagID string `json:"hashtag_id"`
+	// Top counts are over the hashtag's top media
+	TopMediaCount int `json:"top_media_count"`
+	TopLikes      int `json:"top_likes"`
+	TopComments   int `json:"top_comments"`
+	// Recent counts are over the media tagged in the 24 hours before the snapshot
+	RecentMediaCount int `json:"recent_media_count"`
+	RecentLikes      int `json:"recent_likes"`
+	RecentComments   int `json:"recent_comments"`
+	// RecentMediaCapped is set when there were more recent media than a
+	// snapshot counts, so the recent counts are lower than the real activity
+	RecentMediaCapped bool      `json:"recent_media_capped"`
+	RecordedAt        time.Time `json:"recorded_at"`
+}
+
+// HashtagTrend compares the two latest snapshots of a tracked hashtag
+type HashtagTrend struct {
+	Hashtag
+	Latest   *HashtagSnapshot `json:"latest"`
+	Previous *HashtagSnapshot `json:"previous"`
+	// Heat is the relative change in recent posting volume between the two
+	// snapshots, e.g. 0.5 for 50% more media in the last 24 hours. It is zero
+	// until there are two snapshots.
+	Heat float64 `json:"heat"`
+	// Capped is set when either snapshot hit the recent media cap, which makes
+	// Heat unreliable
+	Capped bool `json:"capped"`
+}
+
+// hashtagColumns are the columns selected by scanHashtag, in order
+const hashtagColumns = `hashtag_id, name, tracked, created_at`
+
+// scanHashtag scans a row selected with hashtagColumns
+func scanHashtag(row rowScanner, hashtag *Hashtag) error {
+	return row.Scan(&hashtag.HashtagID, &hashtag.Name, &hashtag.Tracked, &hashtag.CreatedAt)
+}
+
+// SaveHashtag stores the ID of a hashtag, keeping whether it is tracked
+func (db *DB) SaveHashtag(hashtag *Hashtag) error {
+	query := `
+		INSERT INTO hashtags (hashtag_id, name)
+		VALUES ($1, $2)
+		ON CONFLICT (hashtag_id) DO UPDATE SET name = EXCLUDED.name
+		RETURNING ` + hashtagColumns
+
+	return scanHashtag(db.QueryRow(query, hashtag.HashtagID, hashtag.Name), hashtag)
+}
+
+// GetHashtagByName gets a hashtag by its normalized name. It returns
+// sql.ErrNoRows if its ID hasn't been looked up yet.
+func (db *DB) GetHashtagByName(name string) (*Hashtag, error) {
+	var hashtag Hashtag
+	if err := scanHashtag(db.QueryRow(`SELECT `+hashtagColumns+` FROM hashtags WHERE name = $1`, name), &hashtag); err != nil {
+		return nil, err
+	}
+	return &hashtag, nil
+}
+
+// GetTrackedHashtags gets the tracked hashtags by name
+func (db *DB) GetTrackedHashtags() ([]Hashtag, error) {
+	rows, err := db.Query(`SELECT ` + hashtagColumns + ` FROM hashtags WHERE tracked ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashtags := []Hashtag{}
+	for rows.Next() {
+		var hashtag Hashtag
+		if err := scanHashtag(rows, &hashtag); err != nil {
+			return nil, err
+		}
+		hashtags = append(hashtags, hashtag)
+	}
+
+	return hashtags, rows.Err()
+}
+
+// SetHashtagTracked starts or stops tracking a hashtag. It reports false if
+// the hashtag hasn't been looked up.
+func (db *DB) SetHashtagTracked(name string, tracked bool) (bool, error) {
+	result, err := db.Exec(`UPDATE hashtags SET tracked = $1 WHERE name = $2`, tracked, name)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// ReserveHashtag adds a query of a hashtag by an Instagram user to the ledger,
+// unless the hashtag isn't in the user's window since the given time and the
+// window already holds limit hashtags. A per-user advisory lock serializes
+// reservations, so that two reserving at once can't both take the last slot.
+// It implements instagram.HashtagLedger.
+func (db *DB) ReserveHashtag(instagramUserID, name string, since time.Time, limit int) (bool, time.Time, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	// The insert below must see reservations committed while it waited for
+	// the lock, so the lock is taken in a statement of its own
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('hashtag_queries'), hashtext($1))`, instagramUserID); err != nil {
+		return false, time.Time{}, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO hashtag_queries (instagram_user_id, name, hashtag_id)
+		SELECT $1, $2, (SELECT hashtag_id FROM hashtags WHERE name = $2)
+		WHERE EXISTS (
+			SELECT 1 FROM hashtag_queries WHERE instagram_user_id = $1 AND name = $2 AND queried_at > $3
+		) OR (
+			SELECT COUNT(DISTINCT name) FROM hashtag_queries WHERE instagram_user_id = $1 AND queried_at > $3
+		) < $4
+	`, instagramUserID, name, since, limit)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	reserved, err := result.RowsAffected()
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	if reserved == 0 {
+		var oldest time.Time
+		err := tx.QueryRow(`
+			SELECT MIN(queried_at) FROM hashtag_queries WHERE instagram_user_id = $1 AND queried_at > $2
+		`, instagramUserID, since).Scan(&oldest)
+		return false, oldest, err
+	}
+
+	return true, time.Time{}, tx.Commit()
+}
+
+// GetHashtagQueries gets the unique hashtags an Instagram user has queried
+// since the given time, the longest queried first
+func (db *DB) GetHashtagQueries(instagramUserID string, since time.Time) ([]HashtagQuery, error) {
+	query := `
+		SELECT COALESCE(h.hashtag_id, ''), q.name, MIN(q.queried_at)
+		FROM hashtag_queries q
+		LEFT JOIN hashtags h ON h.name = q.name
+		WHERE q.instagram_user_id = $1 AND q.queried_at > $2
+		GROUP BY h.hashtag_id, q.name
+		ORDER BY MIN(q.queried_at)
+	`
+
+	rows, err := db.Query(query, instagramUserID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queries := []HashtagQuery{}
+	for rows.Next() {
+		var q HashtagQuery
+		if err := rows.Scan(&q.HashtagID, &q.Name, &q.FirstQueriedAt); err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+
+	return queries, rows.Err()
+}
+
+// SaveHashtagSnapshot stores a snapshot of a hashtag's activity
+func (db *DB) SaveHashtagSnapshot(snapshot *HashtagSnapshot) error {
+	query := `
+		INSERT INTO hashtag_snapshots (hashtag_id, top_media_count, top_likes, top_comments,
+			recent_media_count, recent_likes, recent_comments, recent_media_capped)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, recorded_at
+	`
+
+	return db.QueryRow(
+		query,
+		snapshot.HashtagID,
+		snapshot.TopMediaCount,
+		snapshot.TopLikes,
+		snapshot.TopComments,
+		snapshot.RecentMediaCount,
+		snapshot.RecentLikes,
+		snapshot.RecentComments,
+		snapshot.RecentMediaCapped,
+	).Scan(&snapshot.ID, &snapshot.RecordedAt)
+}
+
+// GetHashtagTrends compares the two latest snapshots of every tracked hashtag,
+// hottest first
+func (db *DB) GetHashtagTrends() ([]HashtagTrend, error) {
+	hashtags, err := db.GetTrackedHashtags()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, hashtag_id, top_media_count, top_likes, top_comments,
+			recent_media_count, recent_likes, recent_comments, recent_media_capped, recorded_at
+		FROM (
+			SELECT s.*, ROW_NUMBER() OVER (PARTITION BY s.hashtag_id ORDER BY s.recorded_at DESC) AS rank
+			FROM hashtag_snapshots s
+			JOIN hashtags h ON h.hashtag_id = s.hashtag_id
+			WHERE h.tracked
+		) ranked
+		WHERE rank <= 2
+		ORDER BY hashtag_id, recorded_at DESC
+	`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make(map[string][]HashtagSnapshot)
+	for rows.Next() {
+		var s HashtagSnapshot
+		err := rows.Scan(
+			&s.ID,
+			&s.HashtagID,
+			&s.TopMediaCount,
+			&s.TopLikes,
+			&s.TopComments,
+			&s.RecentMediaCount,
+			&s.RecentLikes,
+			&s.RecentComments,
+			&s.RecentMediaCapped,
+			&s.RecordedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[s.HashtagID] = append(snapshots[s.HashtagID], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	trends := make([]HashtagTrend, 0, len(hashtags))
+	for _, hashtag := range hashtags {
+		trend := HashtagTrend{Hashtag: hashtag}
+		recent := snapshots[hashtag.HashtagID]
+		if len(recent) > 0 {
+			trend.Latest = &recent[0]
+		}
+		if len(recent) > 1 {
+			trend.Previous = &recent[1]
+			previous := trend.Previous.RecentMediaCount
+			if previous < 1 {
+				previous = 1
+			}
+			trend.Heat = float64(trend.Latest.RecentMediaCount-trend.Previous.RecentMediaCount) / float64(previous)
+			trend.Capped = trend.Latest.RecentMediaCapped || trend.Previous.RecentMediaCapped
+		}
+		trends = append(trends, trend)
+	}
+
+	sort.SliceStable(trends, func(i, j int) bool {
+		return trends[i].Heat > trends[j].Heat
+	})
+
+	return trends, nil
+}
@@ -0,0 +1,466 @@
+package database
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps ideas, posts, analytics and speculations in memory. It
+// lets the server and tests run without Postgres; nothing survives a restart.
+type MemoryStore struct {
+	mu           sync.Mutex
+	ideas        []ContentIdea
+	posts        []Post
+	analytics    []Analytics
+	speculations []memorySpeculation
+	// lastIDs are the last IDs given out per table, as each table has its own sequence
+	lastIDs map[string]int
+}
+
+// memorySpeculation is a speculation held by a MemoryStore
+type memorySpeculation struct {
+	id                                int
+	company, topic, headline, content string
+	createdAt                         time.Time
+}
+
+var (
+	_ IdeaRepository        = (*MemoryStore)(nil)
+	_ PostRepository        = (*MemoryStore)(nil)
+	_ AnalyticsRepository   = (*MemoryStore)(nil)
+	_ SpeculationRepository = (*MemoryStore)(nil)
+)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lastIDs: make(map[string]int)}
+}
+
+// NewMemoryRepositories returns repositories backed by a new in-memory store
+func NewMemoryRepositories() Repositories {
+	store := NewMemoryStore()
+	return Repositories{
+		Ideas:        store,
+		Posts:        store,
+		Analytics:    store,
+		Speculations: store,
+	}
+}
+
+// nextID returns a new row ID for a table
+func (m *MemoryStore) nextID(table string) int {
+	m.lastIDs[table]++
+	return m.lastIDs[table]
+}
+
+// SaveContentIdea stores a content idea. Source URLs are only linked to stored
+// news items, which a MemoryStore has none of, so they are dropped as the
+// database would drop URLs of unknown news.
+func (m *MemoryStore) SaveContentIdea(idea *ContentIdea) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saveContentIdea(idea)
+	return nil
+}
+
+// SaveContentIdeas stores several content ideas, filling in their IDs
+func (m *MemoryStore) SaveContentIdeas(ideas []ContentIdea) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range ideas {
+		m.saveContentIdea(&ideas[i])
+	}
+	return nil
+}
+
+// saveContentIdea stores a content idea without its source URLs
+func (m *MemoryStore) saveContentIdea(idea *ContentIdea) {
+	idea.ID = m.nextID("content_ideas")
+	idea.CreatedAt = time.Now()
+
+	stored := copyContentIdea(*idea)
+	stored.SourceURLs = nil
+	m.ideas = append(m.ideas, stored)
+}
+
+// GetContentIdeas gets all content ideas, newest first
+func (m *MemoryStore) GetContentIdeas() ([]ContentIdea, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ideas []ContentIdea
+	for i := len(m.ideas) - 1; i >= 0; i-- {
+		ideas = append(ideas, copyContentIdea(m.ideas[i]))
+	}
+	return ideas, nil
+}
+
+// copyContentIdea copies an idea so callers can't change stored slices
+func copyContentIdea(idea ContentIdea) ContentIdea {
+	idea.TalkingPoints = append([]string(nil), idea.TalkingPoints...)
+	idea.Hashtags = append([]string(nil), idea.Hashtags...)
+	idea.SourceURLs = append([]string(nil), idea.SourceURLs...)
+	return idea
+}
+
+// SavePost stores a post and its carousel assets
+func (m *MemoryStore) SavePost(post *Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.savePost(post)
+	return nil
+}
+
+// savePost stores a post, filling in the defaults the posts table would
+func (m *MemoryStore) savePost(post *Post) {
+	if post.MediaType == "" {
+		post.MediaType = PostMediaImage
+		if len(post.Assets) > 0 {
+			post.MediaType = PostMediaCarousel
+		}
+	}
+	if post.ShareToFeed == nil {
+		shareToFeed := true
+		post.ShareToFeed = &shareToFeed
+	}
+
+	post.ID = m.nextID("posts")
+	post.CreatedAt = time.Now()
+	for i := range post.Assets {
+		asset := &post.Assets[i]
+		asset.ID = m.nextID("post_assets")
+		asset.PostID = post.ID
+		asset.Position = i + 1
+		if asset.MediaType == "" {
+			asset.MediaType = "IMAGE"
+		}
+	}
+
+	m.posts = append(m.posts, copyPost(*post))
+}
+
+// SaveImportedPost stores a post that already exists on Instagram, updating the
+// stored copy when the media was imported before. It reports whether the post is new.
+func (m *MemoryStore) SaveImportedPost(post *Post) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.posts {
+		existing := &m.posts[i]
+		if existing.InstagramID == "" || existing.InstagramID != post.InstagramID {
+			continue
+		}
+
+		existing.Caption = post.Caption
+		existing.MediaURL = post.MediaURL
+		existing.Permalink = post.Permalink
+		existing.MediaType = post.MediaType
+		if existing.AccountID == nil {
+			existing.AccountID = copyInt(post.AccountID)
+		}
+		post.ID = existing.ID
+		return false, nil
+	}
+
+	m.savePost(post)
+	return true, nil
+}
+
+// GetPosts gets all posts, newest first
+func (m *MemoryStore) GetPosts() ([]Post, error) {
+	return m.newestPosts(func(post *Post) bool { return true }), nil
+}
+
+// GetAccountPosts gets the posts of an account, newest first
+func (m *MemoryStore) GetAccountPosts(accountID int) ([]Post, error) {
+	return m.newestPosts(func(post *Post) bool {
+		return post.AccountID != nil && *post.AccountID == accountID
+	}), nil
+}
+
+// newestPosts gets the posts matching keep, newest first
+func (m *MemoryStore) newestPosts(keep func(post *Post) bool) []Post {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var posts []Post
+	for i := len(m.posts) - 1; i >= 0; i-- {
+		if keep(&m.posts[i]) {
+			posts = append(posts, copyPost(m.posts[i]))
+		}
+	}
+	return posts
+}
+
+// GetPost gets a single post by ID
+func (m *MemoryStore) GetPost(id int) (*Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findPost(id)
+	if stored == nil {
+		return nil, sql.ErrNoRows
+	}
+	post := copyPost(*stored)
+	return &post, nil
+}
+
+// GetDuePosts gets the scheduled posts whose scheduled time has passed
+func (m *MemoryStore) GetDuePosts(now time.Time) ([]Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var posts []Post
+	for _, post := range m.posts {
+		if post.Status == PostStatusScheduled && post.ScheduledAt != nil && !post.ScheduledAt.After(now) {
+			posts = append(posts, copyPost(post))
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].ScheduledAt.Before(*posts[j].ScheduledAt)
+	})
+	return posts, nil
+}
+
+// ClaimPostForPublishing marks a post as publishing unless it is already being
+// published or has been posted. It reports whether the claim succeeded.
+func (m *MemoryStore) ClaimPostForPublishing(id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post := m.findPost(id)
+	if post == nil {
+		return false, nil
+	}
+	switch post.Status {
+	case PostStatusDraft, PostStatusScheduled, PostStatusFailed:
+		now := time.Now()
+		post.Status = PostStatusPublishing
+		post.LastError = ""
+		post.ContainerID = ""
+		post.ClaimedAt = &now
+		return true, nil
+	}
+	return false, nil
+}
+
+// GetStalePublishingPosts gets the posts claimed for publishing before
+// claimedBefore that are still publishing
+func (m *MemoryStore) GetStalePublishingPosts(claimedBefore time.Time) ([]Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var posts []Post
+	for _, post := range m.posts {
+		if post.Status == PostStatusPublishing && (post.ClaimedAt == nil || post.ClaimedAt.Before(claimedBefore)) {
+			posts = append(posts, copyPost(post))
+		}
+	}
+	return posts, nil
+}
+
+// GetProcessingPosts gets the posts whose media is still being processed by Instagram
+func (m *MemoryStore) GetProcessingPosts() ([]Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var posts []Post
+	for _, post := range m.posts {
+		if post.Status == PostStatusProcessing {
+			posts = append(posts, copyPost(post))
+		}
+	}
+	// Posts that never started processing sort last, as NULLs do
+	sort.SliceStable(posts, func(i, j int) bool {
+		a, b := posts[i].ProcessingStartedAt, posts[j].ProcessingStartedAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+	return posts, nil
+}
+
+// SetPostContainer records the container a publishing post is about to be published from
+func (m *MemoryStore) SetPostContainer(id int, containerID string) error {
+	return m.updatePost(id, func(post *Post) {
+		post.ContainerID = containerID
+	})
+}
+
+// MarkPostProcessing records the container a post was uploaded to while Instagram processes it
+func (m *MemoryStore) MarkPostProcessing(id int, containerID string) error {
+	return m.updatePost(id, func(post *Post) {
+		now := time.Now()
+		post.Status = PostStatusProcessing
+		post.ContainerID = containerID
+		post.ProcessingStatus = "IN_PROGRESS"
+		post.ProcessingStartedAt = &now
+		post.LastError = ""
+	})
+}
+
+// UpdatePostProcessingStatus records the latest container status of a processing post
+func (m *MemoryStore) UpdatePostProcessingStatus(id int, processingStatus string) error {
+	return m.updatePost(id, func(post *Post) {
+		post.ProcessingStatus = processingStatus
+	})
+}
+
+// MarkPostPublished records the Instagram media a post was published as
+func (m *MemoryStore) MarkPostPublished(id int, instagramID, permalink string, postedAt time.Time) error {
+	return m.updatePost(id, func(post *Post) {
+		post.Status = PostStatusPosted
+		post.InstagramID = instagramID
+		post.Permalink = permalink
+		post.PostedAt = &postedAt
+		post.LastError = ""
+	})
+}
+
+// MarkPostFailed records why publishing a post failed
+func (m *MemoryStore) MarkPostFailed(id int, reason string) error {
+	return m.updatePost(id, func(post *Post) {
+		post.Status = PostStatusFailed
+		post.LastError = reason
+	})
+}
+
+// updatePost applies update to a stored post; like an UPDATE matching no
+// rows, a missing post is not an error
+func (m *MemoryStore) updatePost(id int, update func(post *Post)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if post := m.findPost(id); post != nil {
+		update(post)
+	}
+	return nil
+}
+
+// findPost returns the stored post with the given ID, or nil
+func (m *MemoryStore) findPost(id int) *Post {
+	for i := range m.posts {
+		if m.posts[i].ID == id {
+			return &m.posts[i]
+		}
+	}
+	return nil
+}
+
+// copyPost copies a post so callers can't change the stored one
+func copyPost(post Post) Post {
+	post.AccountID = copyInt(post.AccountID)
+	post.ScheduledAt = copyTime(post.ScheduledAt)
+	post.PostedAt = copyTime(post.PostedAt)
+	post.ProcessingStartedAt = copyTime(post.ProcessingStartedAt)
+	post.ClaimedAt = copyTime(post.ClaimedAt)
+	if post.ShareToFeed != nil {
+		shareToFeed := *post.ShareToFeed
+		post.ShareToFeed = &shareToFeed
+	}
+	post.Assets = append([]PostAsset(nil), post.Assets...)
+	return post
+}
+
+func copyInt(value *int) *int {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+func copyTime(value *time.Time) *time.Time {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+// SaveAnalytics stores analytics data, taking the account from the post if none is set
+func (m *MemoryStore) SaveAnalytics(analytics *Analytics) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if analytics.AccountID == nil {
+		if post := m.findPost(analytics.PostID); post != nil {
+			analytics.AccountID = copyInt(post.AccountID)
+		}
+	}
+	analytics.ID = m.nextID("analytics")
+	analytics.RecordedAt = time.Now()
+
+	stored := *analytics
+	stored.AccountID = copyInt(analytics.AccountID)
+	m.analytics = append(m.analytics, stored)
+	return nil
+}
+
+// GetAnalyticsForPost gets all analytics data for a post, newest first
+func (m *MemoryStore) GetAnalyticsForPost(postID int) ([]Analytics, error) {
+	return m.newestAnalytics(func(data *Analytics) bool {
+		return data.PostID == postID
+	}), nil
+}
+
+// GetAccountAnalytics gets all analytics data for the posts of an account, newest first
+func (m *MemoryStore) GetAccountAnalytics(accountID int) ([]Analytics, error) {
+	return m.newestAnalytics(func(data *Analytics) bool {
+		return data.AccountID != nil && *data.AccountID == accountID
+	}), nil
+}
+
+// newestAnalytics gets the analytics data matching keep, newest first
+func (m *MemoryStore) newestAnalytics(keep func(data *Analytics) bool) []Analytics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var analyticsData []Analytics
+	for i := len(m.analytics) - 1; i >= 0; i-- {
+		if keep(&m.analytics[i]) {
+			data := m.analytics[i]
+			data.AccountID = copyInt(data.AccountID)
+			analyticsData = append(analyticsData, data)
+		}
+	}
+	return analyticsData
+}
+
+// SaveSpeculation stores a speculation
+func (m *MemoryStore) SaveSpeculation(company, topic, headline, content string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.speculations = append(m.speculations, memorySpeculation{
+		id:        m.nextID("speculations"),
+		company:   company,
+		topic:     topic,
+		headline:  headline,
+		content:   content,
+		createdAt: time.Now(),
+	})
+	return nil
+}
+
+// GetRecentSpeculations gets the most recent speculations
+func (m *MemoryStore) GetRecentSpeculations(limit int) ([]map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var results []map[string]interface{}
+	for i := len(m.speculations) - 1; i >= 0 && len(results) < limit; i-- {
+		speculation := m.speculations[i]
+		results = append(results, map[string]interface{}{
+			"id":        speculation.id,
+			"company":   speculation.company,
+			"topic":     speculation.topic,
+			"headline":  speculation.headline,
+			"createdAt": speculation.createdAt.Format(time.RFC3339),
+		})
+	}
+	return results, nil
+}
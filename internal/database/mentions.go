@@ -0,0 +1,209 @@
+package database
+
+import "time"
+
+// Mention kinds
+const (
+	MentionKindCaption = "caption"
+	MentionKindComment = "comment"
+	MentionKindTag     = "tag"
+)
+
+// Mention is a media or comment in which an account was mentioned, or a media
+// it was tagged in
+type Mention struct {
+	ID        int    `json:"id"`
+	AccountID *int   `json:"account_id"`
+	Kind      string `json:"kind"` // caption, comment or tag
+	MediaID   string `json:"media_id"`
+	// CommentID is empty unless the mention is in a comment
+	CommentID string `json:"comment_id"`
+	// Username, Text and the counts describe the media or comment mentioning
+	// us; they are filled in once it has been fetched from Instagram
+	Username      string     `json:"username"`
+	Text          string     `json:"text"`
+	Permalink     string     `json:"permalink"`
+	MediaType     string     `json:"media_type"`
+	LikeCount     int        `json:"like_count"`
+	CommentsCount int        `json:"comments_count"`
+	MentionedAt   *time.Time `json:"mentioned_at"`
+	FetchedAt     *time.Time `json:"fetched_at"`
+	// NeedsResponse is cleared once we reply or dismiss the mention
+	NeedsResponse bool       `json:"needs_response"`
+	RespondedAt   *time.Time `json:"responded_at"`
+	ResponseID    string     `json:"response_id"`
+	ReceivedAt    time.Time  `json:"received_at"`
+}
+
+// mentionColumns are the columns selected by scanMention, in order
+const mentionColumns = `id, account_id, kind, media_id, comment_id, username, text, permalink, media_type,
+	like_count, comments_count, mentioned_at, fetched_at, needs_response, responded_at, response_id, received_at`
+
+// scanMention scans a row selected with mentionColumns
+func scanMention(row rowScanner, mention *Mention) error {
+	return row.Scan(
+		&mention.ID,
+		&mention.AccountID,
+		&mention.Kind,
+		&mention.MediaID,
+		&mention.CommentID,
+		&mention.Username,
+		&mention.Text,
+		&mention.Permalink,
+		&mention.MediaType,
+		&mention.LikeCount,
+		&mention.CommentsCount,
+		&mention.MentionedAt,
+		&mention.FetchedAt,
+		&mention.NeedsResponse,
+		&mention.RespondedAt,
+		&mention.ResponseID,
+		&mention.ReceivedAt,
+	)
+}
+
+// SaveMention stores a mention from a webhook unless it was stored before. The
+// kind is derived from whether there is a comment ID.
+func (db *DB) SaveMention(mention *Mention) error {
+	mention.Kind = MentionKindCaption
+	if mention.CommentID != "" {
+		mention.Kind = MentionKindComment
+	}
+
+	query := `
+		INSERT INTO mentions (account_id, kind, media_id, comment_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (media_id, comment_id, kind) DO UPDATE SET
+			account_id = COALESCE(mentions.account_id, EXCLUDED.account_id)
+		RETURNING ` + mentionColumns
+
+	return scanMention(db.QueryRow(query, mention.AccountID, mention.Kind, mention.MediaID, mention.CommentID), mention)
+}
+
+// SaveFetchedMention stores a mention fetched from Instagram, overwriting the
+// stored details but keeping whether it still needs a response
+func (db *DB) SaveFetchedMention(mention *Mention) error {
+	query := `
+		INSERT INTO mentions (account_id, kind, media_id, comment_id, username, text, permalink, media_type,
+			like_count, comments_count, mentioned_at, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		ON CONFLICT (media_id, comment_id, kind) DO UPDATE SET
+			account_id = COALESCE(mentions.account_id, EXCLUDED.account_id),
+			username = EXCLUDED.username,
+			text = EXCLUDED.text,
+			permalink = EXCLUDED.permalink,
+			media_type = EXCLUDED.media_type,
+			like_count = EXCLUDED.like_count,
+			comments_count = EXCLUDED.comments_count,
+			mentioned_at = EXCLUDED.mentioned_at,
+			fetched_at = EXCLUDED.fetched_at
+		RETURNING ` + mentionColumns
+
+	row := db.QueryRow(
+		query,
+		mention.AccountID,
+		mention.Kind,
+		mention.MediaID,
+		mention.CommentID,
+		mention.Username,
+		mention.Text,
+		mention.Permalink,
+		mention.MediaType,
+		mention.LikeCount,
+		mention.CommentsCount,
+		mention.MentionedAt,
+	)
+	return scanMention(row, mention)
+}
+
+// MentionFilter narrows down GetMentions; zero values match everything
+type MentionFilter struct {
+	AccountID     *int
+	Kind          string
+	NeedsResponse *bool
+}
+
+// GetMentions gets mentions matching filter, newest first
+func (db *DB) GetMentions(filter MentionFilter) ([]Mention, error) {
+	query := `
+		SELECT ` + mentionColumns + `
+		FROM mentions
+		WHERE ($1::INTEGER IS NULL OR account_id = $1)
+			AND ($2 = '' OR kind = $2)
+			AND ($3::BOOLEAN IS NULL OR needs_response = $3)
+		ORDER BY COALESCE(mentioned_at, received_at) DESC, id DESC
+	`
+
+	rows, err := db.Query(query, filter.AccountID, filter.Kind, filter.NeedsResponse)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var mention Mention
+		if err := scanMention(rows, &mention); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+// GetMention gets a mention by ID. It returns sql.ErrNoRows if there is none.
+func (db *DB) GetMention(id int) (*Mention, error) {
+	var mention Mention
+	if err := scanMention(db.QueryRow(`SELECT `+mentionColumns+` FROM mentions WHERE id = $1`, id), &mention); err != nil {
+		return nil, err
+	}
+	return &mention, nil
+}
+
+// GetUnfetchedMentions gets the webhook mentions of an account whose media or
+// comment hasn't been fetched yet, oldest first. A nil accountID matches
+// mentions without an account.
+func (db *DB) GetUnfetchedMentions(accountID *int, limit int) ([]Mention, error) {
+	query := `
+		SELECT ` + mentionColumns + `
+		FROM mentions
+		WHERE fetched_at IS NULL AND kind <> 'tag'
+			AND (account_id = $1 OR ($1::INTEGER IS NULL AND account_id IS NULL))
+		ORDER BY received_at
+		LIMIT $2
+	`
+
+	rows, err := db.Query(query, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mentions []Mention
+	for rows.Next() {
+		var mention Mention
+		if err := scanMention(rows, &mention); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+// MarkMentionResponded records our response to a mention
+func (db *DB) MarkMentionResponded(id int, responseID string) error {
+	_, err := db.Exec(`
+		UPDATE mentions SET needs_response = FALSE, responded_at = NOW(), response_id = $2
+		WHERE id = $1
+	`, id, responseID)
+	return err
+}
+
+// SetMentionNeedsResponse flags or clears a mention as needing a response
+// without responding to it
+func (db *DB) SetMentionNeedsResponse(id int, needsResponse bool) error {
+	_, err := db.Exec(`UPDATE mentions SET needs_response = $2 WHERE id = $1`, id, needsResponse)
+	return err
+}
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// migrationLockKey identifies the advisory lock held while migrating, so that
+// servers starting at the same time don't apply a migration twice
+const migrationLockKey = 7261541
+
+// Migration is a versioned change to the schema. Up applies it and Down
+// reverts it; each may hold several statements.
+type Migration struct {
+	Version     int
+	Description string
+	Up          string
+	Down        string
+}
+
+// MigrationStatus is a migration and when it was applied
+type MigrationStatus struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+	// AppliedAt is nil for pending migrations
+	AppliedAt *time.Time `json:"applied_at"`
+	// Unknown migrations were applied by a newer build
+	Unknown bool `json:"unknown,omitempty"`
+}
+
+// SchemaTooNewError is returned when the database has been migrated by a newer
+// build; running against it could corrupt data written by the newer schema
+type SchemaTooNewError struct {
+	Version int
+	Latest  int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("database schema version %d is newer than version %d, the latest this build knows",
+		e.Version, e.Latest)
+}
+
+func init() {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			panic(fmt.Sprintf("migration %d has version %d", i+1, migration.Version))
+		}
+	}
+}
+
+// LatestSchemaVersion is the schema version once every migration has been applied
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+// SchemaVersion gets the version of the latest applied migration, which is
+// zero for a database that has never been migrated
+func (db *DB) SchemaVersion() (int, error) {
+	var exists bool
+	if err := db.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// CheckSchema reports how many migrations are pending. It returns a
+// *SchemaTooNewError if the database has been migrated by a newer build.
+func (db *DB) CheckSchema() (int, error) {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version > LatestSchemaVersion() {
+		return 0, &SchemaTooNewError{Version: version, Latest: LatestSchemaVersion()}
+	}
+	return LatestSchemaVersion() - version, nil
+}
+
+// GetMigrationStatus gets every known migration, and any unknown ones that
+// have been applied, in version order
+func (db *DB) GetMigrationStatus() ([]MigrationStatus, error) {
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		statuses = append(statuses, MigrationStatus{Version: migration.Version, Description: migration.Description})
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil || version == 0 {
+		return statuses, err
+	}
+
+	rows, err := db.Query(`SELECT version, description, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var applied MigrationStatus
+		if err := rows.Scan(&applied.Version, &applied.Description, &applied.AppliedAt); err != nil {
+			return nil, err
+		}
+		if applied.Version <= len(migrations) {
+			statuses[applied.Version-1].AppliedAt = applied.AppliedAt
+			continue
+		}
+		applied.Unknown = true
+		statuses = append(statuses, applied)
+	}
+
+	return statuses, rows.Err()
+}
+
+// Migrate applies every pending migration
+func (db *DB) Migrate() error {
+	return db.MigrateTo(LatestSchemaVersion())
+}
+
+// MigrateTo applies or reverts migrations until the schema is at the given
+// version. Each migration runs in its own transaction.
+func (db *DB) MigrateTo(version int) error {
+	if version < 0 || version > LatestSchemaVersion() {
+		return fmt.Errorf("schema version must be between 0 and %d, got %d", LatestSchemaVersion(), version)
+	}
+
+	for {
+		done, err := db.migrateStep(version)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// migrateStep applies or reverts the one migration that brings the schema a
+// step closer to target, holding the migration lock. It reports true once the
+// schema is at target.
+func (db *DB) migrateStep(target int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// The lock is released when the transaction ends
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockKey); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return false, err
+	}
+
+	// Read under the lock, since another server may have migrated meanwhile
+	var current int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return false, err
+	}
+	if current > LatestSchemaVersion() {
+		return false, &SchemaTooNewError{Version: current, Latest: LatestSchemaVersion()}
+	}
+
+	switch {
+	case current == target:
+		return true, tx.Commit()
+	case current < target:
+		migration := migrations[current]
+		if _, err := tx.Exec(migration.Up); err != nil {
+			return false, fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`,
+			migration.Version, migration.Description)
+	default:
+		migration := migrations[current-1]
+		if _, err := tx.Exec(migration.Down); err != nil {
+			return false, fmt.Errorf("failed to revert migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return false, tx.Commit()
+}
@@ -0,0 +1,58 @@
+package database
+
+import "testing"
+
+func TestMigrationsUpDownUp(t *testing.T) {
+	db := testDB(t)
+
+	for _, version := range []int{LatestSchemaVersion(), 0, LatestSchemaVersion()} {
+		if err := db.MigrateTo(version); err != nil {
+			t.Fatalf("migrating to %d: %v", version, err)
+		}
+		got, err := db.SchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != version {
+			t.Fatalf("schema version = %d, want %d", got, version)
+		}
+	}
+}
+
+func TestMigrateUntrackedDatabase(t *testing.T) {
+	db := testDB(t)
+	if err := db.MigrateTo(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := db.Migrate(); err != nil {
+			t.Errorf("restoring the schema: %v", err)
+		}
+	})
+
+	// A database from before migrations were tracked has the baseline tables,
+	// possibly with a media linked to several posts
+	if err := db.MigrateTo(1); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(`
+		INSERT INTO posts (instagram_id, caption, status) VALUES ('17900', 'first', 'posted'), ('17900', 'again', 'posted');
+		DROP TABLE schema_migrations;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrating an untracked database: %v", err)
+	}
+
+	var linked, posts int
+	err = db.QueryRow(`SELECT COUNT(instagram_id), COUNT(*) FROM posts WHERE caption IN ('first', 'again')`).Scan(&linked, &posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked != 1 || posts != 2 {
+		t.Errorf("%d of %d posts linked to the media, want 1 of 2", linked, posts)
+	}
+}
@@ -0,0 +1,384 @@
+package database
+
+// migrations are the schema changes in order; versions must be 1, 2, 3...
+// Never edit a migration that has been released, add a new one instead.
+//
+// Migration 1 creates the tables that existed before migrations were tracked,
+// so it is idempotent: databases created back then keep their tables and data.
+
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create content idea, post, analytics and speculation tables",
+		Up: `
+			CREATE TABLE IF NOT EXISTS content_ideas (
+				id SERIAL PRIMARY KEY,
+				headline TEXT NOT NULL,
+				content TEXT NOT NULL,
+				talking_points TEXT[] NOT NULL,
+				hashtags TEXT[] NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS posts (
+				id SERIAL PRIMARY KEY,
+				instagram_id TEXT,
+				caption TEXT NOT NULL,
+				media_url TEXT,
+				permalink TEXT,
+				status TEXT NOT NULL,
+				scheduled_at TIMESTAMP,
+				posted_at TIMESTAMP,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS analytics (
+				id SERIAL PRIMARY KEY,
+				post_id INTEGER REFERENCES posts(id),
+				engagement INTEGER NOT NULL,
+				impressions INTEGER NOT NULL,
+				reach INTEGER NOT NULL,
+				saved INTEGER NOT NULL,
+				recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS speculations (
+				id SERIAL PRIMARY KEY,
+				company VARCHAR(255) NOT NULL,
+				topic VARCHAR(255) NOT NULL,
+				headline TEXT NOT NULL,
+				content TEXT NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+		`,
+		Down: `
+			DROP TABLE IF EXISTS speculations;
+			DROP TABLE IF EXISTS analytics;
+			DROP TABLE IF EXISTS posts;
+			DROP TABLE IF EXISTS content_ideas;
+		`,
+	},
+	{
+		Version:     2,
+		Description: "add publishing state, carousel assets and news items",
+		Up: `
+			-- Why the last publish attempt failed, the media type, the processing
+			-- state of uploaded video and when the post was claimed for publishing
+			ALTER TABLE posts
+				ADD COLUMN last_error TEXT NOT NULL DEFAULT '',
+				ADD COLUMN media_type TEXT NOT NULL DEFAULT 'IMAGE',
+				ADD COLUMN cover_url TEXT NOT NULL DEFAULT '',
+				ADD COLUMN thumb_offset_ms INTEGER NOT NULL DEFAULT 0,
+				ADD COLUMN share_to_feed BOOLEAN NOT NULL DEFAULT TRUE,
+				ADD COLUMN container_id TEXT NOT NULL DEFAULT '',
+				ADD COLUMN processing_status TEXT NOT NULL DEFAULT '',
+				ADD COLUMN processing_started_at TIMESTAMP,
+				ADD COLUMN claimed_at TIMESTAMP;
+
+			-- Posts already publishing get a fresh claim so they are checked after the usual timeout
+			UPDATE posts SET claimed_at = NOW() WHERE status = 'publishing';
+
+			-- Each Instagram media maps to at most one post, which lets backfills be
+			-- re-run. Older databases may link a media to several posts; the first
+			-- post keeps the link.
+			UPDATE posts p SET instagram_id = NULL
+			WHERE instagram_id <> '' AND EXISTS (
+				SELECT 1 FROM posts o WHERE o.instagram_id = p.instagram_id AND o.id < p.id
+			);
+			CREATE UNIQUE INDEX posts_instagram_id_key ON posts (instagram_id) WHERE instagram_id <> '';
+
+			-- The ordered slides of carousel posts
+			CREATE TABLE post_assets (
+				id SERIAL PRIMARY KEY,
+				post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				media_url TEXT NOT NULL,
+				media_type TEXT NOT NULL,
+				UNIQUE (post_id, position)
+			);
+
+			CREATE TABLE news_items (
+				id SERIAL PRIMARY KEY,
+				url TEXT NOT NULL,
+				canonical_url TEXT NOT NULL UNIQUE,
+				title TEXT NOT NULL,
+				source TEXT NOT NULL,
+				content TEXT NOT NULL,
+				published_at TIMESTAMP,
+				simhash BIGINT NOT NULL,
+				duplicate_of INTEGER REFERENCES news_items(id),
+				fetched_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			-- Links ideas to the stories behind them
+			CREATE TABLE content_idea_news (
+				content_idea_id INTEGER NOT NULL REFERENCES content_ideas(id) ON DELETE CASCADE,
+				news_item_id INTEGER NOT NULL REFERENCES news_items(id) ON DELETE CASCADE,
+				PRIMARY KEY (content_idea_id, news_item_id)
+			);
+		`,
+		Down: `
+			DROP TABLE content_idea_news;
+			DROP TABLE news_items;
+			DROP TABLE post_assets;
+			DROP INDEX posts_instagram_id_key;
+			ALTER TABLE posts
+				DROP COLUMN last_error,
+				DROP COLUMN media_type,
+				DROP COLUMN cover_url,
+				DROP COLUMN thumb_offset_ms,
+				DROP COLUMN share_to_feed,
+				DROP COLUMN container_id,
+				DROP COLUMN processing_status,
+				DROP COLUMN processing_started_at,
+				DROP COLUMN claimed_at;
+		`,
+	},
+	{
+		Version:     3,
+		Description: "create accounts and access tokens",
+		Up: `
+			-- Each account's token lives in instagram_tokens under its Instagram user
+			-- ID. The voice and sarcasm level are what its comment replies are drafted in.
+			CREATE TABLE accounts (
+				id SERIAL PRIMARY KEY,
+				instagram_user_id TEXT NOT NULL UNIQUE,
+				username TEXT NOT NULL DEFAULT '',
+				name TEXT NOT NULL DEFAULT '',
+				voice TEXT NOT NULL DEFAULT '',
+				sarcasm_level INTEGER NOT NULL DEFAULT 5,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			-- Scope posts and analytics to an account
+			ALTER TABLE posts ADD COLUMN account_id INTEGER REFERENCES accounts(id);
+			ALTER TABLE analytics ADD COLUMN account_id INTEGER REFERENCES accounts(id);
+			CREATE INDEX posts_account_id_idx ON posts (account_id);
+
+			-- Encrypted long-lived access tokens
+			CREATE TABLE instagram_tokens (
+				user_id TEXT PRIMARY KEY,
+				access_token TEXT NOT NULL,
+				token_type TEXT NOT NULL DEFAULT '',
+				expires_at TIMESTAMP,
+				issued_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				last_refresh_attempt_at TIMESTAMP,
+				last_refresh_error TEXT NOT NULL DEFAULT '',
+				updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+		`,
+		Down: `
+			DROP TABLE instagram_tokens;
+			DROP INDEX posts_account_id_idx;
+			ALTER TABLE analytics DROP COLUMN account_id;
+			ALTER TABLE posts DROP COLUMN account_id;
+			DROP TABLE accounts;
+		`,
+	},
+	{
+		Version:     4,
+		Description: "create comments and reply drafts",
+		Up: `
+			-- Comments on our media, received by webhook or fetched, and our moderation of them
+			CREATE TABLE comments (
+				id SERIAL PRIMARY KEY,
+				account_id INTEGER REFERENCES accounts(id),
+				instagram_id TEXT NOT NULL UNIQUE,
+				media_id TEXT NOT NULL,
+				parent_id TEXT NOT NULL DEFAULT '',
+				user_id TEXT NOT NULL DEFAULT '',
+				username TEXT NOT NULL DEFAULT '',
+				text TEXT NOT NULL,
+				live BOOLEAN NOT NULL DEFAULT FALSE,
+				commented_at TIMESTAMP,
+				like_count INTEGER NOT NULL DEFAULT 0,
+				hidden BOOLEAN NOT NULL DEFAULT FALSE,
+				deleted_at TIMESTAMP,
+				received_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE INDEX comments_media_id_idx ON comments (media_id);
+
+			-- Drafted comment replies queued for approval. A comment gets at most
+			-- one draft, which keeps us from replying twice.
+			CREATE TABLE reply_drafts (
+				id SERIAL PRIMARY KEY,
+				account_id INTEGER REFERENCES accounts(id),
+				comment_id TEXT NOT NULL UNIQUE REFERENCES comments(instagram_id),
+				reply TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL,
+				reason TEXT NOT NULL DEFAULT '',
+				reply_id TEXT NOT NULL DEFAULT '',
+				last_error TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				decided_at TIMESTAMP,
+				posted_at TIMESTAMP
+			);
+		`,
+		Down: `
+			DROP TABLE reply_drafts;
+			DROP TABLE comments;
+		`,
+	},
+	{
+		Version:     5,
+		Description: "create mentions",
+		Up: `
+			-- Media and comments we are mentioned in and media we are tagged in,
+			-- with enough detail to show who is talking about us and whether we
+			-- still owe them a response. A media can both tag and mention us, so
+			-- the kind is part of the key.
+			CREATE TABLE mentions (
+				id SERIAL PRIMARY KEY,
+				account_id INTEGER REFERENCES accounts(id),
+				kind TEXT NOT NULL,
+				media_id TEXT NOT NULL,
+				comment_id TEXT NOT NULL DEFAULT '',
+				username TEXT NOT NULL DEFAULT '',
+				text TEXT NOT NULL DEFAULT '',
+				permalink TEXT NOT NULL DEFAULT '',
+				media_type TEXT NOT NULL DEFAULT '',
+				like_count INTEGER NOT NULL DEFAULT 0,
+				comments_count INTEGER NOT NULL DEFAULT 0,
+				mentioned_at TIMESTAMP,
+				fetched_at TIMESTAMP,
+				needs_response BOOLEAN NOT NULL DEFAULT TRUE,
+				responded_at TIMESTAMP,
+				response_id TEXT NOT NULL DEFAULT '',
+				received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				UNIQUE (media_id, comment_id, kind)
+			);
+		`,
+		Down: `
+			DROP TABLE mentions;
+		`,
+	},
+	{
+		Version:     6,
+		Description: "create story and account insights",
+		Up: `
+			-- The final insights of expired stories
+			CREATE TABLE story_insights (
+				id SERIAL PRIMARY KEY,
+				account_id INTEGER REFERENCES accounts(id),
+				media_id TEXT NOT NULL UNIQUE,
+				impressions INTEGER NOT NULL,
+				reach INTEGER NOT NULL,
+				replies INTEGER NOT NULL,
+				exits INTEGER NOT NULL,
+				taps_forward INTEGER NOT NULL,
+				taps_back INTEGER NOT NULL,
+				recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			-- A daily snapshot of each account's insights, from which follower
+			-- growth is charted. Metrics the API doesn't offer are null.
+			CREATE TABLE account_insights (
+				id SERIAL PRIMARY KEY,
+				account_id INTEGER NOT NULL REFERENCES accounts(id),
+				date DATE NOT NULL,
+				followers_count INTEGER NOT NULL,
+				follows_count INTEGER NOT NULL,
+				media_count INTEGER NOT NULL,
+				new_followers INTEGER,
+				profile_views INTEGER,
+				website_clicks INTEGER,
+				reach_day INTEGER,
+				reach_week INTEGER,
+				reach_28_days INTEGER,
+				audience_cities JSONB NOT NULL DEFAULT '{}',
+				audience_countries JSONB NOT NULL DEFAULT '{}',
+				audience_age_gender JSONB NOT NULL DEFAULT '{}',
+				recorded_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				UNIQUE (account_id, date)
+			);
+		`,
+		Down: `
+			DROP TABLE account_insights;
+			DROP TABLE story_insights;
+		`,
+	},
+	{
+		Version:     7,
+		Description: "create hashtags, the hashtag query ledger and hashtag snapshots",
+		Up: `
+			-- The Instagram IDs of hashtags by name
+			CREATE TABLE hashtags (
+				hashtag_id TEXT PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				tracked BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			-- The hashtags each Instagram user has queried, used to stay under the
+			-- weekly unique hashtag limit. Hashtags are reserved by name before
+			-- their ID is known.
+			CREATE TABLE hashtag_queries (
+				id SERIAL PRIMARY KEY,
+				instagram_user_id TEXT NOT NULL,
+				name TEXT NOT NULL,
+				hashtag_id TEXT REFERENCES hashtags(hashtag_id),
+				queried_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE INDEX hashtag_queries_user_idx ON hashtag_queries (instagram_user_id, queried_at);
+
+			-- The activity of tracked hashtags over time. Capped snapshots saw only
+			-- the first page of recent media.
+			CREATE TABLE hashtag_snapshots (
+				id SERIAL PRIMARY KEY,
+				hashtag_id TEXT NOT NULL REFERENCES hashtags(hashtag_id),
+				top_media_count INTEGER NOT NULL,
+				top_likes INTEGER NOT NULL,
+				top_comments INTEGER NOT NULL,
+				recent_media_count INTEGER NOT NULL,
+				recent_likes INTEGER NOT NULL,
+				recent_comments INTEGER NOT NULL,
+				recent_media_capped BOOLEAN NOT NULL DEFAULT FALSE,
+				recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE INDEX hashtag_snapshots_hashtag_idx ON hashtag_snapshots (hashtag_id, recorded_at);
+		`,
+		Down: `
+			DROP TABLE hashtag_snapshots;
+			DROP TABLE hashtag_queries;
+			DROP TABLE hashtags;
+		`,
+	},
+	{
+		Version:     8,
+		Description: "create competitors and competitor snapshots",
+		Up: `
+			-- The accounts we benchmark against
+			CREATE TABLE competitors (
+				id SERIAL PRIMARY KEY,
+				username TEXT NOT NULL UNIQUE,
+				instagram_id TEXT NOT NULL DEFAULT '',
+				name TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			-- Each competitor's public stats over time
+			CREATE TABLE competitor_snapshots (
+				id SERIAL PRIMARY KEY,
+				competitor_id INTEGER NOT NULL REFERENCES competitors(id) ON DELETE CASCADE,
+				followers_count INTEGER NOT NULL,
+				media_count INTEGER NOT NULL,
+				media_sampled INTEGER NOT NULL,
+				posts_per_week DOUBLE PRECISION NOT NULL,
+				formats JSONB NOT NULL DEFAULT '{}',
+				avg_likes DOUBLE PRECISION NOT NULL,
+				avg_comments DOUBLE PRECISION NOT NULL,
+				engagement_rate DOUBLE PRECISION NOT NULL,
+				recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE INDEX competitor_snapshots_competitor_idx ON competitor_snapshots (competitor_id, recorded_at);
+		`,
+		Down: `
+			DROP TABLE competitor_snapshots;
+			DROP TABLE competitors;
+		`,
+	},
+}
@@ -0,0 +1,354 @@
+package database
+
+import (
+	"database/sql"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// nearDuplicateDistance is the maximum SimHash Hamming distance for two
+// stories to be treated as the same story
+const nearDuplicateDistance = 6
+
+// duplicateLookback is how far back new stories are compared against
+const duplicateLookback = 72 * time.Hour
+
+// minSimHashWords is the fewest words a story needs for a SimHash; shorter
+// texts share too few features to tell stories apart
+const minSimHashWords = 6
+
+// NewsItem represents an ingested news story in the database
+type NewsItem struct {
+	ID             int        `json:"id"`
+	URL            string     `json:"url"`
+	CanonicalURL   string     `json:"canonical_url"`
+	Title          string     `json:"title"`
+	Source         string     `json:"source"`
+	Content        string     `json:"content"`
+	PublishedAt    *time.Time `json:"published_at"`
+	SimHash        uint64     `json:"-"`
+	DuplicateOf    *int       `json:"duplicate_of"`
+	DuplicateCount int        `json:"duplicate_count"`
+	IdeaIDs        []int64    `json:"idea_ids"`
+	FetchedAt      time.Time  `json:"fetched_at"`
+}
+
+// trackingParams are the click and campaign IDs added by ad networks and mail
+// tools, which never change which story a URL points at. Generic names such as
+// ref or src are left alone since some sites route on them.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "gbraid": true, "wbraid": true, "dclid": true,
+	"msclkid": true, "yclid": true, "twclid": true, "ttclid": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true, "mkt_tok": true,
+}
+
+// CanonicalURL normalizes a story URL so that the same article shared with
+// tracking parameters, a www. host or an AMP path maps to one key
+func CanonicalURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(raw)
+	}
+
+	u.Scheme = "https"
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	// Other subdomains such as m. or amp. may serve different pages, so only www. goes
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	path := u.EscapedPath()
+	path = strings.TrimSuffix(path, "/amp")
+	path = strings.TrimSuffix(path, "/amp/")
+	path = strings.TrimRight(path, "/")
+	u.RawPath = ""
+	u.Path, _ = url.PathUnescape(path)
+
+	query := u.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(key)
+		}
+	}
+	// Encode sorts the keys, so parameter order doesn't matter
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// SimHash computes a 64-bit SimHash over the words and word pairs of text.
+// Text with fewer than minSimHashWords words has no fingerprint and hashes to 0.
+func SimHash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) < minSimHashWords {
+		return 0
+	}
+
+	var weights [64]int
+	addFeature := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	for i, word := range words {
+		addFeature(word)
+		if i > 0 {
+			addFeature(words[i-1] + " " + word)
+		}
+	}
+
+	var hash uint64
+	for i, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// hammingDistance counts the differing bits between two hashes
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// SaveNewsItems stores fetched news items, skipping URLs that were already
+// stored and marking near-duplicates of recent stories via DuplicateOf.
+// Stories too short for a SimHash are never matched as near-duplicates.
+// The returned items carry their database IDs.
+func (db *DB) SaveNewsItems(items []NewsItem) ([]NewsItem, error) {
+	rows, err := db.Query(`
+		SELECT id, simhash
+		FROM news_items
+		WHERE duplicate_of IS NULL AND simhash <> 0 AND fetched_at > $1
+	`, time.Now().Add(-duplicateLookback))
+	if err != nil {
+		return nil, err
+	}
+
+	type fingerprint struct {
+		id   int
+		hash uint64
+	}
+	var recent []fingerprint
+	for rows.Next() {
+		var f fingerprint
+		var hash int64
+		if err := rows.Scan(&f.id, &hash); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		f.hash = uint64(hash)
+		recent = append(recent, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	saved := make([]NewsItem, 0, len(items))
+	for _, item := range items {
+		item.CanonicalURL = CanonicalURL(item.URL)
+		item.SimHash = SimHash(item.Title + " " + item.Content)
+		item.DuplicateOf = nil
+
+		for _, f := range recent {
+			if item.SimHash != 0 && hammingDistance(f.hash, item.SimHash) <= nearDuplicateDistance {
+				id := f.id
+				item.DuplicateOf = &id
+				break
+			}
+		}
+
+		// On conflict the no-op update lets RETURNING hand back the existing row
+		err := db.QueryRow(`
+			INSERT INTO news_items (url, canonical_url, title, source, content, published_at, simhash, duplicate_of)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (canonical_url) DO UPDATE SET canonical_url = EXCLUDED.canonical_url
+			RETURNING id, duplicate_of, fetched_at
+		`,
+			item.URL,
+			item.CanonicalURL,
+			item.Title,
+			item.Source,
+			item.Content,
+			item.PublishedAt,
+			int64(item.SimHash),
+			item.DuplicateOf,
+		).Scan(&item.ID, &item.DuplicateOf, &item.FetchedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if item.DuplicateOf == nil && item.SimHash != 0 {
+			recent = append(recent, fingerprint{id: item.ID, hash: item.SimHash})
+		}
+		saved = append(saved, item)
+	}
+
+	return saved, nil
+}
+
+// GetNewsHistory gets stored stories newest first, collapsing near-duplicates
+// into their original and listing the content ideas each story inspired
+func (db *DB) GetNewsHistory(limit, offset int) ([]NewsItem, error) {
+	query := `
+		SELECT n.id, n.url, n.canonical_url, n.title, n.source, n.content, n.published_at,
+			n.fetched_at,
+			(SELECT COUNT(*) FROM news_items d WHERE d.duplicate_of = n.id),
+			ARRAY(SELECT l.content_idea_id FROM content_idea_news l WHERE l.news_item_id = n.id ORDER BY l.content_idea_id)
+		FROM news_items n
+		WHERE n.duplicate_of IS NULL
+		ORDER BY COALESCE(n.published_at, n.fetched_at) DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := db.Query(query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []NewsItem
+	for rows.Next() {
+		var item NewsItem
+		err := rows.Scan(
+			&item.ID,
+			&item.URL,
+			&item.CanonicalURL,
+			&item.Title,
+			&item.Source,
+			&item.Content,
+			&item.PublishedAt,
+			&item.FetchedAt,
+			&item.DuplicateCount,
+			pq.Array(&item.IdeaIDs),
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetNewsItemsSince gets every stored story published (or, lacking a publish
+// date, fetched) after since. Near-duplicates are left out so that syndicated
+// copies of a story count once.
+func (db *DB) GetNewsItemsSince(since time.Time) ([]NewsItem, error) {
+	query := `
+		SELECT id, url, canonical_url, title, source, content, published_at, duplicate_of, fetched_at
+		FROM news_items
+		WHERE duplicate_of IS NULL AND COALESCE(published_at, fetched_at) > $1
+		ORDER BY COALESCE(published_at, fetched_at) DESC
+	`
+
+	rows, err := db.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []NewsItem
+	for rows.Next() {
+		var item NewsItem
+		err := rows.Scan(
+			&item.ID,
+			&item.URL,
+			&item.CanonicalURL,
+			&item.Title,
+			&item.Source,
+			&item.Content,
+			&item.PublishedAt,
+			&item.DuplicateOf,
+			&item.FetchedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetContentIdeaSources gets the stories a content idea was generated from
+func (db *DB) GetContentIdeaSources(ideaID int) ([]NewsItem, error) {
+	query := `
+		SELECT n.id, n.url, n.canonical_url, n.title, n.source, n.content, n.published_at,
+			n.duplicate_of, n.fetched_at
+		FROM content_idea_news l
+		JOIN news_items n ON n.id = l.news_item_id
+		WHERE l.content_idea_id = $1
+		ORDER BY n.published_at DESC NULLS LAST
+	`
+
+	rows, err := db.Query(query, ideaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []NewsItem
+	for rows.Next() {
+		var item NewsItem
+		err := rows.Scan(
+			&item.ID,
+			&item.URL,
+			&item.CanonicalURL,
+			&item.Title,
+			&item.Source,
+			&item.Content,
+			&item.PublishedAt,
+			&item.DuplicateOf,
+			&item.FetchedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// linkContentIdeaSources records which stored stories an idea came from.
+// Near-duplicates are linked through to the original story.
+func linkContentIdeaSources(tx *sql.Tx, ideaID int, sourceURLs []string) error {
+	canonical := make([]string, 0, len(sourceURLs))
+	for _, u := range sourceURLs {
+		canonical = append(canonical, CanonicalURL(u))
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO content_idea_news (content_idea_id, news_item_id)
+		SELECT $1, COALESCE(n.duplicate_of, n.id)
+		FROM news_items n
+		WHERE n.canonical_url = ANY($2)
+		ON CONFLICT DO NOTHING
+	`, ideaID, pq.Array(canonical))
+	return err
+}
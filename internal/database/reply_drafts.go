@@ -0,0 +1,275 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Reply draft statuses
+const (
+	ReplyDraftPending  = "pending"
+	ReplyDraftSkipped  = "skipped"
+	ReplyDraftRejected = "rejected"
+	ReplyDraftPosting  = "posting"
+	ReplyDraftPosted   = "posted"
+	ReplyDraftFailed   = "failed"
+	// ReplyDraftUnknown means posting was attempted but Instagram's answer was
+	// lost, so the reply may or may not be up
+	ReplyDraftUnknown = "unknown"
+)
+
+// ReplyDraft is a drafted reply to a comment awaiting, or past, human approval
+type ReplyDraft struct {
+	ID        int    `json:"id"`
+	AccountID *int   `json:"account_id"`
+	CommentID string `json:"comment_id"`
+	MediaID   string `json:"media_id"`
+	Reply     string `json:"reply"`
+	Status    string `json:"status"` // pending, skipped, rejected, posting, posted, failed or unknown
+	// Reason explains why the comment was skipped
+	Reason string `json:"reason"`
+	// ReplyID is the Instagram ID of the posted reply
+	ReplyID   string     `json:"reply_id"`
+	LastError string     `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+	DecidedAt *time.Time `json:"decided_at"`
+	PostedAt  *time.Time `json:"posted_at"`
+	// CommentUsername and CommentText are the comment being replied to
+	CommentUsername string `json:"comment_username"`
+	CommentText     string `json:"comment_text"`
+}
+
+// replyDraftColumns are the columns selected by scanReplyDraft, in order.
+// Queries must alias reply_drafts as d and join comments as c.
+const replyDraftColumns = `d.id, d.account_id, d.comment_id, c.media_id, d.reply, d.status, d.reason,
+	d.reply_id, d.last_error, d.created_at, d.decided_at, d.posted_at, c.username, c.text`
+
+// scanReplyDraft scans a row selected with replyDraftColumns
+func scanReplyDraft(row rowScanner, draft *ReplyDraft) error {
+	return row.Scan(
+		&draft.ID,
+		&draft.AccountID,
+		&draft.CommentID,
+		&draft.MediaID,
+		&draft.Reply,
+		&draft.Status,
+		&draft.Reason,
+		&draft.ReplyID,
+		&draft.LastError,
+		&draft.CreatedAt,
+		&draft.DecidedAt,
+		&draft.PostedAt,
+		&draft.CommentUsername,
+		&draft.CommentText,
+	)
+}
+
+// CommentToDraft is a comment that needs a reply draft, along with the caption
+// of the post it was left on when we have it
+type CommentToDraft struct {
+	Comment
+	PostCaption string
+}
+
+// GetCommentsAwaitingDraft gets the oldest top-level comments that have no
+// draft yet. Deleted, hidden and live comments are left out, as are comments
+// written by one of our accounts or already replied to by one.
+func (db *DB) GetCommentsAwaitingDraft(limit int) ([]CommentToDraft, error) {
+	query := `
+		SELECT c.id, c.account_id, c.instagram_id, c.media_id, c.parent_id, c.user_id, c.username,
+			c.text, c.live, c.commented_at, c.like_count, c.hidden, c.deleted_at, c.received_at,
+			COALESCE(p.caption, '')
+		FROM comments c
+		LEFT JOIN posts p ON p.instagram_id = c.media_id
+		WHERE c.parent_id = ''
+			AND c.deleted_at IS NULL
+			AND NOT c.hidden
+			AND NOT c.live
+			AND NOT EXISTS (SELECT 1 FROM reply_drafts d WHERE d.comment_id = c.instagram_id)
+			AND NOT EXISTS (SELECT 1 FROM accounts a WHERE a.instagram_user_id = c.user_id)
+			AND NOT EXISTS (
+				SELECT 1 FROM comments r
+				JOIN accounts a ON a.instagram_user_id = r.user_id
+				WHERE r.parent_id = c.instagram_id
+			)
+		ORDER BY c.received_at
+		LIMIT $1
+	`
+
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []CommentToDraft
+	for rows.Next() {
+		var comment CommentToDraft
+		err := rows.Scan(
+			&comment.ID,
+			&comment.AccountID,
+			&comment.InstagramID,
+			&comment.MediaID,
+			&comment.ParentID,
+			&comment.UserID,
+			&comment.Username,
+			&comment.Text,
+			&comment.Live,
+			&comment.CommentedAt,
+			&comment.LikeCount,
+			&comment.Hidden,
+			&comment.DeletedAt,
+			&comment.ReceivedAt,
+			&comment.PostCaption,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// HasAccountReply reports whether one of our accounts has replied to a comment,
+// counting replies that were deleted since
+func (db *DB) HasAccountReply(commentID string) (bool, error) {
+	var replied bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM comments r
+			JOIN accounts a ON a.instagram_user_id = r.user_id
+			WHERE r.parent_id = $1
+		)
+	`, commentID).Scan(&replied)
+	return replied, err
+}
+
+// SaveReplyDraft stores a draft for a comment. A comment only ever gets one
+// draft; it reports false, leaving the draft unsaved, if one already exists.
+func (db *DB) SaveReplyDraft(draft *ReplyDraft) (bool, error) {
+	query := `
+		INSERT INTO reply_drafts (account_id, comment_id, reply, status, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (comment_id) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := db.QueryRow(query, draft.AccountID, draft.CommentID, draft.Reply, draft.Status, draft.Reason).
+		Scan(&draft.ID, &draft.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetReplyDrafts gets drafts, newest first, optionally filtered by status and account
+func (db *DB) GetReplyDrafts(status string, accountID *int) ([]ReplyDraft, error) {
+	query := `
+		SELECT ` + replyDraftColumns + `
+		FROM reply_drafts d
+		JOIN comments c ON c.instagram_id = d.comment_id
+		WHERE ($1 = '' OR d.status = $1) AND ($2::INTEGER IS NULL OR d.account_id = $2)
+		ORDER BY d.created_at DESC
+	`
+
+	rows, err := db.Query(query, status, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []ReplyDraft{}
+	for rows.Next() {
+		var draft ReplyDraft
+		if err := scanReplyDraft(rows, &draft); err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+// GetReplyDraft gets a draft by ID. It returns sql.ErrNoRows if there is none.
+func (db *DB) GetReplyDraft(id int) (*ReplyDraft, error) {
+	query := `
+		SELECT ` + replyDraftColumns + `
+		FROM reply_drafts d
+		JOIN comments c ON c.instagram_id = d.comment_id
+		WHERE d.id = $1
+	`
+
+	var draft ReplyDraft
+	if err := scanReplyDraft(db.QueryRow(query, id), &draft); err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// UpdateReplyDraftText edits the reply of a draft that hasn't been decided on.
+// It reports false if the draft isn't pending or failed.
+func (db *DB) UpdateReplyDraftText(id int, reply string) (bool, error) {
+	return db.updateReplyDraft(`
+		UPDATE reply_drafts SET reply = $2
+		WHERE id = $1 AND status IN ('pending', 'failed')
+	`, id, reply)
+}
+
+// ClaimReplyDraft marks a pending, failed or unknown draft as being posted.
+// Only one caller can claim a draft, so an approved reply is never posted twice.
+func (db *DB) ClaimReplyDraft(id int) (bool, error) {
+	return db.updateReplyDraft(`
+		UPDATE reply_drafts SET status = 'posting', decided_at = NOW(), last_error = ''
+		WHERE id = $1 AND status IN ('pending', 'failed', 'unknown')
+	`, id)
+}
+
+// MarkReplyDraftPosted records that the reply of a draft was posted
+func (db *DB) MarkReplyDraftPosted(id int, replyID string) error {
+	_, err := db.Exec(`
+		UPDATE reply_drafts SET status = 'posted', reply_id = $2, posted_at = NOW()
+		WHERE id = $1
+	`, id, replyID)
+	return err
+}
+
+// MarkReplyDraftFailed records why posting the reply of a draft failed.
+// Failed drafts can be edited and approved again.
+func (db *DB) MarkReplyDraftFailed(id int, reason string) error {
+	_, err := db.Exec(`UPDATE reply_drafts SET status = 'failed', last_error = $2 WHERE id = $1`, id, reason)
+	return err
+}
+
+// MarkReplyDraftUnknown records that posting the reply of a draft may or may
+// not have succeeded. Unknown drafts can be approved again once the comment's
+// replies have been checked, but not edited or rejected.
+func (db *DB) MarkReplyDraftUnknown(id int, reason string) error {
+	_, err := db.Exec(`UPDATE reply_drafts SET status = 'unknown', last_error = $2 WHERE id = $1`, id, reason)
+	return err
+}
+
+// RejectReplyDraft rejects a draft that hasn't been posted. It reports false if
+// the draft isn't pending or failed.
+func (db *DB) RejectReplyDraft(id int) (bool, error) {
+	return db.updateReplyDraft(`
+		UPDATE reply_drafts SET status = 'rejected', decided_at = NOW()
+		WHERE id = $1 AND status IN ('pending', 'failed')
+	`, id)
+}
+
+// updateReplyDraft runs a conditional update and reports whether it matched a draft
+func (db *DB) updateReplyDraft(query string, args ...interface{}) (bool, error) {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import "time"
+
+// IdeaRepository stores generated content ideas
+type IdeaRepository interface {
+	SaveContentIdea(idea *ContentIdea) error
+	// SaveContentIdeas saves all of the ideas or none of them
+	SaveContentIdeas(ideas []ContentIdea) error
+	GetContentIdeas() ([]ContentIdea, error)
+}
+
+// PostRepository stores posts and tracks them through publishing. Getting a
+// post that doesn't exist returns sql.ErrNoRows.
+type PostRepository interface {
+	SavePost(post *Post) error
+	SaveImportedPost(post *Post) (bool, error)
+	GetPosts() ([]Post, error)
+	GetAccountPosts(accountID int) ([]Post, error)
+	GetPost(id int) (*Post, error)
+	GetDuePosts(now time.Time) ([]Post, error)
+	ClaimPostForPublishing(id int) (bool, error)
+	GetStalePublishingPosts(claimedBefore time.Time) ([]Post, error)
+	GetProcessingPosts() ([]Post, error)
+	SetPostContainer(id int, containerID string) error
+	MarkPostProcessing(id int, containerID string) error
+	UpdatePostProcessingStatus(id int, processingStatus string) error
+	MarkPostPublished(id int, instagramID, permalink string, postedAt time.Time) error
+	MarkPostFailed(id int, reason string) error
+}
+
+// AnalyticsRepository stores post analytics
+type AnalyticsRepository interface {
+	SaveAnalytics(analytics *Analytics) error
+	GetAnalyticsForPost(postID int) ([]Analytics, error)
+	GetAccountAnalytics(accountID int) ([]Analytics, error)
+}
+
+// SpeculationRepository stores generated speculations
+type SpeculationRepository interface {
+	SaveSpeculation(company, topic, headline, content string) error
+	GetRecentSpeculations(limit int) ([]map[string]interface{}, error)
+}
+
+// Repositories groups the repositories the server's core features use
+type Repositories struct {
+	Ideas        IdeaRepository
+	Posts        PostRepository
+	Analytics    AnalyticsRepository
+	Speculations SpeculationRepository
+}
+
+var (
+	_ IdeaRepository        = (*DB)(nil)
+	_ PostRepository        = (*DB)(nil)
+	_ AnalyticsRepository   = (*DB)(nil)
+	_ SpeculationRepository = (*DB)(nil)
+)
+
+// Repositories returns repositories backed by the database
+func (db *DB) Repositories() Repositories {
+	return Repositories{
+		Ideas:        db,
+		Posts:        db,
+		Analytics:    db,
+		Speculations: db,
+	}
+}
@@ -0,0 +1,318 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testDB connects to TEST_DATABASE_URL and skips the test when it isn't set.
+// The database is shared with other tests and may be migrated down and up.
+func testDB(t *testing.T) *DB {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	t.Setenv("DATABASE_URL", dsn)
+	db, err := New()
+	if err != nil {
+		t.Fatalf("connecting to TEST_DATABASE_URL: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// repositoryBackends returns the repositories the contract tests run against:
+// always the in-memory store, and Postgres when TEST_DATABASE_URL is set. The
+// Postgres database is migrated and shared, so tests only look at rows they create.
+func repositoryBackends(t *testing.T) map[string]func(t *testing.T) Repositories {
+	backends := map[string]func(t *testing.T) Repositories{
+		"memory": func(t *testing.T) Repositories { return NewMemoryRepositories() },
+	}
+
+	if os.Getenv("TEST_DATABASE_URL") == "" {
+		t.Log("TEST_DATABASE_URL not set, skipping Postgres")
+		return backends
+	}
+	backends["postgres"] = func(t *testing.T) Repositories {
+		db := testDB(t)
+		if err := db.Migrate(); err != nil {
+			t.Fatalf("migrating: %v", err)
+		}
+		return db.Repositories()
+	}
+	return backends
+}
+
+// forEachBackend runs test against every repository backend
+func forEachBackend(t *testing.T, test func(t *testing.T, repos Repositories)) {
+	for name, open := range repositoryBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			test(t, open(t))
+		})
+	}
+}
+
+// uniqueName returns a name no earlier run has used, for finding rows in a shared database
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+}
+
+func TestIdeaRepositoryContract(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repositories) {
+		idea := ContentIdea{
+			Headline:      uniqueName("idea"),
+			TalkingPoints: []string{"one", "two"},
+			Hashtags:      []string{"#tech"},
+			SourceURLs:    []string{"https://example.com/not-a-stored-news-item"},
+		}
+		if err := repos.Ideas.SaveContentIdea(&idea); err != nil {
+			t.Fatal(err)
+		}
+		if idea.ID == 0 || idea.CreatedAt.IsZero() {
+			t.Errorf("saved idea has ID %d, created at %s", idea.ID, idea.CreatedAt)
+		}
+
+		batch := []ContentIdea{{Headline: uniqueName("batch")}, {Headline: uniqueName("batch")}}
+		if err := repos.Ideas.SaveContentIdeas(batch); err != nil {
+			t.Fatal(err)
+		}
+		if batch[0].ID == 0 || batch[0].ID == batch[1].ID {
+			t.Errorf("batch IDs = %d, %d, want distinct IDs", batch[0].ID, batch[1].ID)
+		}
+
+		ideas, err := repos.Ideas.GetContentIdeas()
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, stored := range ideas {
+			if stored.ID != idea.ID {
+				continue
+			}
+			found = true
+			if len(stored.TalkingPoints) != 2 || len(stored.Hashtags) != 1 {
+				t.Errorf("stored idea = %+v, want its talking points and hashtags", stored)
+			}
+			if len(stored.SourceURLs) != 0 {
+				t.Errorf("SourceURLs = %v, want URLs of unknown news dropped", stored.SourceURLs)
+			}
+		}
+		if !found {
+			t.Errorf("idea %d not listed", idea.ID)
+		}
+	})
+}
+
+func TestPostRepositoryContract(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repositories) {
+		post := Post{
+			Caption: uniqueName("carousel"),
+			Status:  PostStatusDraft,
+			Assets:  []PostAsset{{MediaURL: "https://example.com/1.jpg"}, {MediaURL: "https://example.com/2.jpg"}},
+		}
+		if err := repos.Posts.SavePost(&post); err != nil {
+			t.Fatal(err)
+		}
+		if post.MediaType != PostMediaCarousel || post.ShareToFeed == nil || !*post.ShareToFeed {
+			t.Errorf("saved post = %+v, want the table defaults", post)
+		}
+
+		stored, err := repos.Posts.GetPost(post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.Assets) != 2 || stored.Assets[1].Position != 2 || stored.Assets[1].PostID != post.ID {
+			t.Errorf("assets = %+v, want two ordered assets", stored.Assets)
+		}
+
+		if _, err := repos.Posts.GetPost(-1); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("missing post: err = %v, want sql.ErrNoRows", err)
+		}
+
+		claimed, err := repos.Posts.ClaimPostForPublishing(post.ID)
+		if err != nil || !claimed {
+			t.Fatalf("first claim = %v, %v", claimed, err)
+		}
+		if claimed, _ := repos.Posts.ClaimPostForPublishing(post.ID); claimed {
+			t.Errorf("second claim succeeded")
+		}
+
+		if err := repos.Posts.SetPostContainer(post.ID, "container-0"); err != nil {
+			t.Fatal(err)
+		}
+		if stored, err := repos.Posts.GetPost(post.ID); err != nil || stored.ContainerID != "container-0" {
+			t.Errorf("container = %q, %v; want container-0", stored.ContainerID, err)
+		}
+
+		if err := repos.Posts.MarkPostFailed(post.ID, "boom"); err != nil {
+			t.Fatal(err)
+		}
+		if claimed, _ := repos.Posts.ClaimPostForPublishing(post.ID); !claimed {
+			t.Errorf("failed post couldn't be claimed again")
+		}
+		if stored, err := repos.Posts.GetPost(post.ID); err != nil || stored.ContainerID != "" {
+			t.Errorf("container after a new claim = %q, %v; want none", stored.ContainerID, err)
+		}
+
+		if err := repos.Posts.MarkPostProcessing(post.ID, "container-1"); err != nil {
+			t.Fatal(err)
+		}
+		processing, err := repos.Posts.GetProcessingPosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, p := range processing {
+			if p.ID != post.ID {
+				continue
+			}
+			found = true
+			if len(p.Assets) != 2 {
+				t.Errorf("processing post has %d assets, want 2", len(p.Assets))
+			}
+		}
+		if !found {
+			t.Errorf("processing post not listed")
+		}
+
+		postedAt := time.Now().Truncate(time.Second)
+		if err := repos.Posts.MarkPostPublished(post.ID, uniqueName("ig"), "https://instagram.com/p/x", postedAt); err != nil {
+			t.Fatal(err)
+		}
+		stored, err = repos.Posts.GetPost(post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != PostStatusPosted || stored.PostedAt == nil || stored.LastError != "" {
+			t.Errorf("published post = %+v", stored)
+		}
+	})
+}
+
+func TestDuePostsContract(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repositories) {
+		now := time.Now()
+		past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+		due := Post{Caption: uniqueName("due"), Status: PostStatusScheduled, ScheduledAt: &past}
+		later := Post{Caption: uniqueName("later"), Status: PostStatusScheduled, ScheduledAt: &future}
+		for _, post := range []*Post{&due, &later} {
+			if err := repos.Posts.SavePost(post); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		duePosts := func() ([]Post, error) { return repos.Posts.GetDuePosts(now) }
+		if !containsPost(t, duePosts, due.ID) {
+			t.Errorf("due post not listed")
+		}
+		if containsPost(t, duePosts, later.ID) {
+			t.Errorf("future post listed as due")
+		}
+	})
+}
+
+func TestImportedPostsContract(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repositories) {
+		post := Post{InstagramID: uniqueName("ig"), Caption: "first", Status: PostStatusPosted, MediaType: PostMediaImage}
+		created, err := repos.Posts.SaveImportedPost(&post)
+		if err != nil || !created {
+			t.Fatalf("first import = %v, %v", created, err)
+		}
+
+		again := Post{InstagramID: post.InstagramID, Caption: "edited", Status: PostStatusPosted, MediaType: PostMediaImage}
+		created, err = repos.Posts.SaveImportedPost(&again)
+		if err != nil || created {
+			t.Fatalf("second import = %v, %v", created, err)
+		}
+		if again.ID != post.ID {
+			t.Errorf("reimport got ID %d, want %d", again.ID, post.ID)
+		}
+
+		stored, err := repos.Posts.GetPost(post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Caption != "edited" {
+			t.Errorf("caption = %q, want the reimported one", stored.Caption)
+		}
+	})
+}
+
+func TestAnalyticsRepositoryContract(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repositories) {
+		post := Post{Caption: uniqueName("measured"), Status: PostStatusDraft}
+		if err := repos.Posts.SavePost(&post); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, reach := range []int{10, 20} {
+			if err := repos.Analytics.SaveAnalytics(&Analytics{PostID: post.ID, Reach: reach}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		analytics, err := repos.Analytics.GetAnalyticsForPost(post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(analytics) != 2 || analytics[0].Reach != 20 {
+			t.Errorf("analytics = %+v, want both readings newest first", analytics)
+		}
+	})
+}
+
+func TestSpeculationRepositoryContract(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repos Repositories) {
+		headline := uniqueName("speculation")
+		if err := repos.Speculations.SaveSpeculation("Acme", "AI", headline, "content"); err != nil {
+			t.Fatal(err)
+		}
+
+		speculations, err := repos.Speculations.GetRecentSpeculations(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(speculations) != 1 || speculations[0]["headline"] != headline {
+			t.Errorf("recent speculations = %v, want %s", speculations, headline)
+		}
+	})
+}
+
+func TestMemoryStoreNumbersEachTableSeparately(t *testing.T) {
+	store := NewMemoryStore()
+
+	idea := ContentIdea{Headline: "idea"}
+	post := Post{Caption: "post", Assets: []PostAsset{{MediaURL: "a"}, {MediaURL: "b"}}}
+	if err := store.SaveContentIdea(&idea); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SavePost(&post); err != nil {
+		t.Fatal(err)
+	}
+
+	if idea.ID != 1 || post.ID != 1 || post.Assets[0].ID != 1 || post.Assets[1].ID != 2 {
+		t.Errorf("IDs: idea %d, post %d, assets %d and %d; want each table to start at 1",
+			idea.ID, post.ID, post.Assets[0].ID, post.Assets[1].ID)
+	}
+}
+
+// containsPost reports whether list returns the post with the given ID
+func containsPost(t *testing.T, list func() ([]Post, error), id int) bool {
+	t.Helper()
+	posts, err := list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, post := range posts {
+		if post.ID == id {
+			return true
+		}
+	}
+	return false
+}
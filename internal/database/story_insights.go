@@ -0,0 +1,47 @@
+package database
+
+import "time"
+
+// StoryInsights are the final insights of a story, reported when it expires
+type StoryInsights struct {
+	ID          int       `json:"id"`
+	AccountID   *int      `json:"account_id"`
+	MediaID     string    `json:"media_id"`
+	Impressions int       `json:"impressions"`
+	Reach       int       `json:"reach"`
+	Replies     int       `json:"replies"`
+	Exits       int       `json:"exits"`
+	TapsForward int       `json:"taps_forward"`
+	TapsBack    int       `json:"taps_back"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// SaveStoryInsights stores the insights of a story, replacing any stored before
+func (db *DB) SaveStoryInsights(insights *StoryInsights) error {
+	query := `
+		INSERT INTO story_insights (account_id, media_id, impressions, reach, replies, exits, taps_forward, taps_back)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (media_id) DO UPDATE SET
+			account_id = COALESCE(story_insights.account_id, EXCLUDED.account_id),
+			impressions = EXCLUDED.impressions,
+			reach = EXCLUDED.reach,
+			replies = EXCLUDED.replies,
+			exits = EXCLUDED.exits,
+			taps_forward = EXCLUDED.taps_forward,
+			taps_back = EXCLUDED.taps_back,
+			recorded_at = NOW()
+		RETURNING id, recorded_at
+	`
+
+	return db.QueryRow(
+		query,
+		insights.AccountID,
+		insights.MediaID,
+		insights.Impressions,
+		insights.Reach,
+		insights.Replies,
+		insights.Exits,
+		insights.TapsForward,
+		insights.TapsBack,
+	).Scan(&insights.ID, &insights.RecordedAt)
+}
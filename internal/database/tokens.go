@@ -0,0 +1,214 @@
+package database
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// AccessToken is a stored Instagram access token. The token itself is
+// encrypted at rest and never serialized.
+type AccessToken struct {
+	UserID      string     `json:"user_id"`
+	AccessToken string     `json:"-"`
+	TokenType   string     `json:"token_type"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	// IssuedAt is when the current token was obtained; Instagram only refreshes
+	// tokens that are at least a day old
+	IssuedAt             time.Time  `json:"issued_at"`
+	LastRefreshAttemptAt *time.Time `json:"last_refresh_attempt_at"`
+	// LastRefreshError is set when the last refresh failed and cleared when one succeeds
+	LastRefreshError string    `json:"last_refresh_error"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// tokenColumns are the columns selected by scanAccessToken, in order
+const tokenColumns = `user_id, access_token, token_type, expires_at, issued_at,
+	last_refresh_attempt_at, last_refresh_error, updated_at`
+
+// tokenCipher builds the AES-GCM cipher for tokens from TOKEN_ENCRYPTION_KEY.
+// Any passphrase works; it is hashed into a 256-bit key.
+func tokenCipher() (cipher.AEAD, error) {
+	secret := os.Getenv("TOKEN_ENCRYPTION_KEY")
+	if secret == "" {
+		return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY environment variable not set")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptToken encrypts a token as base64 of the nonce followed by the ciphertext
+func encryptToken(token string) (string, error) {
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(token), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptToken reverses encryptToken
+func decryptToken(encrypted string) (string, error) {
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted token: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid encrypted token: too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	token, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt token, was TOKEN_ENCRYPTION_KEY changed? %w", err)
+	}
+	return string(token), nil
+}
+
+// scanAccessToken scans a row selected with tokenColumns and decrypts the token
+func scanAccessToken(row rowScanner, token *AccessToken) error {
+	var encrypted string
+	var expiresAt, lastAttemptAt sql.NullTime
+
+	err := row.Scan(
+		&token.UserID,
+		&encrypted,
+		&token.TokenType,
+		&expiresAt,
+		&token.IssuedAt,
+		&lastAttemptAt,
+		&token.LastRefreshError,
+		&token.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastAttemptAt.Valid {
+		token.LastRefreshAttemptAt = &lastAttemptAt.Time
+	}
+
+	token.AccessToken, err = decryptToken(encrypted)
+	return err
+}
+
+// SaveAccessToken stores the token for an Instagram user, replacing any previous
+// one and clearing a recorded refresh failure. A nil ExpiresAt means the expiry
+// is unknown, which makes the token due for refresh once it is old enough.
+func (db *DB) SaveAccessToken(token *AccessToken) error {
+	encrypted, err := encryptToken(token.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO instagram_tokens (user_id, access_token, token_type, expires_at, issued_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			access_token = EXCLUDED.access_token,
+			token_type = EXCLUDED.token_type,
+			expires_at = EXCLUDED.expires_at,
+			issued_at = EXCLUDED.issued_at,
+			last_refresh_error = '',
+			updated_at = NOW()
+		RETURNING issued_at, updated_at
+	`
+
+	return db.QueryRow(query, token.UserID, encrypted, token.TokenType, token.ExpiresAt).
+		Scan(&token.IssuedAt, &token.UpdatedAt)
+}
+
+// GetAccessToken gets the stored token of an Instagram user.
+// It returns sql.ErrNoRows if there is none.
+func (db *DB) GetAccessToken(userID string) (*AccessToken, error) {
+	var token AccessToken
+	row := db.QueryRow(`SELECT `+tokenColumns+` FROM instagram_tokens WHERE user_id = $1`, userID)
+	if err := scanAccessToken(row, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetAccessTokens gets every stored token, soonest to expire first
+func (db *DB) GetAccessTokens() ([]AccessToken, error) {
+	rows, err := db.Query(`SELECT ` + tokenColumns + ` FROM instagram_tokens ORDER BY expires_at NULLS FIRST`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []AccessToken
+	for rows.Next() {
+		var token AccessToken
+		if err := scanAccessToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetTokensDueForRefresh gets the tokens issued before issuedBefore whose expiry
+// is unknown or before expiringBefore. Younger tokens can't be refreshed yet.
+func (db *DB) GetTokensDueForRefresh(expiringBefore, issuedBefore time.Time) ([]AccessToken, error) {
+	query := `
+		SELECT ` + tokenColumns + `
+		FROM instagram_tokens
+		WHERE issued_at < $2 AND (expires_at IS NULL OR expires_at < $1)
+		ORDER BY expires_at NULLS FIRST
+	`
+
+	rows, err := db.Query(query, expiringBefore, issuedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []AccessToken
+	for rows.Next() {
+		var token AccessToken
+		if err := scanAccessToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RecordTokenRefreshFailure records why refreshing a user's token failed
+func (db *DB) RecordTokenRefreshFailure(userID, reason string) error {
+	_, err := db.Exec(`
+		UPDATE instagram_tokens
+		SET last_refresh_attempt_at = NOW(), last_refresh_error = $1, updated_at = NOW()
+		WHERE user_id = $2
+	`, reason, userID)
+	return err
+}
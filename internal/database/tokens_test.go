@@ -0,0 +1,115 @@
+package database
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestTokenEncryptionRoundTrip(t *testing.T) {
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "a long random secret")
+
+	encrypted, err := encryptToken("IGQVJ-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted == "IGQVJ-access-token" {
+		t.Fatal("token stored in plain text")
+	}
+
+	again, err := encryptToken("IGQVJ-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == encrypted {
+		t.Error("encrypting twice gave the same ciphertext, want a fresh nonce each time")
+	}
+
+	token, err := decryptToken(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "IGQVJ-access-token" {
+		t.Errorf("decrypted %q, want the original token", token)
+	}
+}
+
+func TestTokenDecryptionWithWrongKey(t *testing.T) {
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "a long random secret")
+	encrypted, err := encryptToken("IGQVJ-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "another secret")
+	if _, err := decryptToken(encrypted); err == nil {
+		t.Error("decrypted with the wrong key")
+	}
+
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "")
+	if _, err := decryptToken(encrypted); err == nil {
+		t.Error("decrypted without a key")
+	}
+}
+
+func TestTokenDecryptionRejectsTampering(t *testing.T) {
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "a long random secret")
+	encrypted, err := encryptToken("IGQVJ-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := append([]byte(nil), sealed...)
+	flipped[len(flipped)-1] ^= 1
+
+	for name, tampered := range map[string]string{
+		"flipped bit": base64.StdEncoding.EncodeToString(flipped),
+		"truncated":   base64.StdEncoding.EncodeToString(sealed[:len(sealed)-4]),
+		"too short":   base64.StdEncoding.EncodeToString(sealed[:4]),
+		"not base64":  "not base64!",
+	} {
+		if _, err := decryptToken(tampered); err == nil {
+			t.Errorf("%s: decrypted", name)
+		}
+	}
+}
+
+func TestNewTokensAreNotDueForRefresh(t *testing.T) {
+	db := testDB(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TOKEN_ENCRYPTION_KEY", "a long random secret")
+
+	// A seeded token has no known expiry, but is too young to refresh
+	userID := uniqueName("seeded")
+	if err := db.SaveAccessToken(&AccessToken{UserID: userID, AccessToken: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM instagram_tokens WHERE user_id = $1`, userID) })
+
+	now := time.Now()
+	for _, tt := range []struct {
+		issuedBefore time.Time
+		want         bool
+	}{
+		{issuedBefore: now.Add(-24 * time.Hour), want: false},
+		{issuedBefore: now.Add(time.Minute), want: true},
+	} {
+		tokens, err := db.GetTokensDueForRefresh(now.Add(7*24*time.Hour), tt.issuedBefore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		due := false
+		for _, token := range tokens {
+			due = due || token.UserID == userID
+		}
+		if due != tt.want {
+			t.Errorf("issued before %s: due = %v, want %v", tt.issuedBefore, due, tt.want)
+		}
+	}
+}
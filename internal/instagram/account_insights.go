@@ -0,0 +1,263 @@
+package instagram
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Periods account metrics are aggregated over
+const (
+	PeriodDay    = "day"
+	PeriodWeek   = "week"
+	PeriodDays28 = "days_28"
+)
+
+// Audience breakdowns of the follower_demographics metric
+const (
+	BreakdownCity      = "city"
+	BreakdownCountry   = "country"
+	BreakdownAgeGender = "age,gender"
+)
+
+// legacyAudienceMetrics are the pre-v18 demographics metrics, keyed by the
+// breakdown that replaced them
+var legacyAudienceMetrics = map[string]string{
+	BreakdownCity:      "audience_city",
+	BreakdownCountry:   "audience_country",
+	BreakdownAgeGender: "audience_gender_age",
+}
+
+// accountMetric is a metric requested for an account over a period
+type accountMetric struct {
+	Name   string
+	Period string
+	// TotalValue requests metric_type=total_value, which some metrics require
+	TotalValue bool
+}
+
+// accountMetrics are the metrics in AccountInsights. Each is requested on its
+// own because the periods and metric types each one accepts differ.
+var accountMetrics = []accountMetric{
+	{Name: "follower_count", Period: PeriodDay},
+	{Name: "profile_views", Period: PeriodDay, TotalValue: true},
+	{Name: "website_clicks", Period: PeriodDay, TotalValue: true},
+	{Name: "reach", Period: PeriodDay},
+	{Name: "reach", Period: PeriodWeek},
+	{Name: "reach", Period: PeriodDays28},
+}
+
+// AccountInsights are the insights of an Instagram account
+type AccountInsights struct {
+	UserID string `json:"user_id"`
+	// FollowersCount, FollowsCount and MediaCount are the current totals
+	FollowersCount int `json:"followers_count"`
+	FollowsCount   int `json:"follows_count"`
+	MediaCount     int `json:"media_count"`
+	// NewFollowers is the follower_count metric: followers gained in the last day
+	NewFollowers  int `json:"new_followers"`
+	ProfileViews  int `json:"profile_views"`
+	WebsiteClicks int `json:"website_clicks"`
+	// Reach is the number of accounts reached, keyed by period
+	Reach map[string]int `json:"reach"`
+	// Unsupported lists metrics the API doesn't offer for this account, as
+	// metric/period
+	Unsupported []string `json:"unsupported,omitempty"`
+	Timestamp   string   `json:"timestamp"`
+}
+
+// Audience is the make-up of an account's followers. AgeGender is keyed like
+// "F.25-34".
+type Audience struct {
+	Cities    map[string]int `json:"cities"`
+	Countries map[string]int `json:"countries"`
+	AgeGender map[string]int `json:"age_gender"`
+}
+
+// GetAccountInsights gets the follower totals and daily, weekly and 28-day
+// metrics of the client's account. Metrics the API rejects are listed in
+// Unsupported rather than failing the whole request.
+func (c *Client) GetAccountInsights() (*AccountInsights, error) {
+	params := url.Values{}
+	params.Set("fields", "followers_count,follows_count,media_count")
+
+	var profile struct {
+		FollowersCount int `json:"followers_count"`
+		FollowsCount   int `json:"follows_count"`
+		MediaCount     int `json:"media_count"`
+	}
+	if err := c.get(c.UserID, params, &profile); err != nil {
+		return nil, fmt.Errorf("failed to get account %s: %w", c.UserID, err)
+	}
+
+	insights := &AccountInsights{
+		UserID:         c.UserID,
+		FollowersCount: profile.FollowersCount,
+		FollowsCount:   profile.FollowsCount,
+		MediaCount:     profile.MediaCount,
+		Reach:          make(map[string]int),
+		Timestamp:      time.Now().Format(time.RFC3339),
+	}
+
+	for _, metric := range accountMetrics {
+		value, err := c.fetchAccountMetric(metric)
+		if isUnsupportedMetric(err) {
+			insights.Unsupported = append(insights.Unsupported, metric.Name+"/"+metric.Period)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s for account %s: %w", metric.Name, c.UserID, err)
+		}
+
+		switch metric.Name {
+		case "follower_count":
+			insights.NewFollowers = value
+		case "profile_views":
+			insights.ProfileViews = value
+		case "website_clicks":
+			insights.WebsiteClicks = value
+		case "reach":
+			insights.Reach[metric.Period] = value
+		}
+	}
+
+	return insights, nil
+}
+
+// fetchAccountMetric requests a single metric of the client's account
+func (c *Client) fetchAccountMetric(metric accountMetric) (int, error) {
+	params := url.Values{}
+	params.Set("metric", metric.Name)
+	params.Set("period", metric.Period)
+	if metric.TotalValue {
+		params.Set("metric_type", "total_value")
+	}
+
+	values, err := c.fetchInsightValues(c.UserID, params)
+	if err != nil {
+		return 0, err
+	}
+	return values[metric.Name], nil
+}
+
+// isUnsupportedMetric reports whether an insights error means the metric isn't
+// available, either for this account or in this API version
+func isUnsupportedMetric(err error) bool {
+	var graphErr *GraphError
+	if !errors.As(err, &graphErr) {
+		return false
+	}
+	return graphErr.Code == errorCodeInvalidParameter || isNotEnoughData(graphErr)
+}
+
+// GetAudience gets the cities, countries and age and gender of the client's
+// account's followers. Accounts need at least 100 followers for demographics;
+// breakdowns the API won't report are left empty.
+func (c *Client) GetAudience() (*Audience, error) {
+	audience := &Audience{}
+
+	breakdowns := []struct {
+		breakdown string
+		values    *map[string]int
+	}{
+		{BreakdownCity, &audience.Cities},
+		{BreakdownCountry, &audience.Countries},
+		{BreakdownAgeGender, &audience.AgeGender},
+	}
+
+	for _, b := range breakdowns {
+		values, err := c.fetchDemographics(b.breakdown)
+		if isUnsupportedMetric(err) {
+			// Older API versions only have the audience_* metrics
+			values, err = c.fetchLegacyAudience(legacyAudienceMetrics[b.breakdown])
+		}
+		if isUnsupportedMetric(err) {
+			values, err = map[string]int{}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get audience %s for account %s: %w", b.breakdown, c.UserID, err)
+		}
+		*b.values = values
+	}
+
+	return audience, nil
+}
+
+// fetchDemographics requests the follower_demographics metric with a breakdown
+func (c *Client) fetchDemographics(breakdown string) (map[string]int, error) {
+	params := url.Values{}
+	params.Set("metric", "follower_demographics")
+	params.Set("period", "lifetime")
+	params.Set("metric_type", "total_value")
+	params.Set("breakdown", breakdown)
+
+	var response struct {
+		Data []struct {
+			TotalValue struct {
+				Breakdowns []struct {
+					DimensionKeys []string `json:"dimension_keys"`
+					Results       []struct {
+						DimensionValues []string `json:"dimension_values"`
+						Value           int      `json:"value"`
+					} `json:"results"`
+				} `json:"breakdowns"`
+			} `json:"total_value"`
+		} `json:"data"`
+	}
+
+	if err := c.get(fmt.Sprintf("%s/insights", c.UserID), params, &response); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]int)
+	for _, metric := range response.Data {
+		for _, b := range metric.TotalValue.Breakdowns {
+			for _, result := range b.Results {
+				values[demographicKey(b.DimensionKeys, result.DimensionValues)] += result.Value
+			}
+		}
+	}
+	return values, nil
+}
+
+// demographicKey joins dimension values into a single key. Age and gender are
+// joined as gender.age to match the legacy audience_gender_age keys.
+func demographicKey(keys, values []string) string {
+	if len(keys) == 2 && len(values) == 2 && keys[0] == "age" && keys[1] == "gender" {
+		return values[1] + "." + values[0]
+	}
+	return strings.Join(values, ".")
+}
+
+// fetchLegacyAudience requests one of the lifetime audience_* metrics, whose
+// value is an object of counts
+func (c *Client) fetchLegacyAudience(metric string) (map[string]int, error) {
+	params := url.Values{}
+	params.Set("metric", metric)
+	params.Set("period", "lifetime")
+
+	var response struct {
+		Data []struct {
+			Values []struct {
+				Value map[string]float64 `json:"value"`
+			} `json:"values"`
+		} `json:"data"`
+	}
+
+	if err := c.get(fmt.Sprintf("%s/insights", c.UserID), params, &response); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]int)
+	for _, metric := range response.Data {
+		if len(metric.Values) == 0 {
+			continue
+		}
+		for key, value := range metric.Values[len(metric.Values)-1].Value {
+			values[key] = metricValue(value)
+		}
+	}
+	return values, nil
+}
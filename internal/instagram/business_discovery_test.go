@@ -0,0 +1,78 @@
+package instagram_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     string
+		valid    bool
+	}{
+		{" @tech.news_daily ", "tech.news_daily", true},
+		{"a", "a", true},
+		{"abcdefghijklmnopqrstuvwxyz1234", "abcdefghijklmnopqrstuvwxyz1234", true},
+		{"abcdefghijklmnopqrstuvwxyz12345", "", false},
+		{"", "", false},
+		{"@", "", false},
+		{"bad name", "", false},
+		{"x){id},business_discovery.username(y", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := instagram.NormalizeUsername(tt.username)
+		if tt.valid && (err != nil || got != tt.want) {
+			t.Errorf("NormalizeUsername(%q) = %q, %v; want %q", tt.username, got, err, tt.want)
+		}
+		if !tt.valid && !errors.Is(err, instagram.ErrInvalidUsername) {
+			t.Errorf("NormalizeUsername(%q) = %q, %v; want ErrInvalidUsername", tt.username, got, err)
+		}
+	}
+}
+
+func TestDiscoverBusinessRejectsInvalidUsername(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+
+	_, err := server.Client().DiscoverBusiness("x){id}", 5)
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindInvalidParameter {
+		t.Errorf("err = %v, want an invalid parameter", err)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("invalid username reached the server")
+	}
+}
+
+func TestDiscoverBusiness(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	account := server.AddBusinessAccount(instagramtest.BusinessAccount{
+		Username:       "Byte_Sized_Jokes",
+		FollowersCount: 5400,
+		Media: []instagram.PublishedMedia{
+			{Caption: "newest", LikeCount: 10},
+			{Caption: "older", LikeCount: 20},
+			{Caption: "oldest", LikeCount: 30},
+		},
+	})
+
+	profile, err := server.Client().DiscoverBusiness("@byte_sized_jokes", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.ID != account.ID || profile.FollowersCount != 5400 || profile.MediaCount != 3 {
+		t.Errorf("profile = %+v, want %+v", profile, account)
+	}
+	if len(profile.Media) != 2 || profile.Media[0].Caption != "newest" {
+		t.Errorf("media = %+v, want the two newest", profile.Media)
+	}
+
+	_, err = server.Client().DiscoverBusiness("nobody_here", 2)
+	var graphErr *instagram.GraphError
+	if !errors.As(err, &graphErr) || graphErr.ErrorSubcode != 2207013 {
+		t.Errorf("err = %v, want user not found", err)
+	}
+}
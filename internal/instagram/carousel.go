@@ -0,0 +1,95 @@
+package instagram
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	minCarouselItems = 2
+	maxCarouselItems = 10
+)
+
+// CarouselItem is a single slide of a carousel post.
+// Exactly one of ImageURL and VideoURL must be set.
+type CarouselItem struct {
+	ImageURL string `json:"image_url,omitempty"`
+	VideoURL string `json:"video_url,omitempty"`
+}
+
+// PostCarousel publishes a carousel post and returns the published media ID.
+// With video slides this blocks for up to ReelTimeout.
+func (c *Client) PostCarousel(caption string, items []CarouselItem) (string, error) {
+	containerID, err := c.CreateCarouselContainer(caption, items)
+	if err != nil {
+		return "", err
+	}
+
+	return c.PublishContainer(containerID)
+}
+
+// CreateCarouselContainer creates a container per slide, waits for them to
+// finish processing, then creates the carousel container referencing them and
+// waits for it to be ready to publish
+func (c *Client) CreateCarouselContainer(caption string, items []CarouselItem) (string, error) {
+	if len(items) < minCarouselItems || len(items) > maxCarouselItems {
+		return "", fmt.Errorf("carousel must have between %d and %d items, got %d",
+			minCarouselItems, maxCarouselItems, len(items))
+	}
+
+	childIDs := make([]string, 0, len(items))
+	for i, item := range items {
+		params := url.Values{}
+		params.Set("is_carousel_item", "true")
+
+		switch {
+		case item.ImageURL != "" && item.VideoURL == "":
+			params.Set("image_url", item.ImageURL)
+		case item.VideoURL != "" && item.ImageURL == "":
+			params.Set("media_type", MediaTypeVideo)
+			params.Set("video_url", item.VideoURL)
+		default:
+			return "", fmt.Errorf("carousel item %d must have exactly one of image_url and video_url", i+1)
+		}
+
+		childID, err := c.CreateMediaContainer(params)
+		if err != nil {
+			return "", fmt.Errorf("carousel item %d: %w", i+1, err)
+		}
+		childIDs = append(childIDs, childID)
+	}
+
+	// Video slides take minutes to process, like Reels, and so does a carousel
+	// holding them
+	timeout := c.PublishTimeout
+	for _, item := range items {
+		if item.VideoURL != "" {
+			timeout = c.ReelTimeout
+		}
+	}
+
+	// Children are processed in parallel by Instagram, so waiting on each in
+	// turn costs no more than the slowest one
+	for i, childID := range childIDs {
+		if _, err := c.WaitForContainer(childID, timeout); err != nil {
+			return "", fmt.Errorf("carousel item %d: %w", i+1, err)
+		}
+	}
+
+	params := url.Values{}
+	params.Set("media_type", "CAROUSEL")
+	params.Set("caption", caption)
+	params.Set("children", strings.Join(childIDs, ","))
+
+	containerID, err := c.CreateMediaContainer(params)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := c.WaitForContainer(containerID, timeout); err != nil {
+		return "", err
+	}
+
+	return containerID, nil
+}
@@ -0,0 +1,63 @@
+package instagram_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+func TestPostCarousel(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+
+	mediaID, err := server.Client().PostCarousel("slides", []instagram.CarouselItem{
+		{ImageURL: "https://example.com/1.jpg"},
+		{VideoURL: "https://example.com/2.mp4"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	media, ok := server.Media(mediaID)
+	if !ok || media.Caption != "slides" || media.MediaType != instagram.MediaTypeCarouselAlbum {
+		t.Fatalf("published media = %+v, %v", media, ok)
+	}
+}
+
+func TestPostCarouselRejectsBadItems(t *testing.T) {
+	client := newAccountServer(t, instagramtest.DefaultUserID).Client()
+
+	image := instagram.CarouselItem{ImageURL: "https://example.com/1.jpg"}
+	both := instagram.CarouselItem{ImageURL: "https://example.com/1.jpg", VideoURL: "https://example.com/1.mp4"}
+	for name, items := range map[string][]instagram.CarouselItem{
+		"one item":     {image},
+		"eleven items": {image, image, image, image, image, image, image, image, image, image, image},
+		"both urls":    {image, both},
+		"no url":       {image, {}},
+	} {
+		if _, err := client.PostCarousel("slides", items); err == nil {
+			t.Errorf("%s: published", name)
+		}
+	}
+}
+
+func TestPostCarouselGivesVideoTheReelTimeout(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	server.ProcessingPolls = 3
+	client := server.Client()
+	client.PollInterval = 10 * time.Millisecond
+	client.PublishTimeout = 15 * time.Millisecond
+	client.ReelTimeout = 5 * time.Second
+
+	video := []instagram.CarouselItem{{ImageURL: "https://example.com/1.jpg"}, {VideoURL: "https://example.com/2.mp4"}}
+	if _, err := client.PostCarousel("video slides", video); err != nil {
+		t.Errorf("video carousel: %v", err)
+	}
+
+	images := []instagram.CarouselItem{{ImageURL: "https://example.com/1.jpg"}, {ImageURL: "https://example.com/2.jpg"}}
+	if _, err := client.PostCarousel("image slides", images); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("image carousel: err = %v, want a timeout after PublishTimeout", err)
+	}
+}
@@ -2,11 +2,14 @@ package instagram
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,30 +18,149 @@ type Client struct {
 	AccessToken string
 	UserID      string
 	BaseURL     string
+	// TokenBaseURL serves the token endpoints, which are not versioned
+	TokenBaseURL string
+	// FacebookBaseURL and FacebookAccessToken are used for the calls only the
+	// Instagram API with Facebook Login serves: hashtag search, business
+	// discovery and the tags edge. The token is a Facebook user or Page access
+	// token with access to the Facebook Page linked to the account. Without it
+	// those calls fail with ErrFacebookLoginRequired.
+	FacebookBaseURL     string
+	FacebookAccessToken string
+	// HTTPClient sends the requests; nil means a client with defaultHTTPTimeout
+	HTTPClient *http.Client
+	// PollInterval and PublishTimeout control how media containers are polled while publishing
+	PollInterval   time.Duration
+	PublishTimeout time.Duration
+	// ReelTimeout is how long video containers are given to process
+	ReelTimeout time.Duration
+	// ThrottlePercent and DeferPercent are the API usage levels at which calls
+	// are slowed down and refused respectively
+	ThrottlePercent int
+	DeferPercent    int
+	// HashtagLedger keeps the account under the weekly hashtag limit; nil
+	// means hashtag queries aren't counted
+	HashtagLedger HashtagLedger
 }
 
-// MediaInsights represents insights for a media object
-type MediaInsights struct {
-	ID          string `json:"id"`
-	Engagement  int    `json:"engagement"`
-	Impressions int    `json:"impressions"`
-	Reach       int    `json:"reach"`
-	Saved       int    `json:"saved"`
-	Timestamp   string `json:"timestamp"`
-}
+// Media types reported by the Graph API
+const (
+	MediaTypeImage         = "IMAGE"
+	MediaTypeVideo         = "VIDEO"
+	MediaTypeCarouselAlbum = "CAROUSEL_ALBUM"
+)
+
+// mediaFields are the fields requested for media objects, including carousel children
+const mediaFields = "id,caption,media_type,media_product_type,media_url,permalink,thumbnail_url,timestamp,username," +
+	"children{id,media_type,media_url,thumbnail_url}"
 
 // Media represents an Instagram media object
 type Media struct {
 	ID        string `json:"id"`
 	Caption   string `json:"caption"`
 	MediaType string `json:"media_type"`
-	MediaURL  string `json:"media_url"`
-	Permalink string `json:"permalink"`
-	Timestamp string `json:"timestamp"`
-	Username  string `json:"username"`
+	// MediaProductType distinguishes FEED, STORY and REELS media
+	MediaProductType string `json:"media_product_type,omitempty"`
+	MediaURL         string `json:"media_url"`
+	ThumbnailURL     string `json:"thumbnail_url,omitempty"`
+	Permalink        string `json:"permalink"`
+	Timestamp        string `json:"timestamp"`
+	Username         string `json:"username"`
+	// Children holds the slides of a CAROUSEL_ALBUM in order
+	Children []Media `json:"children,omitempty"`
+}
+
+// IsCarousel reports whether the media is a carousel album
+func (m *Media) IsCarousel() bool {
+	return m.MediaType == MediaTypeCarouselAlbum
+}
+
+// PublishedAt parses the media timestamp
+func (m *Media) PublishedAt() (time.Time, error) {
+	return time.Parse(mediaTimestampLayout, m.Timestamp)
+}
+
+// UnmarshalJSON flattens the Graph API's {"children": {"data": [...]}} edge into Children
+func (m *Media) UnmarshalJSON(data []byte) error {
+	type media Media
+	var raw struct {
+		media
+		Children json.RawMessage `json:"children"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*m = Media(raw.media)
+	m.Children = nil
+
+	if len(raw.Children) == 0 || string(raw.Children) == "null" {
+		return nil
+	}
+
+	// Accept both the Graph API edge and our own flattened form
+	var edge struct {
+		Data []Media `json:"data"`
+	}
+	if err := json.Unmarshal(raw.Children, &edge); err == nil {
+		m.Children = edge.Data
+		return nil
+	}
+	return json.Unmarshal(raw.Children, &m.Children)
 }
 
-// NewClient creates a new Instagram client
+// ErrFacebookLoginRequired is returned for calls that need a Facebook access
+// token when the client has none
+var ErrFacebookLoginRequired = errors.New("this call needs the Instagram API with Facebook Login, but FACEBOOK_ACCESS_TOKEN is not set")
+
+// defaultHTTPTimeout bounds every Graph API request, so a stalled
+// connection can't hang the scheduler
+const defaultHTTPTimeout = 30 * time.Second
+
+// defaultHTTPClient is used by clients without an HTTPClient
+var defaultHTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+
+const (
+	// defaultGraphURL is the Graph API host; INSTAGRAM_GRAPH_URL overrides it,
+	// e.g. to run against a fake server
+	defaultGraphURL = "https://graph.instagram.com"
+	// defaultFacebookGraphURL is the Facebook Login host; FACEBOOK_GRAPH_URL overrides it
+	defaultFacebookGraphURL = "https://graph.facebook.com"
+	graphAPIVersion         = "v12.0"
+)
+
+// NewHTTPClient creates the HTTP client Graph API requests are sent with. Its
+// timeout is defaultHTTPTimeout unless INSTAGRAM_HTTP_TIMEOUT sets one, e.g. 45s.
+func NewHTTPClient() (*http.Client, error) {
+	timeout := defaultHTTPTimeout
+	if raw := os.Getenv("INSTAGRAM_HTTP_TIMEOUT"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid INSTAGRAM_HTTP_TIMEOUT: %q", raw)
+		}
+		timeout = parsed
+	}
+	return &http.Client{Timeout: timeout}, nil
+}
+
+// graphURL returns the Graph API host without a trailing slash
+func graphURL() string {
+	if raw := os.Getenv("INSTAGRAM_GRAPH_URL"); raw != "" {
+		return strings.TrimRight(raw, "/")
+	}
+	return defaultGraphURL
+}
+
+// facebookGraphURL returns the Facebook Login host without a trailing slash
+func facebookGraphURL() string {
+	if raw := os.Getenv("FACEBOOK_GRAPH_URL"); raw != "" {
+		return strings.TrimRight(raw, "/")
+	}
+	return defaultFacebookGraphURL
+}
+
+// NewClient creates a new Instagram client for INSTAGRAM_USER_ID using the
+// INSTAGRAM_ACCESS_TOKEN token
 func NewClient() (*Client, error) {
 	accessToken := os.Getenv("INSTAGRAM_ACCESS_TOKEN")
 	if accessToken == "" {
@@ -50,93 +172,207 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("INSTAGRAM_USER_ID environment variable not set")
 	}
 
-	return &Client{
-		AccessToken: accessToken,
-		UserID:      userID,
-		BaseURL:     "https://graph.instagram.com/v12.0",
-	}, nil
+	return NewClientFor(userID, accessToken)
+}
+
+// NewClientFor creates a new Instagram client for the given account and access
+// token, e.g. one loaded from the token store. Calls that need Facebook Login
+// use FACEBOOK_ACCESS_TOKEN if it is set.
+func NewClientFor(userID, accessToken string) (*Client, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("instagram user ID is required")
+	}
+	if accessToken == "" {
+		return nil, fmt.Errorf("access token is required")
+	}
+
+	httpClient, err := NewHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		AccessToken:         accessToken,
+		UserID:              userID,
+		BaseURL:             graphURL() + "/" + graphAPIVersion,
+		TokenBaseURL:        graphURL(),
+		FacebookBaseURL:     facebookGraphURL() + "/" + graphAPIVersion,
+		FacebookAccessToken: os.Getenv("FACEBOOK_ACCESS_TOKEN"),
+		HTTPClient:          httpClient,
+		PollInterval:        defaultPollInterval,
+		PublishTimeout:      defaultPublishTimeout,
+		ReelTimeout:         defaultReelTimeout,
+		ThrottlePercent:     defaultThrottlePercent,
+		DeferPercent:        defaultDeferPercent,
+	}
+
+	if raw := os.Getenv("INSTAGRAM_THROTTLE_PERCENT"); raw != "" {
+		percent, err := strconv.Atoi(raw)
+		if err != nil || percent <= 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid INSTAGRAM_THROTTLE_PERCENT: %q", raw)
+		}
+		client.ThrottlePercent = percent
+	}
+	if raw := os.Getenv("INSTAGRAM_DEFER_PERCENT"); raw != "" {
+		percent, err := strconv.Atoi(raw)
+		if err != nil || percent <= 0 || percent > 100 {
+			return nil, fmt.Errorf("invalid INSTAGRAM_DEFER_PERCENT: %q", raw)
+		}
+		client.DeferPercent = percent
+	}
+
+	return client, nil
 }
 
 // GetRecentMedia gets the most recent media from the user's Instagram account
 func (c *Client) GetRecentMedia() ([]Media, error) {
-	endpoint := fmt.Sprintf("%s/%s/media", c.BaseURL, c.UserID)
+	return c.ListMedia(MediaQuery{Limit: defaultMediaPageSize})
+}
 
-	// Build query parameters
-	params := url.Values{}
-	params.Add("fields", "id,caption,media_type,media_url,permalink,thumbnail_url,timestamp,username")
-	params.Add("access_token", c.AccessToken)
+// get performs a GET request against the Graph API and decodes the JSON response into out
+func (c *Client) get(path string, params url.Values, out interface{}) error {
+	return c.getFrom(c.BaseURL, c.AccessToken, path, params, out)
+}
+
+// getFacebook performs a GET request against the Facebook Login host, for
+// the calls graph.instagram.com doesn't serve
+func (c *Client) getFacebook(path string, params url.Values, out interface{}) error {
+	if c.FacebookAccessToken == "" {
+		return ErrFacebookLoginRequired
+	}
+	return c.getFrom(c.FacebookBaseURL, c.FacebookAccessToken, path, params, out)
+}
+
+// getFrom performs a GET request against baseURL with token
+func (c *Client) getFrom(baseURL, token, path string, params url.Values, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("access_token", token)
 
-	// Make the request
-	resp, err := http.Get(endpoint + "?" + params.Encode())
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s?%s", baseURL, path, params.Encode()), nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	return c.do(req, out)
+}
+
+// post performs a form-encoded POST request against the Graph API and decodes the JSON response into out
+func (c *Client) post(path string, params url.Values, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("access_token", c.AccessToken)
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/%s", c.BaseURL, path), strings.NewReader(params.Encode()))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	// Parse the response
-	var response struct {
-		Data   []Media `json:"data"`
-		Paging struct {
-			Cursors struct {
-				Before string `json:"before"`
-				After  string `json:"after"`
-			} `json:"cursors"`
-			Next string `json:"next"`
-		} `json:"paging"`
+	return c.do(req, out)
+}
+
+// delete performs a DELETE request against the Graph API and decodes the JSON response into out
+func (c *Client) delete(path string, params url.Values, out interface{}) error {
+	if params == nil {
+		params = url.Values{}
 	}
+	params.Set("access_token", c.AccessToken)
 
-	err = json.Unmarshal(body, &response)
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%s?%s", c.BaseURL, path, params.Encode()), nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return response.Data, nil
+	return c.do(req, out)
 }
 
-// GetMediaInsights gets insights for a specific media
-func (c *Client) GetMediaInsights(mediaID string) (*MediaInsights, error) {
-	endpoint := fmt.Sprintf("%s/%s/insights", c.BaseURL, mediaID)
+// maxGetRetries is how many times a GET is retried after a transient error.
+// Writes are never retried automatically since publishing twice is worse than failing.
+const maxGetRetries = 2
 
-	// Build query parameters
-	params := url.Values{}
-	params.Add("metric", "engagement,impressions,reach,saved")
-	params.Add("access_token", c.AccessToken)
+// do sends the request and decodes the response, turning Graph API error payloads
+// and non-2xx statuses into *GraphError
+func (c *Client) do(req *http.Request, out interface{}) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = c.doOnce(req, out)
+		if err == nil || req.Method != http.MethodGet || attempt >= maxGetRetries {
+			return err
+		}
 
-	// Make the request
-	resp, err := http.Get(endpoint + "?" + params.Encode())
+		kind := ErrorKindOf(err)
+		if kind != ErrorKindTransient && kind != ErrorKindNetwork {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+}
+
+// networkError wraps a transport error as a GraphError. The request URL is
+// left out of the message because it carries the access token.
+func networkError(req *http.Request, statusCode int, err error) *GraphError {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return &GraphError{
+		StatusCode: statusCode,
+		Message:    fmt.Sprintf("%s %s: %v", req.Method, req.URL.Path, err),
+		Err:        err,
+	}
+}
+
+// doOnce sends the request a single time, subject to throttling, and records
+// the usage Instagram reports back
+func (c *Client) doOnce(req *http.Request, out interface{}) error {
+	if err := c.throttle(); err != nil {
+		return err
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return networkError(req, 0, err)
 	}
 	defer resp.Body.Close()
 
-	// We're not using the response body in this mock implementation
-	// Just read it to properly close the connection
-	_, _ = ioutil.ReadAll(resp.Body)
+	recordUsage(c.UserID, resp.Header)
 
-	// In a real implementation, you would parse the actual response
-	// For now, we'll return mock data
-	insights := &MediaInsights{
-		ID:          mediaID,
-		Engagement:  100 + (time.Now().Day() * 10), // Random-ish number
-		Impressions: 1000 + (time.Now().Day() * 100),
-		Reach:       800 + (time.Now().Day() * 80),
-		Saved:       50 + (time.Now().Day() * 5),
-		Timestamp:   time.Now().Format(time.RFC3339),
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return networkError(req, resp.StatusCode, err)
 	}
 
-	return insights, nil
-}
+	var errorResponse struct {
+		Error *GraphError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorResponse); err == nil && errorResponse.Error != nil {
+		errorResponse.Error.StatusCode = resp.StatusCode
+		if errorResponse.Error.Kind() == ErrorKindRateLimit {
+			recordRateLimited(c.UserID, errorResponse.Error)
+		}
+		return errorResponse.Error
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &GraphError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("request failed with status %d", resp.StatusCode),
+		}
+	}
 
-// PostContent posts content to Instagram (note: this is a simplified example)
-// In reality, posting to Instagram requires a Facebook Page, Instagram Business Account,
-// and content hosting. This is just a placeholder for the API structure.
-func (c *Client) PostContent(caption string, imageURL string) (string, error) {
-	// This is a placeholder - in reality, posting to Instagram via API is more complex
-	// and requires multiple steps including uploading media to Facebook first
-	return fmt.Sprintf("Post created with caption: %s", caption), nil
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("invalid graph api response: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,310 @@
+package instagram_test
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+// mediaTimestamp formats a time the way the Graph API does
+func mediaTimestamp(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05-0700")
+}
+
+func TestIterateMediaPagesWithinWindow(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	now := time.Now().Truncate(time.Second)
+	for i := 0; i < 10; i++ {
+		server.AddMedia(instagram.Media{
+			Caption:   fmt.Sprintf("post %d", i),
+			Timestamp: mediaTimestamp(now.Add(-time.Duration(i) * 24 * time.Hour)),
+		})
+	}
+
+	media, err := server.Client().ListMedia(instagram.MediaQuery{
+		PageSize: 2,
+		Since:    now.Add(-7*24*time.Hour - time.Minute),
+		Until:    now.Add(-24*time.Hour + time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var captions []string
+	for _, m := range media {
+		captions = append(captions, m.Caption)
+	}
+	want := "post 1,post 2,post 3,post 4,post 5,post 6,post 7"
+	if got := strings.Join(captions, ","); got != want {
+		t.Errorf("captions = %s, want %s", got, want)
+	}
+
+	pages := 0
+	for _, req := range server.Requests() {
+		if req.Path == server.UserID+"/media" {
+			pages++
+		}
+	}
+	if pages != 4 {
+		t.Errorf("fetched %d pages, want 4", pages)
+	}
+}
+
+func TestIterateMediaStopsAtLimit(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	for i := 0; i < 5; i++ {
+		server.AddMedia(instagram.Media{Caption: fmt.Sprintf("post %d", i)})
+	}
+
+	media, err := server.Client().ListMedia(instagram.MediaQuery{Limit: 3, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(media) != 3 {
+		t.Errorf("got %d media, want 3", len(media))
+	}
+}
+
+func TestPostContentPollsAndPublishes(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	server.ProcessingPolls = 2
+
+	mediaID, err := server.Client().PostContent("Hello", "https://example.com/a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	media, ok := server.Media(mediaID)
+	if !ok || media.Caption != "Hello" || media.MediaURL != "https://example.com/a.jpg" {
+		t.Fatalf("published media = %+v, %v", media, ok)
+	}
+
+	polls := 0
+	for _, req := range server.Requests() {
+		if req.Method == "GET" && req.Params.Get("fields") == "id,status_code,status" {
+			polls++
+		}
+	}
+	if polls != 3 {
+		t.Errorf("polled %d times, want 3", polls)
+	}
+}
+
+func TestPostContentReportsFailedContainer(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	server.FailNextContainer("ERROR: image could not be downloaded")
+
+	_, err := server.Client().PostContent("Hello", "https://example.com/a.jpg")
+	var publishErr *instagram.PublishError
+	if !errors.As(err, &publishErr) || publishErr.StatusCode != instagram.ContainerStatusError {
+		t.Fatalf("err = %v, want a *PublishError", err)
+	}
+
+	container, _ := server.Container(publishErr.ContainerID)
+	if container.MediaID != "" {
+		t.Errorf("failed container was published as %s", container.MediaID)
+	}
+}
+
+func TestPublishContainerRefusesUnfinishedContainer(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+
+	containerID, err := client.CreateMediaContainer(url.Values{"image_url": {"https://example.com/a.jpg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.PublishContainer(containerID)
+	var graphErr *instagram.GraphError
+	if !errors.As(err, &graphErr) || graphErr.Code != 9007 {
+		t.Fatalf("err = %v, want media not ready", err)
+	}
+
+	if _, err := client.WaitForContainer(containerID, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PublishContainer(containerID); err != nil {
+		t.Errorf("publishing the finished container: %v", err)
+	}
+}
+
+func TestCommentOperations(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+	media := server.AddMedia(instagram.Media{Caption: "post"})
+
+	first, err := server.AddComment(media.ID, "devnull_dan", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := server.AddComment(media.ID, "sudo_sarah", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comments, err := client.ListComments(media.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[0].ID != first || comments[1].ID != second {
+		t.Fatalf("comments = %+v, want first and second", comments)
+	}
+
+	replyID, err := client.ReplyToComment(first, "thanks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replies, err := client.ListReplies(first, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1 || replies[0].ID != replyID || replies[0].From.ID != server.UserID || replies[0].ParentID != first {
+		t.Errorf("replies = %+v, want our reply", replies)
+	}
+
+	if err := client.SetCommentHidden(second, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteComment(first); err != nil {
+		t.Fatal(err)
+	}
+
+	remaining := server.Comments(media.ID)
+	if len(remaining) != 1 || remaining[0].ID != second || !remaining[0].Hidden {
+		t.Errorf("comments left = %+v, want second hidden", remaining)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+
+	server.FailNext("", "", instagramtest.ExpiredTokenError())
+	_, err := client.GetMedia("1")
+	var graphErr *instagram.GraphError
+	if !errors.As(err, &graphErr) || graphErr.Code != 190 {
+		t.Fatalf("err = %v, want the Graph API error", err)
+	}
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindOAuth || instagram.IsRetryable(err) {
+		t.Errorf("expired token: kind %s, retryable %v", instagram.ErrorKindOf(err), instagram.IsRetryable(err))
+	}
+
+	server.FailNext("", "", instagramtest.RateLimitError())
+	_, err = client.GetMedia("1")
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindRateLimit {
+		t.Errorf("rate limit: kind = %s", instagram.ErrorKindOf(err))
+	}
+	instagram.ResetUsage()
+
+	_, err = client.GetMedia("1")
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindInvalidParameter {
+		t.Errorf("unknown media: kind = %s", instagram.ErrorKindOf(err))
+	}
+}
+
+func TestGetRetriesTransientErrors(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	media := server.AddMedia(instagram.Media{Caption: "post"})
+	server.FailNext("GET", media.ID, instagramtest.TransientError())
+
+	got, err := server.Client().GetMedia(media.ID)
+	if err != nil {
+		t.Fatalf("GetMedia after a transient error: %v", err)
+	}
+	if got.ID != media.ID {
+		t.Errorf("got media %s, want %s", got.ID, media.ID)
+	}
+	if requests := len(server.Requests()); requests != 2 {
+		t.Errorf("sent %d requests, want 2", requests)
+	}
+}
+
+func TestWritesAreNotRetried(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	media := server.AddMedia(instagram.Media{Caption: "post"})
+	commentID, err := server.AddComment(media.ID, "devnull_dan", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.FailNext("POST", "", instagramtest.TransientError())
+
+	_, err = server.Client().ReplyToComment(commentID, "hello")
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindTransient || !instagram.IsRetryable(err) {
+		t.Errorf("err = %v, want a retryable transient error", err)
+	}
+	if requests := len(server.Requests()); requests != 1 {
+		t.Errorf("sent %d requests, want 1", requests)
+	}
+}
+
+func TestNetworkErrorHidesAccessToken(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+	server.Close()
+
+	err := client.DeleteComment("1")
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindNetwork || !instagram.IsRetryable(err) {
+		t.Errorf("err = %v, want a retryable network error", err)
+	}
+	if strings.Contains(err.Error(), server.AccessToken) {
+		t.Errorf("error %q leaks the access token", err)
+	}
+}
+
+func TestUsageHeadersAreRecorded(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	server.CallLimit = 4
+	client := server.Client()
+
+	usage, err := client.RefreshUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.App == nil || usage.App.CallCount != 25 {
+		t.Fatalf("app usage = %+v, want 25%% of calls", usage.App)
+	}
+
+	server.SetBusinessUsage(instagram.UsageReading{Type: "instagram", CallCount: 60, TotalTime: 70})
+	usage, err = client.RefreshUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage.Business) != 1 || usage.Business[0].Percent() != 70 || usage.Business[0].AccountID != server.UserID {
+		t.Errorf("business usage = %+v, want 70%% for the account", usage.Business)
+	}
+	if usage.Percent != 70 {
+		t.Errorf("Percent = %d, want 70", usage.Percent)
+	}
+}
+
+func TestExchangeToken(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+
+	token, err := instagram.ExchangeToken(server.Server.Client(), server.URL, server.AccessToken, "app-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != server.AccessToken {
+		t.Errorf("AccessToken = %q, want the fake's token", token.AccessToken)
+	}
+	if until := time.Until(token.ExpiresAt); until < 59*24*time.Hour {
+		t.Errorf("token expires in %s, want about 60 days", until)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "access_token" ||
+		requests[0].Params.Get("grant_type") != "ig_exchange_token" || requests[0].Params.Get("client_secret") != "app-secret" {
+		t.Errorf("requests = %+v, want one ig_exchange_token request", requests)
+	}
+
+	if _, err := instagram.ExchangeToken(server.Server.Client(), server.URL, "short-lived", "app-secret"); instagram.ErrorKindOf(err) != instagram.ErrorKindOAuth {
+		t.Errorf("invalid token: err = %v, want an oauth error", err)
+	}
+}
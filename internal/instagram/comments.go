@@ -0,0 +1,256 @@
+package instagram
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultCommentPageSize = 50
+	maxCommentPageSize     = 100
+)
+
+// commentFields are the fields requested for comments. The first page of
+// replies is requested along with each comment.
+const commentFields = "id,text,username,timestamp,like_count,hidden,from{id,username},parent_id," +
+	"replies{id,text,username,timestamp,like_count,hidden,from{id,username},parent_id}"
+
+// replyFields are the fields requested for replies, which can't have replies themselves
+const replyFields = "id,text,username,timestamp,like_count,hidden,from{id,username},parent_id"
+
+// Comment represents a comment or a reply to one
+type Comment struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Username  string `json:"username"`
+	Timestamp string `json:"timestamp"`
+	LikeCount int    `json:"like_count"`
+	Hidden    bool   `json:"hidden"`
+	From      struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	// ParentID is set on replies
+	ParentID string `json:"parent_id,omitempty"`
+	// Replies holds the first page of replies; use ListReplies for all of them
+	Replies []Comment `json:"replies,omitempty"`
+}
+
+// CommentedAt parses the comment timestamp
+func (c *Comment) CommentedAt() (time.Time, error) {
+	return time.Parse(mediaTimestampLayout, c.Timestamp)
+}
+
+// UnmarshalJSON flattens the Graph API's {"replies": {"data": [...]}} edge into Replies
+func (c *Comment) UnmarshalJSON(data []byte) error {
+	type comment Comment
+	var raw struct {
+		comment
+		Replies json.RawMessage `json:"replies"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*c = Comment(raw.comment)
+	c.Replies = nil
+
+	if len(raw.Replies) == 0 || string(raw.Replies) == "null" {
+		return nil
+	}
+
+	var edge struct {
+		Data []Comment `json:"data"`
+	}
+	if err := json.Unmarshal(raw.Replies, &edge); err == nil {
+		c.Replies = edge.Data
+		return nil
+	}
+	return json.Unmarshal(raw.Replies, &c.Replies)
+}
+
+// CommentIterator walks the comments on a media, or the replies to a comment,
+// fetching pages lazily. It is used like MediaIterator.
+type CommentIterator struct {
+	client   *Client
+	path     string
+	fields   string
+	limit    int
+	pageSize int
+	page     []Comment
+	index    int
+	after    string
+	done     bool
+	count    int
+	current  Comment
+	err      error
+}
+
+// IterateComments returns an iterator over the top-level comments on a media.
+// A limit of zero means no limit.
+func (c *Client) IterateComments(mediaID string, limit int) *CommentIterator {
+	return c.newCommentIterator(fmt.Sprintf("%s/comments", mediaID), commentFields, limit)
+}
+
+// IterateReplies returns an iterator over the replies to a comment.
+// A limit of zero means no limit.
+func (c *Client) IterateReplies(commentID string, limit int) *CommentIterator {
+	return c.newCommentIterator(fmt.Sprintf("%s/replies", commentID), replyFields, limit)
+}
+
+func (c *Client) newCommentIterator(path, fields string, limit int) *CommentIterator {
+	pageSize := defaultCommentPageSize
+	if limit > 0 && limit < pageSize {
+		pageSize = limit
+	}
+
+	return &CommentIterator{client: c, path: path, fields: fields, limit: limit, pageSize: pageSize}
+}
+
+// ListComments collects the top-level comments on a media, each with its first page of replies
+func (c *Client) ListComments(mediaID string, limit int) ([]Comment, error) {
+	var comments []Comment
+	it := c.IterateComments(mediaID, limit)
+	for it.Next() {
+		comments = append(comments, it.Comment())
+	}
+	return comments, it.Err()
+}
+
+// ListReplies collects the replies to a comment
+func (c *Client) ListReplies(commentID string, limit int) ([]Comment, error) {
+	var replies []Comment
+	it := c.IterateReplies(commentID, limit)
+	for it.Next() {
+		replies = append(replies, it.Comment())
+	}
+	return replies, it.Err()
+}
+
+// Next advances to the next comment, fetching the next page when needed
+func (it *CommentIterator) Next() bool {
+	for {
+		if it.err != nil || (it.limit > 0 && it.count >= it.limit) {
+			return false
+		}
+
+		if it.index >= len(it.page) {
+			if it.done {
+				return false
+			}
+			if err := it.fetchPage(); err != nil {
+				it.err = err
+				return false
+			}
+			continue
+		}
+
+		it.current = it.page[it.index]
+		it.index++
+		it.count++
+		return true
+	}
+}
+
+// Comment returns the comment at the current position
+func (it *CommentIterator) Comment() Comment {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *CommentIterator) Err() error {
+	return it.err
+}
+
+// fetchPage loads the next page of comments
+func (it *CommentIterator) fetchPage() error {
+	params := url.Values{}
+	params.Set("fields", it.fields)
+	params.Set("limit", strconv.Itoa(it.pageSize))
+	if it.after != "" {
+		params.Set("after", it.after)
+	}
+
+	var response struct {
+		Data   []Comment `json:"data"`
+		Paging struct {
+			Cursors struct {
+				After string `json:"after"`
+			} `json:"cursors"`
+			Next string `json:"next"`
+		} `json:"paging"`
+	}
+
+	if err := it.client.get(it.path, params, &response); err != nil {
+		return fmt.Errorf("failed to list comments: %w", err)
+	}
+
+	it.page = response.Data
+	it.index = 0
+	it.after = response.Paging.Cursors.After
+
+	if response.Paging.Next == "" || it.after == "" || len(response.Data) == 0 {
+		it.done = true
+	}
+
+	return nil
+}
+
+// ReplyToComment replies to a comment and returns the ID of the reply
+func (c *Client) ReplyToComment(commentID, message string) (string, error) {
+	if message == "" {
+		return "", fmt.Errorf("reply message is required")
+	}
+
+	params := url.Values{}
+	params.Set("message", message)
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := c.post(fmt.Sprintf("%s/replies", commentID), params, &response); err != nil {
+		return "", fmt.Errorf("failed to reply to comment %s: %w", commentID, err)
+	}
+	if response.ID == "" {
+		return "", fmt.Errorf("failed to reply to comment %s: no reply ID returned", commentID)
+	}
+
+	return response.ID, nil
+}
+
+// SetCommentHidden hides or unhides a comment. Hidden comments are only
+// visible to their author and to us.
+func (c *Client) SetCommentHidden(commentID string, hidden bool) error {
+	params := url.Values{}
+	params.Set("hide", strconv.FormatBool(hidden))
+
+	var response struct {
+		Success bool `json:"success"`
+	}
+	if err := c.post(commentID, params, &response); err != nil {
+		return fmt.Errorf("failed to update comment %s: %w", commentID, err)
+	}
+	if !response.Success {
+		return fmt.Errorf("failed to update comment %s: request was not successful", commentID)
+	}
+
+	return nil
+}
+
+// DeleteComment deletes a comment
+func (c *Client) DeleteComment(commentID string) error {
+	var response struct {
+		Success bool `json:"success"`
+	}
+	if err := c.delete(commentID, nil, &response); err != nil {
+		return fmt.Errorf("failed to delete comment %s: %w", commentID, err)
+	}
+	if !response.Success {
+		return fmt.Errorf("failed to delete comment %s: request was not successful", commentID)
+	}
+
+	return nil
+}
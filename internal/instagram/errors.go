@@ -0,0 +1,127 @@
+package instagram
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind classifies Graph API errors by how callers should react to them
+type ErrorKind string
+
+// Error kinds
+const (
+	// ErrorKindOAuth means the access token is invalid or expired and must be replaced
+	ErrorKindOAuth ErrorKind = "oauth"
+	// ErrorKindRateLimit means a call quota was exceeded; retry after backing off
+	ErrorKindRateLimit ErrorKind = "rate_limit"
+	// ErrorKindPermission means the token lacks a permission or the account a capability
+	ErrorKindPermission ErrorKind = "permission"
+	// ErrorKindTransient means a temporary server-side problem; retry shortly
+	ErrorKindTransient ErrorKind = "transient"
+	// ErrorKindNetwork means the request never got a response, because of a
+	// timeout, DNS failure or dropped connection; retry shortly
+	ErrorKindNetwork ErrorKind = "network"
+	// ErrorKindInvalidParameter means the request itself was wrong and retrying won't help
+	ErrorKindInvalidParameter ErrorKind = "invalid_parameter"
+	// ErrorKindUnknown covers everything else
+	ErrorKindUnknown ErrorKind = "unknown"
+)
+
+// GraphError is an error object returned by the Graph API
+type GraphError struct {
+	StatusCode     int    `json:"-"`
+	Message        string `json:"message"`
+	Type           string `json:"type"`
+	Code           int    `json:"code"`
+	ErrorSubcode   int    `json:"error_subcode"`
+	IsTransient    bool   `json:"is_transient"`
+	ErrorUserTitle string `json:"error_user_title"`
+	ErrorUserMsg   string `json:"error_user_msg"`
+	FBTraceID      string `json:"fbtrace_id"`
+	// Err is the transport error for requests that never got a response
+	Err error `json:"-"`
+}
+
+func (e *GraphError) Error() string {
+	if e.Err != nil {
+		return "graph api request failed: " + e.Message
+	}
+	msg := fmt.Sprintf("graph api error %d", e.Code)
+	if e.ErrorSubcode != 0 {
+		msg += fmt.Sprintf("/%d", e.ErrorSubcode)
+	}
+	if e.Type != "" {
+		msg += fmt.Sprintf(" (%s)", e.Type)
+	}
+	msg += ": " + e.Message
+	if e.FBTraceID != "" {
+		msg += " [fbtrace_id " + e.FBTraceID + "]"
+	}
+	return msg
+}
+
+func (e *GraphError) Unwrap() error {
+	return e.Err
+}
+
+// Kind classifies the error using the documented Graph API error codes
+func (e *GraphError) Kind() ErrorKind {
+	switch {
+	case e.Err != nil:
+		return ErrorKindNetwork
+	case e.Code == 190 || e.Code == 102 || e.Code == 463 || e.Code == 467:
+		return ErrorKindOAuth
+	case e.Code == 4 || e.Code == 17 || e.Code == 32 || e.Code == 613 ||
+		(e.Code >= 80001 && e.Code <= 80014):
+		return ErrorKindRateLimit
+	case e.Code == 1 || e.Code == 2 || e.IsTransient || e.StatusCode >= 500:
+		return ErrorKindTransient
+	case e.Code == 3 || e.Code == 10 || (e.Code >= 200 && e.Code <= 299):
+		return ErrorKindPermission
+	case e.Code == 100 || e.Code == 9004 || e.Code == 36000 || e.Code == 2207026:
+		return ErrorKindInvalidParameter
+	case e.Type == "OAuthException" && e.StatusCode == http.StatusUnauthorized:
+		return ErrorKindOAuth
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return ErrorKindInvalidParameter
+	default:
+		return ErrorKindUnknown
+	}
+}
+
+// Retryable reports whether the same request may succeed later
+func (e *GraphError) Retryable() bool {
+	kind := e.Kind()
+	return kind == ErrorKindTransient || kind == ErrorKindNetwork || kind == ErrorKindRateLimit
+}
+
+// ErrorKindOf returns the kind of a Graph API error anywhere in err's chain,
+// or ErrorKindUnknown if there is none. Calls refused by throttling or the
+// hashtag limit count as rate limited, calls missing a Facebook Login token
+// as lacking a permission, and invalid usernames as invalid parameters.
+func ErrorKindOf(err error) ErrorKind {
+	if isThrottled(err) || isHashtagLimited(err) {
+		return ErrorKindRateLimit
+	}
+	if errors.Is(err, ErrFacebookLoginRequired) {
+		return ErrorKindPermission
+	}
+	if errors.Is(err, ErrInvalidUsername) {
+		return ErrorKindInvalidParameter
+	}
+	var graphErr *GraphError
+	if errors.As(err, &graphErr) {
+		return graphErr.Kind()
+	}
+	return ErrorKindUnknown
+}
+
+// IsRetryable reports whether err is a Graph API error worth retrying
+func IsRetryable(err error) bool {
+	if isThrottled(err) {
+		return true
+	}
+	var graphErr *GraphError
+	return errors.As(err, &graphErr) && graphErr.Retryable()
+}
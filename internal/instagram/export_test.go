@@ -0,0 +1,4 @@
+package instagram
+
+// RejectedMetrics exposes rejectedMetrics to the tests
+var RejectedMetrics = rejectedMetrics
@@ -0,0 +1,110 @@
+package instagram_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+func TestFacebookLoginCallsUseTheFacebookHost(t *testing.T) {
+	var paths []string
+	facebook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if token := r.URL.Query().Get("access_token"); token != "facebook-token" {
+			t.Errorf("access_token = %q, want the Facebook token", token)
+		}
+		w.Write([]byte(`{"data": [{"id": "17843853986012965"}]}`))
+	}))
+	defer facebook.Close()
+
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+	client.FacebookBaseURL = facebook.URL + "/v12.0"
+	client.FacebookAccessToken = "facebook-token"
+
+	if _, err := client.SearchHashtag("techhumor"); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != "/v12.0/ig_hashtag_search" {
+		t.Errorf("Facebook host got %v, want the hashtag search", paths)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("hashtag search went to the Instagram host")
+	}
+}
+
+func TestFacebookLoginCallsSendTheFacebookToken(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	server.AddHashtagMedia("techhumor", instagram.HashtagMedia{Caption: "tagged", Timestamp: mediaTimestamp(time.Now())})
+	server.AddBusinessAccount(instagramtest.BusinessAccount{Username: "byte_sized_jokes"})
+	client := server.Client()
+
+	calls := map[string]func() error{
+		"hashtag search": func() error { _, err := client.SearchHashtag("techhumor"); return err },
+		"hashtag media":  func() error { _, err := client.GetHashtagTopMedia(server.HashtagID("techhumor"), 5); return err },
+		"discovery":      func() error { _, err := client.DiscoverBusiness("byte_sized_jokes", 5); return err },
+		"tags":           func() error { _, err := client.ListTags(5); return err },
+	}
+	for name, call := range calls {
+		if err := call(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := client.GetRecentMedia(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, request := range server.Requests() {
+		want := server.FacebookAccessToken
+		if request.Path == server.UserID+"/media" {
+			want = server.AccessToken
+		}
+		if token := request.Params.Get("access_token"); token != want {
+			t.Errorf("%s sent token %q, want %q", request.Path, token, want)
+		}
+	}
+
+	// graph.instagram.com doesn't serve these calls to Instagram Login tokens
+	client.FacebookAccessToken = client.AccessToken
+	for name, call := range calls {
+		if err := call(); instagram.ErrorKindOf(err) != instagram.ErrorKindOAuth {
+			t.Errorf("%s with the Instagram token: err = %v, want an OAuth error", name, err)
+		}
+	}
+}
+
+func TestFacebookLoginCallsNeedAFacebookToken(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	ledger := &memoryLedger{}
+	client := server.Client()
+	client.FacebookAccessToken = ""
+	client.HashtagLedger = ledger
+
+	calls := map[string]func() error{
+		"hashtag search": func() error { _, err := client.SearchHashtag("techhumor"); return err },
+		"hashtag media":  func() error { _, err := client.GetHashtagRecentMedia("17843853986012965", 5); return err },
+		"discovery":      func() error { _, err := client.DiscoverBusiness("byte_sized_jokes", 5); return err },
+		"tags":           func() error { _, err := client.ListTags(5); return err },
+	}
+	for name, call := range calls {
+		err := call()
+		if !errors.Is(err, instagram.ErrFacebookLoginRequired) {
+			t.Errorf("%s: err = %v, want ErrFacebookLoginRequired", name, err)
+		}
+		if instagram.ErrorKindOf(err) != instagram.ErrorKindPermission {
+			t.Errorf("%s: kind = %s, want permission", name, instagram.ErrorKindOf(err))
+		}
+	}
+
+	if len(server.Requests()) != 0 {
+		t.Errorf("calls without a Facebook token reached the server")
+	}
+	if len(ledger.queries) != 0 {
+		t.Errorf("hashtag reserved without a Facebook token: %v", ledger.queries)
+	}
+}
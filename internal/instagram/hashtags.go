@@ -0,0 +1,185 @@
+package instagram
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Instagram lets an account query at most HashtagWeeklyLimit unique hashtags
+// in any HashtagWindow. Querying a hashtag again within the window is free.
+const (
+	HashtagWeeklyLimit = 30
+	HashtagWindow      = 7 * 24 * time.Hour
+)
+
+// Hashtag media edges
+const (
+	HashtagEdgeTop    = "top_media"
+	HashtagEdgeRecent = "recent_media"
+)
+
+// maxHashtagPageSize is the largest page the hashtag media edges return
+const maxHashtagPageSize = 50
+
+// hashtagMediaFields are the fields hashtag media expose; media owned by other
+// accounts has no username or insights
+const hashtagMediaFields = "id,caption,media_type,permalink,timestamp,like_count,comments_count"
+
+// HashtagMedia is a public media found through a hashtag
+type HashtagMedia struct {
+	ID        string `json:"id"`
+	Caption   string `json:"caption"`
+	MediaType string `json:"media_type"`
+	Permalink string `json:"permalink"`
+	Timestamp string `json:"timestamp"`
+	// LikeCount is omitted by Instagram for media that hide their likes
+	LikeCount     int `json:"like_count"`
+	CommentsCount int `json:"comments_count"`
+}
+
+// HashtagLimitError is returned when searching a hashtag would go over the
+// weekly limit of unique hashtags
+type HashtagLimitError struct {
+	Hashtag string
+	// ResetAt is when the oldest hashtag in the window drops out of it
+	ResetAt time.Time
+}
+
+func (e *HashtagLimitError) Error() string {
+	return fmt.Sprintf("cannot search #%s: %d unique hashtags already queried in the last 7 days, next slot frees at %s",
+		e.Hashtag, HashtagWeeklyLimit, e.ResetAt.Format(time.RFC3339))
+}
+
+// HashtagLedger records the unique hashtags each account queries
+type HashtagLedger interface {
+	// ReserveHashtag records that an account queries a hashtag, unless that
+	// would make more than limit unique hashtags queried since the given time.
+	// Then it reports false and when the oldest query in the window was made.
+	// Checking and recording must happen atomically, since clients for the
+	// same account may reserve at the same time.
+	ReserveHashtag(instagramUserID, name string, since time.Time, limit int) (bool, time.Time, error)
+}
+
+// isHashtagLimited reports whether err is a hashtag search refused for the weekly limit
+func isHashtagLimited(err error) bool {
+	var limitErr *HashtagLimitError
+	return errors.As(err, &limitErr)
+}
+
+// NormalizeHashtag lowercases a hashtag and strips the leading #, which is how
+// Instagram matches them
+func NormalizeHashtag(name string) string {
+	return strings.ToLower(strings.TrimLeft(strings.TrimSpace(name), "#"))
+}
+
+// ReserveHashtag counts a query of a hashtag against the weekly limit of the
+// client's account. It fails with a *HashtagLimitError if the hashtag isn't in
+// the account's window and the window is full. Without a HashtagLedger it
+// does nothing.
+func (c *Client) ReserveHashtag(name string) error {
+	name = NormalizeHashtag(name)
+	if name == "" {
+		return fmt.Errorf("hashtag is required")
+	}
+	if c.HashtagLedger == nil {
+		return nil
+	}
+
+	reserved, oldest, err := c.HashtagLedger.ReserveHashtag(c.UserID, name, time.Now().Add(-HashtagWindow), HashtagWeeklyLimit)
+	if err != nil {
+		return fmt.Errorf("failed to reserve hashtag #%s: %w", name, err)
+	}
+	if !reserved {
+		return &HashtagLimitError{Hashtag: name, ResetAt: oldest.Add(HashtagWindow)}
+	}
+	return nil
+}
+
+// SearchHashtag looks up the ID of a hashtag, reserving it in the ledger
+// first. Each unique hashtag searched counts against the weekly limit, so
+// callers should reuse IDs they already know. Hashtag search and the hashtag
+// media edges need Facebook Login.
+func (c *Client) SearchHashtag(name string) (string, error) {
+	// Checked before reserving so a missing token doesn't use up the limit
+	if c.FacebookAccessToken == "" {
+		return "", ErrFacebookLoginRequired
+	}
+
+	name = NormalizeHashtag(name)
+	if err := c.ReserveHashtag(name); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("user_id", c.UserID)
+	params.Set("q", name)
+
+	var response struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := c.getFacebook("ig_hashtag_search", params, &response); err != nil {
+		return "", fmt.Errorf("failed to search hashtag #%s: %w", name, err)
+	}
+	if len(response.Data) == 0 {
+		return "", fmt.Errorf("hashtag #%s not found", name)
+	}
+
+	return response.Data[0].ID, nil
+}
+
+// GetHashtagTopMedia gets the most popular media tagged with a hashtag
+func (c *Client) GetHashtagTopMedia(hashtagID string, limit int) ([]HashtagMedia, error) {
+	return c.listHashtagMedia(hashtagID, HashtagEdgeTop, limit)
+}
+
+// GetHashtagRecentMedia gets the media tagged with a hashtag in the last 24
+// hours, newest first
+func (c *Client) GetHashtagRecentMedia(hashtagID string, limit int) ([]HashtagMedia, error) {
+	return c.listHashtagMedia(hashtagID, HashtagEdgeRecent, limit)
+}
+
+// listHashtagMedia pages through a hashtag media edge until limit media have
+// been collected. A limit of zero means a single page.
+func (c *Client) listHashtagMedia(hashtagID, edge string, limit int) ([]HashtagMedia, error) {
+	pageSize := maxHashtagPageSize
+	if limit > 0 && limit < pageSize {
+		pageSize = limit
+	}
+
+	params := url.Values{}
+	params.Set("user_id", c.UserID)
+	params.Set("fields", hashtagMediaFields)
+	params.Set("limit", strconv.Itoa(pageSize))
+
+	var media []HashtagMedia
+	for {
+		var response struct {
+			Data   []HashtagMedia `json:"data"`
+			Paging struct {
+				Cursors struct {
+					After string `json:"after"`
+				} `json:"cursors"`
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+
+		if err := c.getFacebook(fmt.Sprintf("%s/%s", hashtagID, edge), params, &response); err != nil {
+			return nil, fmt.Errorf("failed to get %s of hashtag %s: %w", edge, hashtagID, err)
+		}
+		media = append(media, response.Data...)
+
+		if limit > 0 && len(media) >= limit {
+			return media[:limit], nil
+		}
+		if limit <= 0 || response.Paging.Next == "" || response.Paging.Cursors.After == "" || len(response.Data) == 0 {
+			return media, nil
+		}
+		params.Set("after", response.Paging.Cursors.After)
+	}
+}
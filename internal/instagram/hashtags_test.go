@@ -0,0 +1,130 @@
+package instagram_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+// memoryLedger is a HashtagLedger kept in memory
+type memoryLedger struct {
+	mu      sync.Mutex
+	queries map[string]map[string]time.Time // first query by user and hashtag
+}
+
+func (l *memoryLedger) ReserveHashtag(instagramUserID, name string, since time.Time, limit int) (bool, time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.queries == nil {
+		l.queries = make(map[string]map[string]time.Time)
+	}
+	queried := l.queries[instagramUserID]
+	if queried == nil {
+		queried = make(map[string]time.Time)
+		l.queries[instagramUserID] = queried
+	}
+
+	var oldest time.Time
+	inWindow := 0
+	for _, at := range queried {
+		if at.After(since) {
+			inWindow++
+			if oldest.IsZero() || at.Before(oldest) {
+				oldest = at
+			}
+		}
+	}
+	if at, ok := queried[name]; ok && at.After(since) {
+		return true, time.Time{}, nil
+	}
+	if inWindow >= limit {
+		return false, oldest, nil
+	}
+	queried[name] = time.Now()
+	return true, time.Time{}, nil
+}
+
+func TestReserveHashtagEnforcesWeeklyLimit(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+	client.HashtagLedger = &memoryLedger{}
+
+	for i := 0; i < instagram.HashtagWeeklyLimit; i++ {
+		if err := client.ReserveHashtag(fmt.Sprintf("tag%d", i)); err != nil {
+			t.Fatalf("reserving hashtag %d: %v", i, err)
+		}
+	}
+
+	// Hashtags already in the window can be queried again
+	if err := client.ReserveHashtag("#TAG0"); err != nil {
+		t.Errorf("requerying a hashtag in the window: %v", err)
+	}
+
+	_, err := client.SearchHashtag("onemore")
+	var limitErr *instagram.HashtagLimitError
+	if !errors.As(err, &limitErr) || limitErr.Hashtag != "onemore" {
+		t.Fatalf("err = %v, want a *HashtagLimitError", err)
+	}
+	if until := time.Until(limitErr.ResetAt); until < 6*24*time.Hour {
+		t.Errorf("ResetAt is %s away, want about a week", until)
+	}
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindRateLimit {
+		t.Errorf("kind = %s, want rate_limit", instagram.ErrorKindOf(err))
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("refused search reached the server")
+	}
+
+	// Another account has its own window
+	client.UserID = "17841400000000002"
+	if err := client.ReserveHashtag("onemore"); err != nil {
+		t.Errorf("other account was limited: %v", err)
+	}
+}
+
+func TestSearchHashtagAndMedia(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+	now := time.Now()
+
+	server.AddHashtagMedia("techhumor", instagram.HashtagMedia{Caption: "old but popular", LikeCount: 500,
+		Timestamp: mediaTimestamp(now.Add(-3 * 24 * time.Hour))})
+	for i := 0; i < 3; i++ {
+		server.AddHashtagMedia("techhumor", instagram.HashtagMedia{Caption: fmt.Sprintf("recent %d", i), LikeCount: i,
+			Timestamp: mediaTimestamp(now.Add(-time.Duration(i) * time.Hour))})
+	}
+
+	hashtagID, err := client.SearchHashtag("#TechHumor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hashtagID != server.HashtagID("techhumor") {
+		t.Errorf("hashtag ID = %s, want %s", hashtagID, server.HashtagID("techhumor"))
+	}
+
+	top, err := client.GetHashtagTopMedia(hashtagID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Caption != "old but popular" {
+		t.Errorf("top media = %+v, want the most liked first", top)
+	}
+
+	recent, err := client.GetHashtagRecentMedia(hashtagID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 3 || recent[0].Caption != "recent 0" {
+		t.Errorf("recent media = %+v, want the last day newest first", recent)
+	}
+
+	if _, err := client.SearchHashtag("not a tag"); err == nil {
+		t.Errorf("searching a malformed hashtag succeeded")
+	}
+}
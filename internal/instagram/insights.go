@@ -0,0 +1,301 @@
+package instagram
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Media product types reported by the Graph API
+const (
+	ProductTypeFeed  = "FEED"
+	ProductTypeStory = "STORY"
+	ProductTypeReels = "REELS"
+)
+
+// Graph API error codes the insights endpoint uses for metrics it can't report
+const (
+	errorCodeInvalidParameter = 100
+	// errorCodeNotEnoughData is the permission error code, which insights also
+	// use when too few people saw a media; see isNotEnoughData
+	errorCodeNotEnoughData = 10
+)
+
+// isNotEnoughData reports whether an insights error means there were too few
+// viewers to report, e.g. on a story. The API uses the permission error code
+// for this too, so it is told apart from real permission errors by its message.
+func isNotEnoughData(graphErr *GraphError) bool {
+	if graphErr.Code != errorCodeNotEnoughData {
+		return false
+	}
+	message := strings.ToLower(graphErr.Message + " " + graphErr.ErrorUserMsg)
+	return strings.Contains(message, "not enough")
+}
+
+// MediaInsights represents insights for a media object
+type MediaInsights struct {
+	ID               string `json:"id"`
+	MediaType        string `json:"media_type"`
+	MediaProductType string `json:"media_product_type"`
+	Engagement       int    `json:"engagement"`
+	Impressions      int    `json:"impressions"`
+	Reach            int    `json:"reach"`
+	Saved            int    `json:"saved"`
+	// Metrics holds every metric the API returned, keyed by metric name
+	Metrics map[string]int `json:"metrics"`
+	// Unsupported lists requested metrics the API doesn't offer for this media
+	Unsupported []string `json:"unsupported,omitempty"`
+	// Note explains why insights are missing, e.g. a story with too few viewers
+	Note      string `json:"note,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Metric sets per kind of media. Metrics that have been renamed across API
+// versions are all requested; the ones the API rejects are dropped.
+var (
+	feedMetrics = []string{
+		"impressions", "views", "reach", "saved", "likes", "comments", "shares", "total_interactions",
+	}
+	videoMetrics = append(append([]string{}, feedMetrics...), "video_views")
+
+	carouselMetrics = []string{
+		"impressions", "views", "reach", "saved", "likes", "comments", "shares", "total_interactions",
+	}
+	reelsMetrics = []string{
+		"plays", "views", "reach", "saved", "likes", "comments", "shares", "total_interactions",
+		"ig_reels_avg_watch_time", "ig_reels_video_view_total_time",
+	}
+	storyMetrics = []string{
+		"impressions", "views", "reach", "replies", "exits", "taps_forward", "taps_back",
+		"shares", "total_interactions",
+	}
+)
+
+// metricsFor returns the metrics to request for a media type and product type
+func metricsFor(mediaType, productType string) []string {
+	switch {
+	case productType == ProductTypeStory:
+		return storyMetrics
+	case productType == ProductTypeReels || mediaType == MediaTypeReels:
+		return reelsMetrics
+	case mediaType == MediaTypeCarouselAlbum:
+		return carouselMetrics
+	case mediaType == MediaTypeVideo:
+		return videoMetrics
+	default:
+		return feedMetrics
+	}
+}
+
+// GetMediaInsights gets insights for a specific media, looking up its type
+// first so that the right metric set is requested
+func (c *Client) GetMediaInsights(mediaID string) (*MediaInsights, error) {
+	params := url.Values{}
+	params.Set("fields", "id,media_type,media_product_type")
+
+	var media Media
+	if err := c.get(mediaID, params, &media); err != nil {
+		return nil, fmt.Errorf("failed to look up media %s: %w", mediaID, err)
+	}
+
+	return c.GetMediaInsightsFor(mediaID, media.MediaType, media.MediaProductType)
+}
+
+// GetMediaInsightsFor gets insights for a media whose type is already known.
+// Metrics the API rejects for this media are dropped and listed in Unsupported.
+func (c *Client) GetMediaInsightsFor(mediaID, mediaType, productType string) (*MediaInsights, error) {
+	insights := &MediaInsights{
+		ID:               mediaID,
+		MediaType:        mediaType,
+		MediaProductType: productType,
+		Metrics:          make(map[string]int),
+		Timestamp:        time.Now().Format(time.RFC3339),
+	}
+
+	metrics := append([]string{}, metricsFor(mediaType, productType)...)
+	for len(metrics) > 0 {
+		values, err := c.fetchInsights(mediaID, metrics)
+		if err == nil {
+			for name, value := range values {
+				insights.Metrics[name] = value
+			}
+			break
+		}
+
+		var graphErr *GraphError
+		if !errors.As(err, &graphErr) {
+			return nil, err
+		}
+
+		if isNotEnoughData(graphErr) {
+			insights.Note = graphErr.Message
+			break
+		}
+		if graphErr.Code != errorCodeInvalidParameter {
+			return nil, err
+		}
+
+		rejected := rejectedMetrics(graphErr.Message, metrics)
+		if len(rejected) == 0 {
+			// The message didn't say which metric was the problem, so ask
+			// for each one on its own and keep whatever works
+			c.fetchInsightsIndividually(mediaID, metrics, insights)
+			break
+		}
+
+		insights.Unsupported = append(insights.Unsupported, rejected...)
+		metrics = removeMetrics(metrics, rejected)
+	}
+
+	insights.Impressions = firstMetric(insights.Metrics, "impressions", "views", "plays")
+	insights.Reach = insights.Metrics["reach"]
+	insights.Saved = insights.Metrics["saved"]
+	insights.Engagement = firstMetric(insights.Metrics, "total_interactions", "engagement")
+	if insights.Engagement == 0 {
+		insights.Engagement = insights.Metrics["likes"] + insights.Metrics["comments"] +
+			insights.Metrics["shares"] + insights.Metrics["saved"]
+	}
+
+	return insights, nil
+}
+
+// fetchInsights requests the given metrics and returns their lifetime values
+func (c *Client) fetchInsights(mediaID string, metrics []string) (map[string]int, error) {
+	params := url.Values{}
+	params.Set("metric", strings.Join(metrics, ","))
+
+	return c.fetchInsightValues(mediaID, params)
+}
+
+// fetchInsightValues requests the insights of a media or user and returns the
+// latest value of each metric
+func (c *Client) fetchInsightValues(objectID string, params url.Values) (map[string]int, error) {
+	var response struct {
+		Data []struct {
+			Name   string `json:"name"`
+			Period string `json:"period"`
+			Values []struct {
+				Value interface{} `json:"value"`
+			} `json:"values"`
+			TotalValue *struct {
+				Value interface{} `json:"value"`
+			} `json:"total_value"`
+		} `json:"data"`
+	}
+
+	if err := c.get(fmt.Sprintf("%s/insights", objectID), params, &response); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]int, len(response.Data))
+	for _, metric := range response.Data {
+		switch {
+		case metric.TotalValue != nil:
+			values[metric.Name] = metricValue(metric.TotalValue.Value)
+		case len(metric.Values) > 0:
+			values[metric.Name] = metricValue(metric.Values[len(metric.Values)-1].Value)
+		}
+	}
+
+	return values, nil
+}
+
+// fetchInsightsIndividually requests metrics one at a time, recording failures as unsupported
+func (c *Client) fetchInsightsIndividually(mediaID string, metrics []string, insights *MediaInsights) {
+	for _, metric := range metrics {
+		values, err := c.fetchInsights(mediaID, []string{metric})
+		if err != nil {
+			insights.Unsupported = append(insights.Unsupported, metric)
+			continue
+		}
+		for name, value := range values {
+			insights.Metrics[name] = value
+		}
+	}
+}
+
+// allowedMetricsPattern matches the "must be one of the following values: a, b" error message
+var allowedMetricsPattern = regexp.MustCompile(`one of the following values:\s*(.+)`)
+
+// rejectedMetrics works out which of the requested metrics an error message complains about
+func rejectedMetrics(message string, requested []string) []string {
+	var rejected []string
+
+	if match := allowedMetricsPattern.FindStringSubmatch(message); match != nil {
+		allowed := make(map[string]bool)
+		for _, name := range strings.Split(match[1], ",") {
+			allowed[strings.Trim(strings.TrimSpace(name), ".")] = true
+		}
+		for _, metric := range requested {
+			if !allowed[metric] {
+				rejected = append(rejected, metric)
+			}
+		}
+		return rejected
+	}
+
+	if len(requested) == 0 {
+		return nil
+	}
+	quoted := make([]string, len(requested))
+	for i, metric := range requested {
+		quoted[i] = regexp.QuoteMeta(metric)
+	}
+	named := make(map[string]bool)
+	for _, name := range regexp.MustCompile(`\b(`+strings.Join(quoted, "|")+`)\b`).FindAllString(message, -1) {
+		named[name] = true
+	}
+	for _, metric := range requested {
+		if named[metric] {
+			rejected = append(rejected, metric)
+		}
+	}
+	return rejected
+}
+
+// removeMetrics returns metrics without the removed ones
+func removeMetrics(metrics, removed []string) []string {
+	drop := make(map[string]bool, len(removed))
+	for _, metric := range removed {
+		drop[metric] = true
+	}
+
+	kept := metrics[:0]
+	for _, metric := range metrics {
+		if !drop[metric] {
+			kept = append(kept, metric)
+		}
+	}
+	return kept
+}
+
+// firstMetric returns the value of the first metric present in metrics
+func firstMetric(metrics map[string]int, names ...string) int {
+	for _, name := range names {
+		if value, ok := metrics[name]; ok {
+			return value
+		}
+	}
+	return 0
+}
+
+// metricValue converts a metric value to an int. Breakdown metrics report an
+// object of counts, which are summed.
+func metricValue(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(math.Round(v))
+	case map[string]interface{}:
+		total := 0
+		for _, inner := range v {
+			total += metricValue(inner)
+		}
+		return total
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,88 @@
+package instagram_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+func TestRejectedMetrics(t *testing.T) {
+	requested := []string{"impressions", "views", "video_views", "reach"}
+
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{
+			name:    "allowed values listed",
+			message: "(#100) metric[0] must be one of the following values: views, reach, saved.",
+			want:    []string{"impressions", "video_views"},
+		},
+		{
+			name:    "metrics named",
+			message: "(#100) The Media Insights API does not support the impressions, video_views metric for this media",
+			want:    []string{"impressions", "video_views"},
+		},
+		{
+			name:    "metric within a longer word",
+			message: "(#100) reach_28_days is not supported",
+		},
+		{
+			name:    "nothing named",
+			message: "(#100) Invalid parameter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := instagram.RejectedMetrics(tt.message, requested); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rejectedMetrics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaInsightsDropsRejectedMetrics(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	media := server.AddMedia(instagram.Media{Caption: "post", MediaType: instagram.MediaTypeImage})
+
+	insights, err := server.Client().GetMediaInsights(media.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insights.Metrics) == 0 {
+		t.Errorf("insights = %+v, want the metrics the fake offers", insights)
+	}
+	for _, metric := range insights.Unsupported {
+		if _, ok := insights.Metrics[metric]; ok {
+			t.Errorf("%s is both reported and unsupported", metric)
+		}
+	}
+}
+
+func TestMediaInsightsNotEnoughData(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	media := server.AddMedia(instagram.Media{Caption: "story", MediaType: instagram.MediaTypeImage})
+	server.FailNext("GET", media.ID+"/insights", instagramtest.NotEnoughViewersError())
+
+	insights, err := server.Client().GetMediaInsights(media.ID)
+	if err != nil {
+		t.Fatalf("err = %v, want a note instead", err)
+	}
+	if insights.Note == "" || len(insights.Metrics) != 0 {
+		t.Errorf("insights = %+v, want only a note", insights)
+	}
+}
+
+func TestMediaInsightsPermissionError(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	media := server.AddMedia(instagram.Media{Caption: "post", MediaType: instagram.MediaTypeImage})
+	server.FailNext("GET", media.ID+"/insights", instagramtest.PermissionError())
+
+	_, err := server.Client().GetMediaInsights(media.ID)
+	if instagram.ErrorKindOf(err) != instagram.ErrorKindPermission {
+		t.Errorf("err = %v, want a permission error", err)
+	}
+}
@@ -0,0 +1,368 @@
+package instagramtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// recentHashtagWindow is how far back the recent_media edge of a hashtag reaches
+const recentHashtagWindow = 24 * time.Hour
+
+var (
+	mediaLimitPattern        = regexp.MustCompile(`media\.limit\((\d+)\)`)
+	businessDiscoveryPattern = regexp.MustCompile(`^business_discovery\.username\(([^)]*)\)`)
+	mentionedMediaPattern    = regexp.MustCompile(`^mentioned_media\.media_id\(([^)]*)\)`)
+	mentionedCommentPattern  = regexp.MustCompile(`^mentioned_comment\.comment_id\(([^)]*)\)`)
+	hashtagNamePattern       = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+)
+
+// BusinessAccount is another business or creator account that business
+// discovery finds
+type BusinessAccount struct {
+	ID             string
+	Username       string
+	Name           string
+	Biography      string
+	FollowersCount int
+	FollowsCount   int
+	// Media is the account's media, newest first
+	Media []instagram.PublishedMedia
+}
+
+// MentionReply is a comment posted through the mentions edge
+type MentionReply struct {
+	ID        string
+	MediaID   string
+	CommentID string
+	Message   string
+}
+
+// hashtagObject is a hashtag and the public media tagged with it
+type hashtagObject struct {
+	id    string
+	name  string
+	media []instagram.HashtagMedia
+}
+
+// mediaLimit returns the limit of a media.limit(n) field expansion
+func mediaLimit(fields string) (int, bool) {
+	match := mediaLimitPattern.FindStringSubmatch(fields)
+	if match == nil {
+		return 0, false
+	}
+	limit, _ := strconv.Atoi(match[1])
+	return limit, true
+}
+
+// AddBusinessAccount adds an account business discovery can find and returns
+// it with its ID and the IDs, permalinks and timestamps of its media filled in
+func (s *Server) AddBusinessAccount(account BusinessAccount) BusinessAccount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if account.ID == "" {
+		account.ID = s.newID()
+	}
+	for i := range account.Media {
+		media := &account.Media[i]
+		if media.ID == "" {
+			media.ID = s.newID()
+		}
+		if media.MediaType == "" {
+			media.MediaType = instagram.MediaTypeImage
+		}
+		if media.Timestamp == "" {
+			media.Timestamp = time.Now().Format(timestampLayout)
+		}
+		if media.Permalink == "" {
+			media.Permalink = permalink(media.ID)
+		}
+	}
+
+	stored := account
+	stored.Media = append([]instagram.PublishedMedia{}, account.Media...)
+	s.businesses[strings.ToLower(account.Username)] = &stored
+	return account
+}
+
+// discoverBusiness serves business_discovery.username(name){...}
+func (s *Server) discoverBusiness(fields string) (int, interface{}) {
+	match := businessDiscoveryPattern.FindStringSubmatch(fields)
+	if match == nil {
+		return http.StatusBadRequest, invalidParameter("Syntax error in the business_discovery field")
+	}
+
+	account, ok := s.businesses[strings.ToLower(match[1])]
+	if !ok {
+		return http.StatusBadRequest, instagram.GraphError{
+			StatusCode:     http.StatusBadRequest,
+			Message:        "Invalid user id",
+			Type:           "OAuthException",
+			Code:           110,
+			ErrorSubcode:   2207013,
+			ErrorUserTitle: "Cannot find User",
+			ErrorUserMsg:   fmt.Sprintf("The user with username: %s cannot be found.", match[1]),
+		}
+	}
+
+	profile := map[string]interface{}{
+		"id":              account.ID,
+		"username":        account.Username,
+		"name":            account.Name,
+		"biography":       account.Biography,
+		"followers_count": account.FollowersCount,
+		"follows_count":   account.FollowsCount,
+		"media_count":     len(account.Media),
+	}
+	if limit, ok := mediaLimit(fields); ok {
+		media := account.Media
+		if len(media) > limit {
+			media = media[:limit]
+		}
+		profile["media"] = map[string]interface{}{"data": media}
+	}
+
+	return http.StatusOK, map[string]interface{}{"business_discovery": profile, "id": s.UserID}
+}
+
+// AddTag adds a media by another account that tags the account and returns
+// it with the blanks filled in
+func (s *Server) AddTag(media instagram.MentionedMedia) instagram.MentionedMedia {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	media = s.fillMentionedMedia(media)
+	s.tags = append(s.tags, media)
+	sort.SliceStable(s.tags, func(i, j int) bool {
+		return s.tags[i].Timestamp > s.tags[j].Timestamp
+	})
+	return media
+}
+
+// AddMentionedMedia adds a media by another account that @mentions the account
+// in its caption and returns it with the blanks filled in. Such media can only
+// be fetched by ID, as a mentions webhook would deliver it.
+func (s *Server) AddMentionedMedia(media instagram.MentionedMedia) instagram.MentionedMedia {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	media = s.fillMentionedMedia(media)
+	s.mentionedMedia[media.ID] = media
+	return media
+}
+
+// fillMentionedMedia fills in the ID, type, permalink and timestamp of media
+// that mentions the account
+func (s *Server) fillMentionedMedia(media instagram.MentionedMedia) instagram.MentionedMedia {
+	if media.ID == "" {
+		media.ID = s.newID()
+	}
+	if media.MediaType == "" {
+		media.MediaType = instagram.MediaTypeImage
+	}
+	if media.Timestamp == "" {
+		media.Timestamp = time.Now().Format(timestampLayout)
+	}
+	if media.Permalink == "" {
+		media.Permalink = permalink(media.ID)
+	}
+	return media
+}
+
+// AddMentionedComment adds a comment by another account that @mentions the
+// account and returns it with the blanks filled in
+func (s *Server) AddMentionedComment(comment instagram.MentionedComment) instagram.MentionedComment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if comment.ID == "" {
+		comment.ID = s.newID()
+	}
+	if comment.Timestamp == "" {
+		comment.Timestamp = time.Now().Format(timestampLayout)
+	}
+	if comment.Media.ID == "" {
+		comment.Media.ID = s.newID()
+	}
+	if comment.Media.Permalink == "" {
+		comment.Media.Permalink = permalink(comment.Media.ID)
+	}
+	s.mentionedComments[comment.ID] = comment
+	return comment
+}
+
+// MentionReplies returns the comments posted through the mentions edge, oldest first
+func (s *Server) MentionReplies() []MentionReply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]MentionReply{}, s.mentionReplies...)
+}
+
+// listTags serves the account's tags edge, newest first
+func (s *Server) listTags(params url.Values) (int, interface{}) {
+	start, end, paging := page(len(s.tags), params)
+	return http.StatusOK, map[string]interface{}{"data": s.tags[start:end], "paging": paging}
+}
+
+// getMentionedMedia serves mentioned_media.media_id(id){...}
+func (s *Server) getMentionedMedia(fields string) (int, interface{}) {
+	match := mentionedMediaPattern.FindStringSubmatch(fields)
+	if match == nil {
+		return http.StatusBadRequest, invalidParameter("Syntax error in the mentioned_media field")
+	}
+	media, ok := s.mentionedMedia[match[1]]
+	if !ok {
+		return http.StatusBadRequest, invalidParameter("Invalid media_id %s", match[1])
+	}
+	return http.StatusOK, map[string]interface{}{"mentioned_media": media, "id": s.UserID}
+}
+
+// getMentionedComment serves mentioned_comment.comment_id(id){...}
+func (s *Server) getMentionedComment(fields string) (int, interface{}) {
+	match := mentionedCommentPattern.FindStringSubmatch(fields)
+	if match == nil {
+		return http.StatusBadRequest, invalidParameter("Syntax error in the mentioned_comment field")
+	}
+	comment, ok := s.mentionedComments[match[1]]
+	if !ok {
+		return http.StatusBadRequest, invalidParameter("Invalid comment_id %s", match[1])
+	}
+	return http.StatusOK, map[string]interface{}{"mentioned_comment": comment, "id": s.UserID}
+}
+
+// replyToMention serves a comment posted on a media, or a reply to a comment,
+// that mentions the account
+func (s *Server) replyToMention(params url.Values) (int, interface{}) {
+	reply := MentionReply{
+		MediaID:   params.Get("media_id"),
+		CommentID: params.Get("comment_id"),
+		Message:   params.Get("message"),
+	}
+	if reply.Message == "" {
+		return http.StatusBadRequest, invalidParameter("The parameter message is required.")
+	}
+
+	if reply.CommentID != "" {
+		comment, ok := s.mentionedComments[reply.CommentID]
+		if !ok || comment.Media.ID != reply.MediaID {
+			return http.StatusBadRequest, invalidParameter("Invalid comment_id %s", reply.CommentID)
+		}
+	} else if _, ok := s.mentionedMedia[reply.MediaID]; !ok {
+		return http.StatusBadRequest, invalidParameter("Invalid media_id %s", reply.MediaID)
+	}
+
+	reply.ID = s.newID()
+	s.mentionReplies = append(s.mentionReplies, reply)
+	return http.StatusOK, map[string]string{"id": reply.ID}
+}
+
+// AddHashtagMedia adds a public media tagged with a hashtag and returns it with
+// the blanks filled in. Media from the last 24 hours also appear as recent.
+func (s *Server) AddHashtagMedia(name string, media instagram.HashtagMedia) instagram.HashtagMedia {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if media.ID == "" {
+		media.ID = s.newID()
+	}
+	if media.MediaType == "" {
+		media.MediaType = instagram.MediaTypeImage
+	}
+	if media.Timestamp == "" {
+		media.Timestamp = time.Now().Format(timestampLayout)
+	}
+	if media.Permalink == "" {
+		media.Permalink = permalink(media.ID)
+	}
+
+	hashtag := s.hashtag(instagram.NormalizeHashtag(name))
+	hashtag.media = append(hashtag.media, media)
+	return media
+}
+
+// HashtagID returns the ID of a hashtag, creating it if it doesn't exist yet
+func (s *Server) HashtagID(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hashtag(instagram.NormalizeHashtag(name)).id
+}
+
+// hashtag returns a hashtag by normalized name, creating it if needed
+func (s *Server) hashtag(name string) *hashtagObject {
+	if id, ok := s.hashtagIDs[name]; ok {
+		return s.hashtags[id]
+	}
+	hashtag := &hashtagObject{id: s.newID(), name: name}
+	s.hashtags[hashtag.id] = hashtag
+	s.hashtagIDs[name] = hashtag.id
+	return hashtag
+}
+
+// searchHashtag serves ig_hashtag_search. Every well-formed hashtag exists, as
+// on Instagram.
+func (s *Server) searchHashtag(params url.Values) (int, interface{}) {
+	if params.Get("user_id") != s.UserID {
+		return http.StatusBadRequest, invalidParameter("The parameter user_id is required.")
+	}
+	name := instagram.NormalizeHashtag(params.Get("q"))
+	if name == "" {
+		return http.StatusBadRequest, invalidParameter("The parameter q is required.")
+	}
+
+	data := []interface{}{}
+	if hashtagNamePattern.MatchString(name) {
+		data = append(data, map[string]string{"id": s.hashtag(name).id})
+	}
+	return http.StatusOK, map[string]interface{}{"data": data}
+}
+
+// routeHashtag serves a hashtag node and its media edges
+func (s *Server) routeHashtag(method, hashtagID, edge string, params url.Values) (int, interface{}) {
+	hashtag := s.hashtags[hashtagID]
+
+	switch {
+	case method == http.MethodGet && edge == "":
+		return http.StatusOK, map[string]string{"id": hashtag.id, "name": hashtag.name}
+	case method == http.MethodGet && (edge == instagram.HashtagEdgeTop || edge == instagram.HashtagEdgeRecent):
+		if params.Get("user_id") != s.UserID {
+			return http.StatusBadRequest, invalidParameter("The parameter user_id is required.")
+		}
+		media := hashtag.edge(edge, time.Now())
+		start, end, paging := page(len(media), params)
+		return http.StatusOK, map[string]interface{}{"data": media[start:end], "paging": paging}
+	}
+	return http.StatusBadRequest, invalidParameter("Unsupported %s request to /%s", strings.ToLower(method), edge)
+}
+
+// edge returns the media of a hashtag edge: top media by engagement, or the
+// media of the last 24 hours newest first
+func (h *hashtagObject) edge(edge string, now time.Time) []instagram.HashtagMedia {
+	media := make([]instagram.HashtagMedia, 0, len(h.media))
+	for _, m := range h.media {
+		if edge == instagram.HashtagEdgeRecent {
+			published, err := time.Parse(timestampLayout, m.Timestamp)
+			if err != nil || now.Sub(published) > recentHashtagWindow {
+				continue
+			}
+		}
+		media = append(media, m)
+	}
+
+	sort.SliceStable(media, func(i, j int) bool {
+		if edge == instagram.HashtagEdgeTop {
+			return media[i].LikeCount+media[i].CommentsCount > media[j].LikeCount+media[j].CommentsCount
+		}
+		return media[i].Timestamp > media[j].Timestamp
+	})
+	return media
+}
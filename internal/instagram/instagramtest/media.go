@@ -0,0 +1,759 @@
+package instagramtest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+const (
+	// maxCaptionLength is the longest caption Instagram accepts
+	maxCaptionLength = 2200
+	minCarouselItems = 2
+	maxCarouselItems = 10
+	// containerLifetime is how long an unpublished container stays usable
+	containerLifetime = 24 * time.Hour
+	// publishWindow is the window PublishLimit applies to
+	publishWindow = 24 * time.Hour
+)
+
+// Container media types accepted when creating a container. Images are
+// created without a media type.
+const (
+	containerTypeImage    = "IMAGE"
+	containerTypeCarousel = "CAROUSEL"
+	containerTypeStories  = "STORIES"
+)
+
+// Metrics the fake insights endpoint offers per kind of media
+var (
+	feedMetrics  = []string{"impressions", "reach", "saved", "likes", "comments", "shares", "total_interactions"}
+	videoMetrics = append(append([]string{}, feedMetrics...), "video_views")
+	reelsMetrics = []string{
+		"plays", "reach", "saved", "likes", "comments", "shares", "total_interactions",
+		"ig_reels_avg_watch_time", "ig_reels_video_view_total_time",
+	}
+	storyMetrics = []string{
+		"impressions", "reach", "replies", "exits", "taps_forward", "taps_back", "shares", "total_interactions",
+	}
+)
+
+// Container is a media container created through the content publishing API
+type Container struct {
+	ID string
+	// MediaType is IMAGE, VIDEO, REELS, CAROUSEL or STORIES
+	MediaType      string
+	Caption        string
+	ImageURL       string
+	VideoURL       string
+	CoverURL       string
+	ShareToFeed    bool
+	IsCarouselItem bool
+	// Children are the container IDs of a carousel's slides
+	Children   []string
+	StatusCode string
+	Status     string
+	// MediaID is set once the container is published
+	MediaID   string
+	CreatedAt time.Time
+
+	polls    int
+	failWith string
+}
+
+// mediaObject is a media on the account along with its engagement
+type mediaObject struct {
+	media    instagram.Media
+	insights map[string]int
+	// comments are the IDs of the top-level comments, oldest first
+	comments      []string
+	commentsCount int
+}
+
+// commentObject is a comment or reply on one of the account's media
+type commentObject struct {
+	comment instagram.Comment
+	mediaID string
+	// replies are the IDs of the replies, oldest first
+	replies []string
+}
+
+// AddMedia adds a media to the account, as if it had been published outside
+// the API, and returns it with the ID, timestamp and other blanks filled in
+func (s *Server) AddMedia(media instagram.Media) instagram.Media {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if media.ID == "" {
+		media.ID = s.newID()
+	}
+	if media.MediaType == "" {
+		media.MediaType = instagram.MediaTypeImage
+	}
+	if media.MediaProductType == "" {
+		media.MediaProductType = instagram.ProductTypeFeed
+	}
+	if media.Timestamp == "" {
+		media.Timestamp = time.Now().Format(timestampLayout)
+	}
+	if media.Permalink == "" {
+		media.Permalink = permalink(media.ID)
+	}
+	media.Username = s.Username
+	for i := range media.Children {
+		if media.Children[i].ID == "" {
+			media.Children[i].ID = s.newID()
+		}
+	}
+
+	s.addMedia(&mediaObject{media: media})
+	return media
+}
+
+// addMedia stores a media, keeping the media newest first
+func (s *Server) addMedia(m *mediaObject) {
+	s.media = append([]*mediaObject{m}, s.media...)
+	s.mediaByID[m.media.ID] = m
+	sort.SliceStable(s.media, func(i, j int) bool {
+		return s.media[i].publishedAt().After(s.media[j].publishedAt())
+	})
+}
+
+// Media gets a media by ID
+func (s *Server) Media(id string) (instagram.Media, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mediaByID[id]
+	if !ok {
+		return instagram.Media{}, false
+	}
+	return m.media, true
+}
+
+// SetInsights sets insight values of a media. Metrics that aren't set get a
+// stable made-up value; "likes" also sets the like count.
+func (s *Server) SetInsights(mediaID string, metrics map[string]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mediaByID[mediaID]
+	if !ok {
+		return fmt.Errorf("media %s not found", mediaID)
+	}
+	if m.insights == nil {
+		m.insights = make(map[string]int)
+	}
+	for name, value := range metrics {
+		m.insights[name] = value
+	}
+	return nil
+}
+
+// SetAccountInsights sets the account insight values, keyed by metric name.
+// Account metrics that aren't set are reported as unsupported.
+func (s *Server) SetAccountInsights(metrics map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accountInsights = make(map[string]int, len(metrics))
+	for name, value := range metrics {
+		s.accountInsights[name] = value
+	}
+}
+
+// Container gets a media container by ID
+func (s *Server) Container(id string) (Container, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	container, ok := s.containers[id]
+	if !ok {
+		return Container{}, false
+	}
+	return *container, true
+}
+
+// FailNextContainer makes the next container created fail processing with
+// the given status description
+func (s *Server) FailNextContainer(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.containerFailures = append(s.containerFailures, status)
+}
+
+// AddComment adds a comment by another user to a media and returns its ID
+func (s *Server) AddComment(mediaID, username, text string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mediaByID[mediaID]
+	if !ok {
+		return "", fmt.Errorf("media %s not found", mediaID)
+	}
+
+	comment := s.newComment(mediaID, commenterID(username), username, text)
+	m.comments = append(m.comments, comment.comment.ID)
+	m.commentsCount++
+	return comment.comment.ID, nil
+}
+
+// AddReply adds a reply by another user to a comment and returns its ID
+func (s *Server) AddReply(commentID, username, text string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parent, ok := s.comments[commentID]
+	if !ok || parent.comment.ParentID != "" {
+		return "", fmt.Errorf("top-level comment %s not found", commentID)
+	}
+
+	reply := s.addReply(parent, commenterID(username), username, text)
+	return reply.comment.ID, nil
+}
+
+// Comments gets the top-level comments on a media, oldest first, with their replies
+func (s *Server) Comments(mediaID string) []instagram.Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mediaByID[mediaID]
+	if !ok {
+		return nil
+	}
+
+	comments := make([]instagram.Comment, 0, len(m.comments))
+	for _, id := range m.comments {
+		comment := s.comments[id].comment
+		for _, replyID := range s.comments[id].replies {
+			comment.Replies = append(comment.Replies, s.comments[replyID].comment)
+		}
+		comments = append(comments, comment)
+	}
+	return comments
+}
+
+// newComment stores a comment; fromID is the commenter's user ID
+func (s *Server) newComment(mediaID, fromID, username, text string) *commentObject {
+	comment := &commentObject{mediaID: mediaID}
+	comment.comment.ID = s.newID()
+	comment.comment.Text = text
+	comment.comment.Username = username
+	comment.comment.Timestamp = time.Now().Format(timestampLayout)
+	comment.comment.From.ID = fromID
+	comment.comment.From.Username = username
+
+	s.comments[comment.comment.ID] = comment
+	return comment
+}
+
+// addReply stores a reply to parent
+func (s *Server) addReply(parent *commentObject, fromID, username, text string) *commentObject {
+	reply := s.newComment(parent.mediaID, fromID, username, text)
+	reply.comment.ParentID = parent.comment.ID
+	parent.replies = append(parent.replies, reply.comment.ID)
+	s.mediaByID[parent.mediaID].commentsCount++
+	return reply
+}
+
+// listMedia serves the account's media edge, newest first. Stories aren't listed.
+func (s *Server) listMedia(params url.Values) (int, interface{}) {
+	var since, until time.Time
+	if raw := params.Get("since"); raw != "" {
+		if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			since = time.Unix(unix, 0)
+		}
+	}
+	if raw := params.Get("until"); raw != "" {
+		if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			until = time.Unix(unix, 0)
+		}
+	}
+
+	var matching []*mediaObject
+	for _, m := range s.media {
+		if m.media.MediaProductType == instagram.ProductTypeStory {
+			continue
+		}
+		published := m.publishedAt()
+		if (!since.IsZero() && published.Before(since)) || (!until.IsZero() && published.After(until)) {
+			continue
+		}
+		matching = append(matching, m)
+	}
+
+	start, end, paging := page(len(matching), params)
+	data := make([]interface{}, 0, end-start)
+	for _, m := range matching[start:end] {
+		data = append(data, m.render())
+	}
+
+	return http.StatusOK, map[string]interface{}{"data": data, "paging": paging}
+}
+
+// routeMedia serves a media node and its edges
+func (s *Server) routeMedia(method, mediaID, edge string, params url.Values) (int, interface{}) {
+	m := s.mediaByID[mediaID]
+
+	switch {
+	case method == http.MethodGet && edge == "":
+		return http.StatusOK, m.render()
+	case method == http.MethodGet && edge == "insights":
+		return s.mediaInsights(m, params)
+	case method == http.MethodGet && edge == "comments":
+		return s.listComments(m.comments, params)
+	case method == http.MethodPost && edge == "comments":
+		message := params.Get("message")
+		if message == "" {
+			return http.StatusBadRequest, invalidParameter("The parameter message is required.")
+		}
+		comment := s.newComment(mediaID, s.UserID, s.Username, message)
+		m.comments = append(m.comments, comment.comment.ID)
+		m.commentsCount++
+		return http.StatusOK, map[string]string{"id": comment.comment.ID}
+	}
+	return http.StatusBadRequest, invalidParameter("Unsupported %s request to /%s", strings.ToLower(method), edge)
+}
+
+// publishedAt parses the media timestamp, treating invalid ones as the zero time
+func (m *mediaObject) publishedAt() time.Time {
+	published, _ := time.Parse(timestampLayout, m.media.Timestamp)
+	return published
+}
+
+// render returns the media as the Graph API sends it
+func (m *mediaObject) render() map[string]interface{} {
+	children := make([]interface{}, 0, len(m.media.Children))
+	for _, child := range m.media.Children {
+		children = append(children, map[string]interface{}{
+			"id":            child.ID,
+			"media_type":    child.MediaType,
+			"media_url":     child.MediaURL,
+			"thumbnail_url": child.ThumbnailURL,
+		})
+	}
+
+	rendered := map[string]interface{}{
+		"id":                 m.media.ID,
+		"caption":            m.media.Caption,
+		"media_type":         m.media.MediaType,
+		"media_product_type": m.media.MediaProductType,
+		"media_url":          m.media.MediaURL,
+		"permalink":          m.media.Permalink,
+		"timestamp":          m.media.Timestamp,
+		"username":           m.media.Username,
+		"like_count":         m.insight("likes"),
+		"comments_count":     m.commentsCount,
+	}
+	if m.media.ThumbnailURL != "" {
+		rendered["thumbnail_url"] = m.media.ThumbnailURL
+	}
+	if len(children) > 0 {
+		rendered["children"] = map[string]interface{}{"data": children}
+	}
+	return rendered
+}
+
+// insight returns the value of a metric, making a stable one up if it wasn't set
+func (m *mediaObject) insight(metric string) int {
+	if value, ok := m.insights[metric]; ok {
+		return value
+	}
+	if metric == "comments" {
+		return m.commentsCount
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(m.media.ID + "/" + metric))
+	return int(hash.Sum32()%990) + 10
+}
+
+// metrics returns the insight metrics offered for the media
+func (m *mediaObject) metrics() []string {
+	switch {
+	case m.media.MediaProductType == instagram.ProductTypeStory:
+		return storyMetrics
+	case m.media.MediaProductType == instagram.ProductTypeReels:
+		return reelsMetrics
+	case m.media.MediaType == instagram.MediaTypeVideo:
+		return videoMetrics
+	default:
+		return feedMetrics
+	}
+}
+
+// mediaInsights serves a media's insights, rejecting metrics the media doesn't
+// offer the way Instagram does
+func (s *Server) mediaInsights(m *mediaObject, params url.Values) (int, interface{}) {
+	offered := m.metrics()
+	allowed := make(map[string]bool, len(offered))
+	for _, metric := range offered {
+		allowed[metric] = true
+	}
+
+	requested := strings.Split(params.Get("metric"), ",")
+	data := make([]interface{}, 0, len(requested))
+	for i, metric := range requested {
+		if !allowed[metric] {
+			return http.StatusBadRequest, invalidParameter("metric[%d] must be one of the following values: %s",
+				i, strings.Join(offered, ", "))
+		}
+		data = append(data, map[string]interface{}{
+			"name":   metric,
+			"period": "lifetime",
+			"values": []interface{}{map[string]int{"value": m.insight(metric)}},
+			"id":     fmt.Sprintf("%s/insights/%s/lifetime", m.media.ID, metric),
+		})
+	}
+
+	return http.StatusOK, map[string]interface{}{"data": data}
+}
+
+// userInsights serves the account insights set with SetAccountInsights
+func (s *Server) userInsights(params url.Values) (int, interface{}) {
+	metric := params.Get("metric")
+	value, ok := s.accountInsights[metric]
+	if !ok {
+		return http.StatusBadRequest, invalidParameter("The metric %s is not available for this account", metric)
+	}
+
+	period := params.Get("period")
+	entry := map[string]interface{}{
+		"name":   metric,
+		"period": period,
+		"id":     fmt.Sprintf("%s/insights/%s/%s", s.UserID, metric, period),
+	}
+	if params.Get("metric_type") == "total_value" {
+		entry["total_value"] = map[string]int{"value": value}
+	} else {
+		entry["values"] = []interface{}{map[string]int{"value": value}}
+	}
+
+	return http.StatusOK, map[string]interface{}{"data": []interface{}{entry}}
+}
+
+// createContainer serves container creation, validating the parameters the
+// way Instagram does
+func (s *Server) createContainer(params url.Values) (int, interface{}) {
+	container := &Container{
+		MediaType:      params.Get("media_type"),
+		Caption:        params.Get("caption"),
+		ImageURL:       params.Get("image_url"),
+		VideoURL:       params.Get("video_url"),
+		CoverURL:       params.Get("cover_url"),
+		ShareToFeed:    params.Get("share_to_feed") == "true",
+		IsCarouselItem: params.Get("is_carousel_item") == "true",
+		StatusCode:     instagram.ContainerStatusInProgress,
+		CreatedAt:      time.Now(),
+	}
+	if container.MediaType == "" {
+		container.MediaType = containerTypeImage
+	}
+
+	if len([]rune(container.Caption)) > maxCaptionLength {
+		return http.StatusBadRequest, invalidParameter("The caption must be at most %d characters long.", maxCaptionLength)
+	}
+
+	switch container.MediaType {
+	case containerTypeImage:
+		if container.ImageURL == "" {
+			return http.StatusBadRequest, invalidParameter("The parameter image_url is required.")
+		}
+	case instagram.MediaTypeVideo, instagram.MediaTypeReels:
+		if container.VideoURL == "" {
+			return http.StatusBadRequest, invalidParameter("The parameter video_url is required.")
+		}
+	case containerTypeStories:
+		if container.ImageURL == "" && container.VideoURL == "" {
+			return http.StatusBadRequest, invalidParameter("The parameter image_url or video_url is required.")
+		}
+	case containerTypeCarousel:
+		if container.IsCarouselItem {
+			return http.StatusBadRequest, invalidParameter("A carousel can't be a carousel item.")
+		}
+		if raw := params.Get("children"); raw != "" {
+			container.Children = strings.Split(raw, ",")
+		}
+		if len(container.Children) < minCarouselItems || len(container.Children) > maxCarouselItems {
+			return http.StatusBadRequest, invalidParameter("The parameter children must contain between %d and %d items.",
+				minCarouselItems, maxCarouselItems)
+		}
+		for _, childID := range container.Children {
+			child, ok := s.containers[childID]
+			if !ok || !child.IsCarouselItem {
+				return http.StatusBadRequest, invalidParameter("Child %s is not a carousel item container.", childID)
+			}
+			if s.refreshContainer(child); child.StatusCode != instagram.ContainerStatusFinished {
+				return http.StatusBadRequest, mediaNotReady(fmt.Sprintf("Carousel item %s is not ready.", childID))
+			}
+		}
+	default:
+		return http.StatusBadRequest, invalidParameter("The parameter media_type must be one of IMAGE, VIDEO, REELS, CAROUSEL or STORIES.")
+	}
+
+	if len(s.containerFailures) > 0 {
+		container.failWith = s.containerFailures[0]
+		s.containerFailures = s.containerFailures[1:]
+	}
+
+	container.ID = s.newID()
+	s.containers[container.ID] = container
+	return http.StatusOK, map[string]string{"id": container.ID}
+}
+
+// refreshContainer advances a container's processing as if time had passed
+func (s *Server) refreshContainer(container *Container) {
+	switch {
+	case container.StatusCode == instagram.ContainerStatusInProgress && container.polls < s.ProcessingPolls:
+		container.polls++
+	case container.StatusCode == instagram.ContainerStatusInProgress && container.failWith != "":
+		container.StatusCode = instagram.ContainerStatusError
+		container.Status = container.failWith
+	case container.StatusCode == instagram.ContainerStatusInProgress:
+		container.StatusCode = instagram.ContainerStatusFinished
+	case container.StatusCode == instagram.ContainerStatusFinished && time.Since(container.CreatedAt) > containerLifetime:
+		container.StatusCode = instagram.ContainerStatusExpired
+	}
+}
+
+// containerStatus serves a status check, which advances processing
+func (s *Server) containerStatus(id string) (int, interface{}) {
+	container := s.containers[id]
+	s.refreshContainer(container)
+
+	return http.StatusOK, map[string]string{
+		"id":          container.ID,
+		"status_code": container.StatusCode,
+		"status":      container.Status,
+	}
+}
+
+// mediaNotReady is the error Instagram returns for publishing a container that
+// isn't finished
+func mediaNotReady(message string) instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode:   http.StatusBadRequest,
+		Message:      "Media ID is not available",
+		Type:         "OAuthException",
+		Code:         9007,
+		ErrorSubcode: 2207027,
+		ErrorUserMsg: message,
+	}
+}
+
+// publish serves media_publish, turning a finished container into a media
+func (s *Server) publish(params url.Values) (int, interface{}) {
+	containerID := params.Get("creation_id")
+	if containerID == "" {
+		return http.StatusBadRequest, invalidParameter("The parameter creation_id is required.")
+	}
+
+	container, ok := s.containers[containerID]
+	if !ok {
+		return http.StatusBadRequest, invalidParameter("Invalid parameter creation_id: %s", containerID)
+	}
+	if container.IsCarouselItem {
+		return http.StatusBadRequest, invalidParameter("Carousel items can't be published on their own.")
+	}
+	if container.StatusCode != instagram.ContainerStatusFinished {
+		return http.StatusBadRequest, mediaNotReady(fmt.Sprintf("The media container is %s.", container.StatusCode))
+	}
+
+	if s.PublishLimit > 0 && s.publishedSince(time.Now().Add(-publishWindow)) >= s.PublishLimit {
+		return http.StatusBadRequest, instagram.GraphError{
+			StatusCode:   http.StatusBadRequest,
+			Message:      "Application request limit reached",
+			Type:         "OAuthException",
+			Code:         4,
+			ErrorSubcode: 2207042,
+			ErrorUserMsg: "The maximum number of posts that can be published within a 24 hour period was reached.",
+		}
+	}
+
+	media := instagram.Media{
+		ID:               s.newID(),
+		Caption:          container.Caption,
+		MediaType:        instagram.MediaTypeImage,
+		MediaProductType: instagram.ProductTypeFeed,
+		MediaURL:         container.ImageURL,
+		Timestamp:        time.Now().Format(timestampLayout),
+		Username:         s.Username,
+	}
+	media.Permalink = permalink(media.ID)
+
+	switch container.MediaType {
+	case instagram.MediaTypeVideo:
+		media.MediaType = instagram.MediaTypeVideo
+		media.MediaURL = container.VideoURL
+	case instagram.MediaTypeReels:
+		media.MediaType = instagram.MediaTypeVideo
+		media.MediaProductType = instagram.ProductTypeReels
+		media.MediaURL = container.VideoURL
+		media.ThumbnailURL = container.CoverURL
+	case containerTypeStories:
+		media.MediaProductType = instagram.ProductTypeStory
+		if container.VideoURL != "" {
+			media.MediaType = instagram.MediaTypeVideo
+			media.MediaURL = container.VideoURL
+		}
+	case containerTypeCarousel:
+		media.MediaType = instagram.MediaTypeCarouselAlbum
+		media.MediaURL = ""
+		for _, childID := range container.Children {
+			child := s.containers[childID]
+			slide := instagram.Media{ID: s.newID(), MediaType: instagram.MediaTypeImage, MediaURL: child.ImageURL}
+			if child.VideoURL != "" {
+				slide.MediaType = instagram.MediaTypeVideo
+				slide.MediaURL = child.VideoURL
+			}
+			media.Children = append(media.Children, slide)
+			child.StatusCode = instagram.ContainerStatusPublished
+		}
+		if len(media.Children) > 0 {
+			media.MediaURL = media.Children[0].MediaURL
+		}
+	}
+
+	container.StatusCode = instagram.ContainerStatusPublished
+	container.MediaID = media.ID
+	s.addMedia(&mediaObject{media: media})
+
+	return http.StatusOK, map[string]string{"id": media.ID}
+}
+
+// publishedSince counts the media published through the API since the given time
+func (s *Server) publishedSince(since time.Time) int {
+	count := 0
+	for _, container := range s.containers {
+		if container.MediaID == "" {
+			continue
+		}
+		if s.mediaByID[container.MediaID].publishedAt().After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// routeComment serves a comment node and its replies
+func (s *Server) routeComment(method, commentID, edge string, params url.Values) (int, interface{}) {
+	comment := s.comments[commentID]
+
+	switch {
+	case method == http.MethodGet && edge == "":
+		return http.StatusOK, s.renderComment(comment)
+	case method == http.MethodGet && edge == "replies":
+		return s.listComments(comment.replies, params)
+	case method == http.MethodPost && edge == "replies":
+		if comment.comment.ParentID != "" {
+			return http.StatusBadRequest, invalidParameter("Replies can't be replied to.")
+		}
+		message := params.Get("message")
+		if message == "" {
+			return http.StatusBadRequest, invalidParameter("The parameter message is required.")
+		}
+		reply := s.addReply(comment, s.UserID, s.Username, message)
+		return http.StatusOK, map[string]string{"id": reply.comment.ID}
+	case method == http.MethodPost && edge == "":
+		hide, err := strconv.ParseBool(params.Get("hide"))
+		if err != nil {
+			return http.StatusBadRequest, invalidParameter("The parameter hide is required.")
+		}
+		comment.comment.Hidden = hide
+		return http.StatusOK, map[string]bool{"success": true}
+	case method == http.MethodDelete && edge == "":
+		s.deleteComment(comment)
+		return http.StatusOK, map[string]bool{"success": true}
+	}
+	return http.StatusBadRequest, invalidParameter("Unsupported %s request to /%s", strings.ToLower(method), edge)
+}
+
+// listComments serves a page of the given comments
+func (s *Server) listComments(ids []string, params url.Values) (int, interface{}) {
+	start, end, paging := page(len(ids), params)
+	data := make([]interface{}, 0, end-start)
+	for _, id := range ids[start:end] {
+		data = append(data, s.renderComment(s.comments[id]))
+	}
+
+	return http.StatusOK, map[string]interface{}{"data": data, "paging": paging}
+}
+
+// renderComment returns the comment as the Graph API sends it, with the
+// first page of replies for top-level comments
+func (s *Server) renderComment(comment *commentObject) map[string]interface{} {
+	rendered := map[string]interface{}{
+		"id":         comment.comment.ID,
+		"text":       comment.comment.Text,
+		"username":   comment.comment.Username,
+		"timestamp":  comment.comment.Timestamp,
+		"like_count": comment.comment.LikeCount,
+		"hidden":     comment.comment.Hidden,
+		"from":       map[string]string{"id": comment.comment.From.ID, "username": comment.comment.From.Username},
+	}
+	if comment.comment.ParentID != "" {
+		rendered["parent_id"] = comment.comment.ParentID
+		return rendered
+	}
+
+	replies := make([]interface{}, 0, len(comment.replies))
+	for i, id := range comment.replies {
+		if i == defaultPageSize {
+			break
+		}
+		replies = append(replies, s.renderComment(s.comments[id]))
+	}
+	rendered["replies"] = map[string]interface{}{"data": replies}
+	return rendered
+}
+
+// deleteComment removes a comment along with its replies
+func (s *Server) deleteComment(comment *commentObject) {
+	m := s.mediaByID[comment.mediaID]
+
+	for _, replyID := range comment.replies {
+		delete(s.comments, replyID)
+		m.commentsCount--
+	}
+	delete(s.comments, comment.comment.ID)
+	m.commentsCount--
+
+	if comment.comment.ParentID != "" {
+		parent := s.comments[comment.comment.ParentID]
+		parent.replies = removeID(parent.replies, comment.comment.ID)
+	} else {
+		m.comments = removeID(m.comments, comment.comment.ID)
+	}
+}
+
+// removeID returns ids without id
+func removeID(ids []string, id string) []string {
+	kept := ids[:0]
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+	return kept
+}
+
+// commenterID makes up a stable user ID for another user's username
+func commenterID(username string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(username))
+	return fmt.Sprintf("178414%011d", hash.Sum32())
+}
+
+// permalink makes up a permalink for a media ID
+func permalink(id string) string {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return fmt.Sprintf("https://www.instagram.com/p/%s/", strconv.FormatInt(n, 36))
+}
@@ -0,0 +1,565 @@
+// Package instagramtest provides a fake, stateful Instagram Graph API for
+// developing and exercising code that uses the instagram package without a
+// live account. It serves media listing, insights, content publishing,
+// comments, business discovery, mentions, tags and hashtag search, and can be
+// made to fail or report rate limits on demand.
+package instagramtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// Defaults of a new server's account
+const (
+	DefaultUserID      = "17841400000000001"
+	DefaultUsername    = "fake_account"
+	DefaultAccessToken = "fake-access-token"
+	// DefaultFacebookAccessToken stands in for a Facebook Login token
+	DefaultFacebookAccessToken = "fake-facebook-access-token"
+)
+
+const (
+	// timestampLayout is the layout of Graph API timestamps
+	timestampLayout = "2006-01-02T15:04:05-0700"
+	defaultPageSize = 25
+	maxPageSize     = 100
+	// DefaultPublishLimit is how many posts an account may publish in 24 hours
+	DefaultPublishLimit = 25
+	// usageWindow is the rolling window call usage is computed over
+	usageWindow = time.Hour
+)
+
+// versionPattern matches the API version prefix of a path, e.g. /v12.0
+var versionPattern = regexp.MustCompile(`^v\d+\.\d+$`)
+
+// Server is a fake Graph API backed by an httptest.Server. Its exported fields
+// configure behaviour and must be set before requests are made.
+type Server struct {
+	*httptest.Server
+
+	// UserID, Username and AccessToken identify the account; requests with
+	// another access token are rejected
+	UserID      string
+	Username    string
+	AccessToken string
+	// FacebookAccessToken is the token required by the calls only Facebook
+	// Login serves: hashtag search and media, business discovery and tags.
+	// Those calls are rejected with AccessToken, as graph.instagram.com would.
+	FacebookAccessToken string
+	// Name, FollowersCount and FollowsCount describe the account profile
+	Name           string
+	FollowersCount int
+	FollowsCount   int
+	// ProcessingPolls is how many status checks a new container reports
+	// IN_PROGRESS before it is FINISHED
+	ProcessingPolls int
+	// PublishLimit is how many posts may be published in 24 hours; zero means no limit
+	PublishLimit int
+	// CallLimit is how many calls an hour count as 100% usage in X-App-Usage;
+	// calls over it are rejected. Zero means no limit and no usage header.
+	CallLimit int
+
+	mu              sync.Mutex
+	nextID          int64
+	media           []*mediaObject // newest first
+	mediaByID       map[string]*mediaObject
+	containers      map[string]*Container
+	comments        map[string]*commentObject
+	accountInsights map[string]int
+	// businesses are the other accounts business discovery finds, by lowercase username
+	businesses        map[string]*BusinessAccount
+	tags              []instagram.MentionedMedia // newest first
+	mentionedMedia    map[string]instagram.MentionedMedia
+	mentionedComments map[string]instagram.MentionedComment
+	mentionReplies    []MentionReply
+	hashtags          map[string]*hashtagObject // by ID
+	hashtagIDs        map[string]string         // by name
+	failures          []failure
+	// containerFailures are the statuses queued by FailNextContainer
+	containerFailures []string
+	calls             []time.Time
+	usage             *instagram.UsageReading
+	businessUsage     []instagram.UsageReading
+	requests          []Request
+}
+
+// Request is a request the server received
+type Request struct {
+	Method string
+	// Path is relative to the API version, e.g. "17841400000000001/media"
+	Path   string
+	Params url.Values
+}
+
+// failure is an error queued by FailNext
+type failure struct {
+	method string
+	path   string
+	err    instagram.GraphError
+}
+
+// NewServer starts a fake Graph API with an empty account. The caller must
+// Close it when done.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a fake Graph API that isn't listening yet, so
+// that its listener can be replaced before calling Start
+func NewUnstartedServer() *Server {
+	s := &Server{
+		UserID:              DefaultUserID,
+		Username:            DefaultUsername,
+		AccessToken:         DefaultAccessToken,
+		FacebookAccessToken: DefaultFacebookAccessToken,
+		Name:                "Fake Account",
+		ProcessingPolls:     1,
+		PublishLimit:        DefaultPublishLimit,
+		nextID:              17900000000000000,
+		mediaByID:           make(map[string]*mediaObject),
+		containers:          make(map[string]*Container),
+		comments:            make(map[string]*commentObject),
+		businesses:          make(map[string]*BusinessAccount),
+		mentionedMedia:      make(map[string]instagram.MentionedMedia),
+		mentionedComments:   make(map[string]instagram.MentionedComment),
+		hashtags:            make(map[string]*hashtagObject),
+		hashtagIDs:          make(map[string]string),
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns an Instagram client for the server's account that talks to
+// the server and polls containers without delay
+func (s *Server) Client() *instagram.Client {
+	client, err := instagram.NewClientFor(s.UserID, s.AccessToken)
+	if err != nil {
+		panic(err)
+	}
+	client.BaseURL = s.URL + "/v12.0"
+	client.TokenBaseURL = s.URL
+	client.FacebookBaseURL = s.URL + "/v12.0"
+	client.FacebookAccessToken = s.FacebookAccessToken
+	client.HTTPClient = s.Server.Client()
+	client.PollInterval = 10 * time.Millisecond
+	return client
+}
+
+// FailNext makes the next request matching method and path fail with err.
+// An empty method or path matches any. Path is relative to the API version,
+// e.g. UserID + "/media_publish". The status code defaults to 400.
+func (s *Server) FailNext(method, path string, err instagram.GraphError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, failure{method: method, path: path, err: err})
+}
+
+// SetUsage makes the server report reading in X-App-Usage instead of the
+// usage computed from CallLimit
+func (s *Server) SetUsage(reading instagram.UsageReading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.usage = &reading
+}
+
+// SetBusinessUsage makes the server report readings for the account in
+// X-Business-Use-Case-Usage
+func (s *Server) SetBusinessUsage(readings ...instagram.UsageReading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.businessUsage = readings
+}
+
+// ResetUsage forgets past calls and any usage set with SetUsage or
+// SetBusinessUsage
+func (s *Server) ResetUsage() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = nil
+	s.usage = nil
+	s.businessUsage = nil
+}
+
+// Requests returns the requests received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request{}, s.requests...)
+}
+
+// RateLimitError is the error Instagram returns once the app's call limit is reached
+func RateLimitError() instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode: http.StatusForbidden,
+		Message:    "(#4) Application request limit reached",
+		Type:       "OAuthException",
+		Code:       4,
+	}
+}
+
+// TransientError is an error Instagram returns while it is having trouble
+func TransientError() instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode:  http.StatusServiceUnavailable,
+		Message:     "An unexpected error has occurred. Please retry your request later.",
+		Type:        "OAuthException",
+		Code:        2,
+		IsTransient: true,
+	}
+}
+
+// ExpiredTokenError is the error Instagram returns for an expired access token
+func ExpiredTokenError() instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode:   http.StatusBadRequest,
+		Message:      "Error validating access token: Session has expired",
+		Type:         "OAuthException",
+		Code:         190,
+		ErrorSubcode: 463,
+	}
+}
+
+// NotEnoughViewersError is the error Instagram returns for the insights of a
+// media too few people have seen
+func NotEnoughViewersError() instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode: http.StatusBadRequest,
+		Message:    "(#10) Not enough viewers for the media to show insights",
+		Type:       "OAuthException",
+		Code:       10,
+	}
+}
+
+// PermissionError is the error Instagram returns when the access token lacks
+// a permission the request needs
+func PermissionError() instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode: http.StatusForbidden,
+		Message:    "(#10) Application does not have permission for this action",
+		Type:       "OAuthException",
+		Code:       10,
+	}
+}
+
+// invalidParameter is a (#100) error with the given message
+func invalidParameter(format string, args ...interface{}) instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode: http.StatusBadRequest,
+		Message:    "(#100) " + fmt.Sprintf(format, args...),
+		Type:       "OAuthException",
+		Code:       100,
+	}
+}
+
+// serveHTTP checks the access token, applies queued failures and rate limits,
+// and routes the request
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, invalidParameter("Invalid request body"))
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	if parts := strings.SplitN(path, "/", 2); len(parts) > 0 && versionPattern.MatchString(parts[0]) {
+		path = ""
+		if len(parts) == 2 {
+			path = parts[1]
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, Params: r.Form})
+
+	token := s.AccessToken
+	if s.facebookLoginOnly(r.Method, path, r.Form) {
+		token = s.FacebookAccessToken
+	}
+	if token != "" && r.Form.Get("access_token") != token {
+		writeError(w, instagram.GraphError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid OAuth access token - Cannot parse access token",
+			Type:       "OAuthException",
+			Code:       190,
+		})
+		return
+	}
+
+	if graphErr, ok := s.takeFailure(r.Method, path); ok {
+		writeError(w, graphErr)
+		return
+	}
+
+	if limited := s.recordCall(w.Header()); limited {
+		writeError(w, RateLimitError())
+		return
+	}
+
+	status, body := s.route(r.Method, path, r.Form)
+	if graphErr, ok := body.(instagram.GraphError); ok {
+		writeError(w, graphErr)
+		return
+	}
+	writeJSON(w, status, body)
+}
+
+// facebookLoginOnly reports whether a request is for a call that only the
+// Instagram API with Facebook Login serves
+func (s *Server) facebookLoginOnly(method, path string, params url.Values) bool {
+	if method != http.MethodGet {
+		return false
+	}
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "ig_hashtag_search":
+		return true
+	case len(parts) == 2 && s.hashtags[parts[0]] != nil:
+		return true
+	case path == s.UserID+"/tags":
+		return true
+	case path == s.UserID:
+		return strings.HasPrefix(params.Get("fields"), "business_discovery")
+	}
+	return false
+}
+
+// takeFailure removes and returns the first queued failure matching the request
+func (s *Server) takeFailure(method, path string) (instagram.GraphError, bool) {
+	for i, f := range s.failures {
+		if (f.method == "" || f.method == method) && (f.path == "" || f.path == path) {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			return f.err, true
+		}
+	}
+	return instagram.GraphError{}, false
+}
+
+// recordCall counts a call against CallLimit and sets the usage header. It
+// reports whether the call is over the limit.
+func (s *Server) recordCall(header http.Header) bool {
+	now := time.Now()
+	recent := s.calls[:0]
+	for _, at := range s.calls {
+		if now.Sub(at) < usageWindow {
+			recent = append(recent, at)
+		}
+	}
+	s.calls = append(recent, now)
+
+	reading := s.usage
+	if reading == nil && s.CallLimit > 0 {
+		percent := len(s.calls) * 100 / s.CallLimit
+		reading = &instagram.UsageReading{CallCount: percent, TotalCPUTime: percent / 2, TotalTime: percent / 2}
+	}
+	if reading != nil {
+		encoded, _ := json.Marshal(reading)
+		header.Set("X-App-Usage", string(encoded))
+	}
+	if s.businessUsage != nil {
+		encoded, _ := json.Marshal(map[string][]instagram.UsageReading{s.UserID: s.businessUsage})
+		header.Set("X-Business-Use-Case-Usage", string(encoded))
+	}
+
+	return s.CallLimit > 0 && len(s.calls) > s.CallLimit
+}
+
+// route dispatches a request to its handler. Handlers return the status and
+// body to send, or an instagram.GraphError as the body.
+func (s *Server) route(method, path string, params url.Values) (int, interface{}) {
+	parts := strings.Split(path, "/")
+	id := parts[0]
+	edge := ""
+	if len(parts) > 1 {
+		edge = strings.Join(parts[1:], "/")
+	}
+
+	switch {
+	case method == http.MethodGet && (path == "access_token" || path == "refresh_access_token"):
+		return s.token()
+	case method == http.MethodGet && path == "ig_hashtag_search":
+		return s.searchHashtag(params)
+	case id == s.UserID:
+		return s.routeUser(method, edge, params)
+	case s.mediaByID[id] != nil:
+		return s.routeMedia(method, id, edge, params)
+	case s.containers[id] != nil && method == http.MethodGet && edge == "":
+		return s.containerStatus(id)
+	case s.comments[id] != nil:
+		return s.routeComment(method, id, edge, params)
+	case s.hashtags[id] != nil:
+		return s.routeHashtag(method, id, edge, params)
+	}
+
+	return http.StatusBadRequest, instagram.GraphError{
+		StatusCode: http.StatusBadRequest,
+		Message: fmt.Sprintf("Unsupported %s request. Object with ID '%s' does not exist, cannot be loaded "+
+			"due to missing permissions, or does not support this operation", strings.ToLower(method), id),
+		Type:         "GraphMethodException",
+		Code:         100,
+		ErrorSubcode: 33,
+	}
+}
+
+// routeUser serves the account node and its edges
+func (s *Server) routeUser(method, edge string, params url.Values) (int, interface{}) {
+	switch {
+	case method == http.MethodGet && edge == "":
+		return s.profile(params)
+	case method == http.MethodGet && edge == "media":
+		return s.listMedia(params)
+	case method == http.MethodPost && edge == "media":
+		return s.createContainer(params)
+	case method == http.MethodPost && edge == "media_publish":
+		return s.publish(params)
+	case method == http.MethodGet && edge == "insights":
+		return s.userInsights(params)
+	case method == http.MethodGet && edge == "tags":
+		return s.listTags(params)
+	case method == http.MethodPost && edge == "mentions":
+		return s.replyToMention(params)
+	}
+	return http.StatusBadRequest, invalidParameter("Unsupported %s request to /%s", strings.ToLower(method), edge)
+}
+
+// profile serves the account node, along with business discovery and mention
+// lookups, which are fields expanded on it
+func (s *Server) profile(params url.Values) (int, interface{}) {
+	fields := params.Get("fields")
+	switch {
+	case strings.HasPrefix(fields, "business_discovery"):
+		return s.discoverBusiness(fields)
+	case strings.HasPrefix(fields, "mentioned_media"):
+		return s.getMentionedMedia(fields)
+	case strings.HasPrefix(fields, "mentioned_comment"):
+		return s.getMentionedComment(fields)
+	}
+
+	profile := map[string]interface{}{
+		"id":              s.UserID,
+		"username":        s.Username,
+		"name":            s.Name,
+		"biography":       "",
+		"followers_count": s.FollowersCount,
+		"follows_count":   s.FollowsCount,
+		"media_count":     len(s.media),
+	}
+
+	if limit, ok := mediaLimit(fields); ok {
+		media := make([]interface{}, 0, limit)
+		for i := 0; i < len(s.media) && i < limit; i++ {
+			media = append(media, s.media[i].render())
+		}
+		profile["media"] = map[string]interface{}{"data": media}
+	}
+
+	return http.StatusOK, profile
+}
+
+// token serves the token exchange and refresh endpoints. The access token
+// stays the same so that existing clients keep working.
+func (s *Server) token() (int, interface{}) {
+	return http.StatusOK, map[string]interface{}{
+		"access_token": s.AccessToken,
+		"token_type":   "bearer",
+		"expires_in":   int64((60 * 24 * time.Hour).Seconds()),
+	}
+}
+
+// newID returns a fresh object ID
+func (s *Server) newID() string {
+	s.nextID++
+	return strconv.FormatInt(s.nextID, 10)
+}
+
+// page cuts a page out of n items using the limit and after parameters and
+// returns its bounds along with the paging object to send
+func page(n int, params url.Values) (int, int, map[string]interface{}) {
+	limit := defaultPageSize
+	if raw := params.Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	start := 0
+	if raw := params.Get("after"); raw != "" {
+		if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil {
+			if offset, err := strconv.Atoi(string(decoded)); err == nil && offset >= 0 {
+				start = offset
+			}
+		}
+	}
+	if start > n {
+		start = n
+	}
+	end := start + limit
+	if end > n {
+		end = n
+	}
+
+	cursor := func(offset int) string {
+		return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+	}
+	paging := map[string]interface{}{
+		"cursors": map[string]string{"before": cursor(start), "after": cursor(end)},
+	}
+	if end < n {
+		next := url.Values{}
+		for key, values := range params {
+			if key != "access_token" {
+				next[key] = values
+			}
+		}
+		next.Set("after", cursor(end))
+		paging["next"] = "?" + next.Encode()
+	}
+
+	return start, end, paging
+}
+
+// writeJSON sends body as JSON
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		status = http.StatusInternalServerError
+		encoded = []byte(`{"error":{"message":"failed to encode response","code":1}}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(encoded)
+}
+
+// writeError sends a Graph API error payload
+func writeError(w http.ResponseWriter, graphErr instagram.GraphError) {
+	status := graphErr.StatusCode
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	if graphErr.FBTraceID == "" {
+		graphErr.FBTraceID = "fake"
+	}
+	writeJSON(w, status, map[string]interface{}{"error": graphErr})
+}
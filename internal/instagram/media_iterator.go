@@ -0,0 +1,166 @@
+package instagram
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMediaPageSize = 25
+	maxMediaPageSize     = 100
+)
+
+// mediaTimestampLayout is the format of Media.Timestamp
+const mediaTimestampLayout = "2006-01-02T15:04:05-0700"
+
+// MediaQuery filters and bounds a walk over the account's media
+type MediaQuery struct {
+	// Limit caps the number of media returned; zero means no limit
+	Limit int
+	// PageSize is how many media to request per page
+	PageSize int
+	// Since and Until restrict media to a publish time window when set
+	Since time.Time
+	Until time.Time
+}
+
+// MediaIterator walks the account's media newest first, fetching pages lazily.
+// Use it like bufio.Scanner:
+//
+//	it := client.IterateMedia(MediaQuery{Since: since})
+//	for it.Next() {
+//		media := it.Media()
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// Stopping early is just breaking out of the loop; no further pages are fetched.
+type MediaIterator struct {
+	client  *Client
+	query   MediaQuery
+	page    []Media
+	index   int
+	after   string
+	done    bool
+	count   int
+	current Media
+	err     error
+}
+
+// IterateMedia returns an iterator over the account's media matching query
+func (c *Client) IterateMedia(query MediaQuery) *MediaIterator {
+	if query.PageSize <= 0 || query.PageSize > maxMediaPageSize {
+		query.PageSize = defaultMediaPageSize
+	}
+	if query.Limit > 0 && query.Limit < query.PageSize {
+		query.PageSize = query.Limit
+	}
+
+	return &MediaIterator{client: c, query: query}
+}
+
+// ListMedia collects all media matching query into a slice
+func (c *Client) ListMedia(query MediaQuery) ([]Media, error) {
+	var media []Media
+	it := c.IterateMedia(query)
+	for it.Next() {
+		media = append(media, it.Media())
+	}
+	return media, it.Err()
+}
+
+// Next advances to the next media, fetching the next page when needed.
+// It returns false when the media are exhausted, the limit is reached or an error occurs.
+func (it *MediaIterator) Next() bool {
+	for {
+		if it.err != nil || (it.query.Limit > 0 && it.count >= it.query.Limit) {
+			return false
+		}
+
+		if it.index >= len(it.page) {
+			if it.done {
+				return false
+			}
+			if err := it.fetchPage(); err != nil {
+				it.err = err
+				return false
+			}
+			continue
+		}
+
+		media := it.page[it.index]
+		it.index++
+
+		if !it.query.Since.IsZero() || !it.query.Until.IsZero() {
+			timestamp, err := media.PublishedAt()
+			if err == nil {
+				// Media are returned newest first, so once we're past Since we're done
+				if !it.query.Since.IsZero() && timestamp.Before(it.query.Since) {
+					it.done = true
+					it.page = nil
+					return false
+				}
+				if !it.query.Until.IsZero() && timestamp.After(it.query.Until) {
+					continue
+				}
+			}
+		}
+
+		it.current = media
+		it.count++
+		return true
+	}
+}
+
+// Media returns the media at the current position
+func (it *MediaIterator) Media() Media {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *MediaIterator) Err() error {
+	return it.err
+}
+
+// fetchPage loads the next page of media
+func (it *MediaIterator) fetchPage() error {
+	params := url.Values{}
+	params.Set("fields", mediaFields)
+	params.Set("limit", strconv.Itoa(it.query.PageSize))
+	if !it.query.Since.IsZero() {
+		params.Set("since", strconv.FormatInt(it.query.Since.Unix(), 10))
+	}
+	if !it.query.Until.IsZero() {
+		params.Set("until", strconv.FormatInt(it.query.Until.Unix(), 10))
+	}
+	if it.after != "" {
+		params.Set("after", it.after)
+	}
+
+	var response struct {
+		Data   []Media `json:"data"`
+		Paging struct {
+			Cursors struct {
+				Before string `json:"before"`
+				After  string `json:"after"`
+			} `json:"cursors"`
+			Next string `json:"next"`
+		} `json:"paging"`
+	}
+
+	if err := it.client.get(fmt.Sprintf("%s/media", it.client.UserID), params, &response); err != nil {
+		return fmt.Errorf("failed to list media: %w", err)
+	}
+
+	it.page = response.Data
+	it.index = 0
+	it.after = response.Paging.Cursors.After
+
+	// The API omits "next" on the last page
+	if response.Paging.Next == "" || it.after == "" || len(response.Data) == 0 {
+		it.done = true
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package instagram
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// mentionedMediaFields are the fields requested for media we are tagged or mentioned in
+const mentionedMediaFields = "id,caption,media_type,permalink,timestamp,username,like_count,comments_count"
+
+// mentionedCommentFields are the fields requested for comments we are mentioned in
+const mentionedCommentFields = "id,text,timestamp,username,like_count,media{id,permalink,username}"
+
+// maxTagsPageSize is the largest page the tags edge returns
+const maxTagsPageSize = 50
+
+// MentionedMedia is another account's media that tags or @mentions us
+type MentionedMedia struct {
+	ID            string `json:"id"`
+	Caption       string `json:"caption"`
+	MediaType     string `json:"media_type"`
+	Permalink     string `json:"permalink"`
+	Timestamp     string `json:"timestamp"`
+	Username      string `json:"username"`
+	LikeCount     int    `json:"like_count"`
+	CommentsCount int    `json:"comments_count"`
+}
+
+// PublishedAt parses the media timestamp
+func (m *MentionedMedia) PublishedAt() (time.Time, error) {
+	return time.Parse(mediaTimestampLayout, m.Timestamp)
+}
+
+// MentionedComment is a comment that @mentions us, on any account's media
+type MentionedComment struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Timestamp string `json:"timestamp"`
+	Username  string `json:"username"`
+	LikeCount int    `json:"like_count"`
+	Media     struct {
+		ID        string `json:"id"`
+		Permalink string `json:"permalink"`
+		Username  string `json:"username"`
+	} `json:"media"`
+}
+
+// CommentedAt parses the comment timestamp
+func (c *MentionedComment) CommentedAt() (time.Time, error) {
+	return time.Parse(mediaTimestampLayout, c.Timestamp)
+}
+
+// ListTags gets the media other accounts have tagged us in, newest first.
+// A limit of zero means no limit. The tags edge needs Facebook Login.
+func (c *Client) ListTags(limit int) ([]MentionedMedia, error) {
+	pageSize := maxTagsPageSize
+	if limit > 0 && limit < pageSize {
+		pageSize = limit
+	}
+
+	params := url.Values{}
+	params.Set("fields", mentionedMediaFields)
+	params.Set("limit", strconv.Itoa(pageSize))
+
+	var tags []MentionedMedia
+	for {
+		var response struct {
+			Data   []MentionedMedia `json:"data"`
+			Paging struct {
+				Cursors struct {
+					After string `json:"after"`
+				} `json:"cursors"`
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+
+		if err := c.getFacebook(fmt.Sprintf("%s/tags", c.UserID), params, &response); err != nil {
+			return nil, fmt.Errorf("failed to list tagged media: %w", err)
+		}
+		tags = append(tags, response.Data...)
+
+		if limit > 0 && len(tags) >= limit {
+			return tags[:limit], nil
+		}
+		if response.Paging.Next == "" || response.Paging.Cursors.After == "" || len(response.Data) == 0 {
+			return tags, nil
+		}
+		params.Set("after", response.Paging.Cursors.After)
+	}
+}
+
+// GetMentionedMedia gets a media that @mentions us in its caption. The media
+// ID comes from a mentions webhook; such media can't be listed.
+func (c *Client) GetMentionedMedia(mediaID string) (*MentionedMedia, error) {
+	params := url.Values{}
+	params.Set("fields", fmt.Sprintf("mentioned_media.media_id(%s){%s}", mediaID, mentionedMediaFields))
+
+	var response struct {
+		MentionedMedia *MentionedMedia `json:"mentioned_media"`
+	}
+	if err := c.get(c.UserID, params, &response); err != nil {
+		return nil, fmt.Errorf("failed to get mentioned media %s: %w", mediaID, err)
+	}
+	if response.MentionedMedia == nil {
+		return nil, fmt.Errorf("mentioned media %s not found", mediaID)
+	}
+
+	return response.MentionedMedia, nil
+}
+
+// GetMentionedComment gets a comment that @mentions us. The comment ID comes
+// from a mentions webhook.
+func (c *Client) GetMentionedComment(commentID string) (*MentionedComment, error) {
+	params := url.Values{}
+	params.Set("fields", fmt.Sprintf("mentioned_comment.comment_id(%s){%s}", commentID, mentionedCommentFields))
+
+	var response struct {
+		MentionedComment *MentionedComment `json:"mentioned_comment"`
+	}
+	if err := c.get(c.UserID, params, &response); err != nil {
+		return nil, fmt.Errorf("failed to get mentioned comment %s: %w", commentID, err)
+	}
+	if response.MentionedComment == nil {
+		return nil, fmt.Errorf("mentioned comment %s not found", commentID)
+	}
+
+	return response.MentionedComment, nil
+}
+
+// ReplyToMention comments on a media that @mentions us, or replies to a
+// comment that does when commentID is set, and returns the new comment's ID
+func (c *Client) ReplyToMention(mediaID, commentID, message string) (string, error) {
+	if message == "" {
+		return "", fmt.Errorf("reply message is required")
+	}
+
+	params := url.Values{}
+	params.Set("media_id", mediaID)
+	if commentID != "" {
+		params.Set("comment_id", commentID)
+	}
+	params.Set("message", message)
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := c.post(fmt.Sprintf("%s/mentions", c.UserID), params, &response); err != nil {
+		return "", fmt.Errorf("failed to reply to mention on media %s: %w", mediaID, err)
+	}
+	if response.ID == "" {
+		return "", fmt.Errorf("failed to reply to mention on media %s: no comment ID returned", mediaID)
+	}
+
+	return response.ID, nil
+}
@@ -0,0 +1,72 @@
+package instagram_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+func TestListTagsNewestFirst(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		server.AddTag(instagram.MentionedMedia{
+			Username:  "sudo_sarah",
+			Timestamp: mediaTimestamp(now.Add(-time.Duration(5-i) * time.Hour)),
+		})
+	}
+
+	tags, err := server.Client().ListTags(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 {
+		t.Fatalf("got %d tags, want 3", len(tags))
+	}
+	for i := 1; i < len(tags); i++ {
+		if tags[i].Timestamp > tags[i-1].Timestamp {
+			t.Errorf("tags out of order: %s after %s", tags[i].Timestamp, tags[i-1].Timestamp)
+		}
+	}
+}
+
+func TestMentionLookupsAndReplies(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	client := server.Client()
+
+	media := server.AddMentionedMedia(instagram.MentionedMedia{Caption: "hey @fake_account", Username: "devnull_dan"})
+	comment := server.AddMentionedComment(instagram.MentionedComment{Text: "@fake_account look", Username: "kernel_panic_kim"})
+
+	gotMedia, err := client.GetMentionedMedia(media.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotMedia.Caption != media.Caption || gotMedia.Username != "devnull_dan" {
+		t.Errorf("mentioned media = %+v, want %+v", gotMedia, media)
+	}
+
+	gotComment, err := client.GetMentionedComment(comment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotComment.Text != comment.Text || gotComment.Media.ID != comment.Media.ID {
+		t.Errorf("mentioned comment = %+v, want %+v", gotComment, comment)
+	}
+
+	if _, err := client.GetMentionedMedia("404"); instagram.ErrorKindOf(err) != instagram.ErrorKindInvalidParameter {
+		t.Errorf("unknown media: err = %v, want an invalid parameter", err)
+	}
+
+	if _, err := client.ReplyToMention(media.ID, "", "thanks!"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReplyToMention(comment.Media.ID, comment.ID, "glad you like it"); err != nil {
+		t.Fatal(err)
+	}
+	replies := server.MentionReplies()
+	if len(replies) != 2 || replies[0].MediaID != media.ID || replies[1].CommentID != comment.ID {
+		t.Errorf("mention replies = %+v", replies)
+	}
+}
@@ -0,0 +1,167 @@
+package instagram
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Container status codes reported by the Graph API
+const (
+	ContainerStatusFinished   = "FINISHED"
+	ContainerStatusInProgress = "IN_PROGRESS"
+	ContainerStatusPublished  = "PUBLISHED"
+	ContainerStatusError      = "ERROR"
+	ContainerStatusExpired    = "EXPIRED"
+)
+
+const (
+	defaultPollInterval   = 3 * time.Second
+	defaultPublishTimeout = 2 * time.Minute
+)
+
+// ContainerStatus is the processing state of a media container
+type ContainerStatus struct {
+	ID         string `json:"id"`
+	StatusCode string `json:"status_code"`
+	// Status carries the error description when StatusCode is ERROR
+	Status string `json:"status"`
+}
+
+// PublishError reports a container that could not be published
+type PublishError struct {
+	ContainerID string
+	StatusCode  string
+	Status      string
+}
+
+func (e *PublishError) Error() string {
+	if e.Status != "" {
+		return fmt.Sprintf("media container %s failed with status %s: %s", e.ContainerID, e.StatusCode, e.Status)
+	}
+	return fmt.Sprintf("media container %s failed with status %s", e.ContainerID, e.StatusCode)
+}
+
+// PostContent publishes a single image post and returns the published media ID.
+// It creates a media container, waits for it to finish processing and then
+// publishes it.
+func (c *Client) PostContent(caption string, imageURL string) (string, error) {
+	containerID, err := c.CreateImageContainer(caption, imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	return c.PublishContainer(containerID)
+}
+
+// CreateImageContainer creates the media container of a single image post and
+// waits for it to be ready to publish
+func (c *Client) CreateImageContainer(caption string, imageURL string) (string, error) {
+	params := url.Values{}
+	params.Set("image_url", imageURL)
+	params.Set("caption", caption)
+
+	containerID, err := c.CreateMediaContainer(params)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := c.WaitForContainer(containerID, c.PublishTimeout); err != nil {
+		return "", err
+	}
+
+	return containerID, nil
+}
+
+// CreateMediaContainer creates a media container with the given parameters and returns its ID
+func (c *Client) CreateMediaContainer(params url.Values) (string, error) {
+	var response struct {
+		ID string `json:"id"`
+	}
+
+	if err := c.post(fmt.Sprintf("%s/media", c.UserID), params, &response); err != nil {
+		return "", fmt.Errorf("failed to create media container: %w", err)
+	}
+	if response.ID == "" {
+		return "", fmt.Errorf("failed to create media container: no container ID returned")
+	}
+
+	return response.ID, nil
+}
+
+// GetContainerStatus gets the processing status of a media container
+func (c *Client) GetContainerStatus(containerID string) (*ContainerStatus, error) {
+	params := url.Values{}
+	params.Set("fields", "id,status_code,status")
+
+	var status ContainerStatus
+	if err := c.get(containerID, params, &status); err != nil {
+		return nil, fmt.Errorf("failed to get container status: %w", err)
+	}
+
+	return &status, nil
+}
+
+// WaitForContainer polls a container until it is ready to publish, fails or the timeout elapses
+func (c *Client) WaitForContainer(containerID string, timeout time.Duration) (*ContainerStatus, error) {
+	if timeout <= 0 {
+		timeout = defaultPublishTimeout
+	}
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := c.GetContainerStatus(containerID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch status.StatusCode {
+		case ContainerStatusFinished:
+			return status, nil
+		case ContainerStatusError, ContainerStatusExpired, ContainerStatusPublished:
+			return status, &PublishError{ContainerID: containerID, StatusCode: status.StatusCode, Status: status.Status}
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return status, fmt.Errorf("timed out after %s waiting for media container %s (last status %s)",
+				timeout, containerID, status.StatusCode)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// PublishContainer publishes a finished media container and returns the media ID
+func (c *Client) PublishContainer(containerID string) (string, error) {
+	params := url.Values{}
+	params.Set("creation_id", containerID)
+
+	var response struct {
+		ID string `json:"id"`
+	}
+
+	if err := c.post(fmt.Sprintf("%s/media_publish", c.UserID), params, &response); err != nil {
+		return "", fmt.Errorf("failed to publish media container %s: %w", containerID, err)
+	}
+	if response.ID == "" {
+		return "", fmt.Errorf("failed to publish media container %s: no media ID returned", containerID)
+	}
+
+	return response.ID, nil
+}
+
+// GetMedia gets a single media object
+func (c *Client) GetMedia(mediaID string) (*Media, error) {
+	params := url.Values{}
+	params.Set("fields", mediaFields)
+
+	var media Media
+	if err := c.get(mediaID, params, &media); err != nil {
+		return nil, err
+	}
+
+	return &media, nil
+}
@@ -0,0 +1,409 @@
+package instagram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultThrottlePercent is the usage at which calls start being slowed down
+	defaultThrottlePercent = 80
+	// defaultDeferPercent is the usage at which calls are refused until usage drops
+	defaultDeferPercent = 95
+	// maxThrottleDelay is the longest a call is delayed while throttling
+	maxThrottleDelay = 5 * time.Second
+	// deferInterval is how long calls are held back after a reading over the defer
+	// threshold; the next call afterwards brings a fresh reading
+	deferInterval = 5 * time.Minute
+	// defaultRateLimitBlock is assumed when Instagram rejects a call for exceeding
+	// a limit without saying when access comes back
+	defaultRateLimitBlock = 15 * time.Minute
+	// usageWindow is the rolling window Instagram computes usage over; older
+	// readings say nothing about the current quota
+	usageWindow = time.Hour
+)
+
+// UsageReading is a usage report from the X-App-Usage or X-Business-Use-Case-Usage
+// header. Counts are percentages of the corresponding limit.
+type UsageReading struct {
+	Type         string `json:"type,omitempty"`
+	CallCount    int    `json:"call_count"`
+	TotalCPUTime int    `json:"total_cputime"`
+	TotalTime    int    `json:"total_time"`
+	// EstimatedTimeToRegainAccess is in minutes and only reported per business
+	EstimatedTimeToRegainAccess int `json:"estimated_time_to_regain_access,omitempty"`
+}
+
+// Percent returns the highest of the reported percentages
+func (r UsageReading) Percent() int {
+	percent := r.CallCount
+	if r.TotalCPUTime > percent {
+		percent = r.TotalCPUTime
+	}
+	if r.TotalTime > percent {
+		percent = r.TotalTime
+	}
+	return percent
+}
+
+// BusinessUsage is the usage of one business use case of an Instagram account
+type BusinessUsage struct {
+	// AccountID is the Instagram account whose calls reported the usage
+	AccountID  string `json:"account_id"`
+	BusinessID string `json:"business_id"`
+	UsageReading
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Usage is the API quota as last reported by Instagram
+type Usage struct {
+	App           *UsageReading   `json:"app,omitempty"`
+	AppRecordedAt *time.Time      `json:"app_recorded_at,omitempty"`
+	Business      []BusinessUsage `json:"business"`
+	// Percent is the highest current usage across the app and business limits
+	Percent int `json:"percent"`
+	// BlockedUntil is set while calls are held back after the app hit a limit
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+	// BlockedAccounts are the Instagram accounts whose own limits were hit
+	BlockedAccounts map[string]time.Time `json:"blocked_accounts,omitempty"`
+}
+
+// ThrottleReason says why a call was refused without calling the Graph API
+type ThrottleReason string
+
+// Throttle reasons
+const (
+	// ThrottleBlocked means Instagram rejected an earlier call for exceeding a
+	// limit and access hasn't come back yet
+	ThrottleBlocked ThrottleReason = "blocked"
+	// ThrottleNearLimit means usage is over the defer threshold
+	ThrottleNearLimit ThrottleReason = "near_limit"
+)
+
+// ThrottleError is returned instead of calling the Graph API while a limit is
+// in force or usage is too close to one
+type ThrottleError struct {
+	Reason ThrottleReason
+	// AccountID is the Instagram account whose limit was hit, or empty if it
+	// was the app's
+	AccountID  string
+	Percent    int
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	if e.Reason == ThrottleBlocked {
+		scope := "the app"
+		if e.AccountID != "" {
+			scope = "account " + e.AccountID
+		}
+		return fmt.Sprintf("instagram api rate limit hit for %s, deferring calls for %s",
+			scope, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("instagram api usage at %d%%, deferring calls for %s",
+		e.Percent, e.RetryAfter.Round(time.Second))
+}
+
+// usageTracker is shared across clients since they are created per request,
+// and the quota belongs to the app and account rather than to a client
+var usageTracker = struct {
+	sync.Mutex
+	app   *UsageReading
+	appAt time.Time
+	// business holds each account's readings by business ID and use case
+	business map[string]map[string]BusinessUsage
+	// appBlockedUntil holds back every call after the app hit a limit, and
+	// accountBlockedUntil the calls for an account that hit its own
+	appBlockedUntil     time.Time
+	accountBlockedUntil map[string]time.Time
+}{business: make(map[string]map[string]BusinessUsage), accountBlockedUntil: make(map[string]time.Time)}
+
+// ResetUsage forgets all recorded usage and rate limit blocks. Usage is shared
+// by every client in the process, so tests that run into limits should call
+// it between cases.
+func ResetUsage() {
+	usageTracker.Lock()
+	defer usageTracker.Unlock()
+
+	usageTracker.app = nil
+	usageTracker.appAt = time.Time{}
+	usageTracker.business = make(map[string]map[string]BusinessUsage)
+	usageTracker.appBlockedUntil = time.Time{}
+	usageTracker.accountBlockedUntil = make(map[string]time.Time)
+}
+
+// blockAccount holds back an account's calls until the given time
+func blockAccount(accountID string, until time.Time) {
+	if until.After(usageTracker.accountBlockedUntil[accountID]) {
+		usageTracker.accountBlockedUntil[accountID] = until
+	}
+}
+
+// recordUsage stores the usage headers of a Graph API response made for an
+// Instagram account
+func recordUsage(accountID string, header http.Header) {
+	now := time.Now()
+
+	var app *UsageReading
+	if raw := header.Get("X-App-Usage"); raw != "" {
+		var reading UsageReading
+		if err := json.Unmarshal([]byte(raw), &reading); err == nil {
+			app = &reading
+		}
+	}
+
+	var business map[string][]UsageReading
+	if raw := header.Get("X-Business-Use-Case-Usage"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &business); err != nil {
+			business = nil
+		}
+	}
+
+	if app == nil && business == nil {
+		return
+	}
+
+	usageTracker.Lock()
+	defer usageTracker.Unlock()
+
+	if app != nil {
+		usageTracker.app = app
+		usageTracker.appAt = now
+	}
+	if business != nil && usageTracker.business[accountID] == nil {
+		usageTracker.business[accountID] = make(map[string]BusinessUsage)
+	}
+	for businessID, readings := range business {
+		for _, reading := range readings {
+			usageTracker.business[accountID][businessID+"/"+reading.Type] = BusinessUsage{
+				AccountID:    accountID,
+				BusinessID:   businessID,
+				UsageReading: reading,
+				RecordedAt:   now,
+			}
+			if reading.EstimatedTimeToRegainAccess > 0 {
+				blockAccount(accountID, now.Add(time.Duration(reading.EstimatedTimeToRegainAccess)*time.Minute))
+			}
+		}
+	}
+}
+
+// recordRateLimited notes that Instagram rejected a call made for an account
+// for exceeding a limit. Code 4 is the app's limit and holds back every call;
+// the others hold back the account's. Calls are held back until the time
+// Instagram estimated, or a default if it didn't.
+func recordRateLimited(accountID string, graphErr *GraphError) {
+	usageTracker.Lock()
+	defer usageTracker.Unlock()
+
+	now := time.Now()
+	if graphErr.Code == 4 {
+		if usageTracker.appBlockedUntil.Before(now) {
+			usageTracker.appBlockedUntil = now.Add(defaultRateLimitBlock)
+		}
+		return
+	}
+
+	// The usage headers of the same response may already have set an estimate
+	if usageTracker.accountBlockedUntil[accountID].After(now) {
+		return
+	}
+	blockAccount(accountID, now.Add(defaultRateLimitBlock))
+}
+
+// CurrentUsage returns the API quota as last reported by Instagram for the app
+// and every account. Readings older than the usage window are left out.
+func CurrentUsage() Usage {
+	return usageOf(func(string) bool { return true })
+}
+
+// AccountUsage returns the API quota as last reported by Instagram for the app
+// and one account
+func AccountUsage(accountID string) Usage {
+	return usageOf(func(id string) bool { return id == accountID })
+}
+
+// Usage returns the quota that applies to the client's calls: the app's and
+// its account's
+func (c *Client) Usage() Usage {
+	return AccountUsage(c.UserID)
+}
+
+// usageOf returns the app's usage and that of the accounts include accepts
+func usageOf(include func(accountID string) bool) Usage {
+	usageTracker.Lock()
+	defer usageTracker.Unlock()
+
+	now := time.Now()
+	usage := Usage{Business: []BusinessUsage{}}
+
+	if usageTracker.app != nil && now.Sub(usageTracker.appAt) < usageWindow {
+		app := *usageTracker.app
+		appAt := usageTracker.appAt
+		usage.App = &app
+		usage.AppRecordedAt = &appAt
+		usage.Percent = app.Percent()
+	}
+
+	for accountID, readings := range usageTracker.business {
+		if !include(accountID) {
+			continue
+		}
+		for _, business := range readings {
+			if now.Sub(business.RecordedAt) >= usageWindow {
+				continue
+			}
+			usage.Business = append(usage.Business, business)
+			if business.Percent() > usage.Percent {
+				usage.Percent = business.Percent()
+			}
+		}
+	}
+	sort.Slice(usage.Business, func(i, j int) bool {
+		a, b := usage.Business[i], usage.Business[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.BusinessID != b.BusinessID {
+			return a.BusinessID < b.BusinessID
+		}
+		return a.Type < b.Type
+	})
+
+	if usageTracker.appBlockedUntil.After(now) {
+		blockedUntil := usageTracker.appBlockedUntil
+		usage.BlockedUntil = &blockedUntil
+	}
+	for accountID, blockedUntil := range usageTracker.accountBlockedUntil {
+		if !blockedUntil.After(now) || !include(accountID) {
+			continue
+		}
+		if usage.BlockedAccounts == nil {
+			usage.BlockedAccounts = make(map[string]time.Time)
+		}
+		usage.BlockedAccounts[accountID] = blockedUntil
+	}
+
+	return usage
+}
+
+// latestUsageAt returns when the most recent reading of the app's or an
+// account's usage was taken
+func latestUsageAt(accountID string) time.Time {
+	usageTracker.Lock()
+	defer usageTracker.Unlock()
+
+	latest := usageTracker.appAt
+	for _, business := range usageTracker.business[accountID] {
+		if business.RecordedAt.After(latest) {
+			latest = business.RecordedAt
+		}
+	}
+	return latest
+}
+
+// blockedUntil returns until when calls for an account are held back after
+// it or the app hit a limit, and the account whose limit it was
+func blockedUntil(accountID string) (time.Time, string) {
+	usageTracker.Lock()
+	defer usageTracker.Unlock()
+
+	if usageTracker.appBlockedUntil.After(usageTracker.accountBlockedUntil[accountID]) {
+		return usageTracker.appBlockedUntil, ""
+	}
+	return usageTracker.accountBlockedUntil[accountID], accountID
+}
+
+// throttleDelay works out how to treat the next Graph API call. Only the app's
+// usage and that of the client's account count. It returns a ThrottleError
+// while Instagram is blocking the app or the account or usage is over the
+// defer threshold, and a delay that grows with usage past the throttle
+// threshold.
+func (c *Client) throttleDelay() (time.Duration, error) {
+	throttleAt := c.ThrottlePercent
+	if throttleAt <= 0 {
+		throttleAt = defaultThrottlePercent
+	}
+	deferAt := c.DeferPercent
+	if deferAt <= 0 {
+		deferAt = defaultDeferPercent
+	}
+
+	usage := c.Usage()
+	now := time.Now()
+
+	if until, accountID := blockedUntil(c.UserID); until.After(now) {
+		return 0, &ThrottleError{Reason: ThrottleBlocked, AccountID: accountID, Percent: usage.Percent, RetryAfter: until.Sub(now)}
+	}
+
+	if usage.Percent >= deferAt {
+		if resume := latestUsageAt(c.UserID).Add(deferInterval); resume.After(now) {
+			return 0, &ThrottleError{Reason: ThrottleNearLimit, Percent: usage.Percent, RetryAfter: resume.Sub(now)}
+		}
+		// The reading is old enough that usage has likely dropped; let this call
+		// through to find out
+		return 0, nil
+	}
+
+	if usage.Percent < throttleAt {
+		return 0, nil
+	}
+
+	span := deferAt - throttleAt
+	if span <= 0 {
+		span = 1
+	}
+	delay := maxThrottleDelay * time.Duration(usage.Percent-throttleAt+1) / time.Duration(span)
+	if delay > maxThrottleDelay {
+		delay = maxThrottleDelay
+	}
+	return delay, nil
+}
+
+// throttle runs before every Graph API call, sleeping or refusing the call
+// depending on current usage
+func (c *Client) throttle() error {
+	delay, err := c.throttleDelay()
+	if err != nil {
+		return err
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// CheckQuota returns a *ThrottleError if calls would currently be refused, so
+// that background jobs can skip a run instead of failing part way through
+func (c *Client) CheckQuota() error {
+	_, err := c.throttleDelay()
+	return err
+}
+
+// RefreshUsage makes a cheap call to get a fresh usage reading and returns the
+// usage that applies to the client
+func (c *Client) RefreshUsage() (Usage, error) {
+	params := url.Values{}
+	params.Set("fields", "id")
+
+	var response struct {
+		ID string `json:"id"`
+	}
+	if err := c.get(c.UserID, params, &response); err != nil {
+		return c.Usage(), err
+	}
+
+	return c.Usage(), nil
+}
+
+// isThrottled reports whether err is a call refused by the client's own throttling
+func isThrottled(err error) bool {
+	var throttleErr *ThrottleError
+	return errors.As(err, &throttleErr)
+}
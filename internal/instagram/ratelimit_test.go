@@ -0,0 +1,139 @@
+package instagram_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+	"github.com/igo-used/instagram-ai-agents/internal/instagram/instagramtest"
+)
+
+// newAccountServer starts a fake Graph API for another account, forgetting
+// the usage recorded by earlier tests
+func newAccountServer(t *testing.T, userID string) *instagramtest.Server {
+	t.Helper()
+	instagram.ResetUsage()
+	t.Cleanup(instagram.ResetUsage)
+
+	server := instagramtest.NewServer()
+	server.UserID = userID
+	t.Cleanup(server.Close)
+	return server
+}
+
+// userLimitError is the error Instagram returns once an account's call limit is reached
+func userLimitError() instagram.GraphError {
+	return instagram.GraphError{
+		StatusCode: http.StatusForbidden,
+		Message:    "(#17) User request limit reached",
+		Type:       "OAuthException",
+		Code:       17,
+	}
+}
+
+func throttleErrorOf(t *testing.T, err error) *instagram.ThrottleError {
+	t.Helper()
+	var throttleErr *instagram.ThrottleError
+	if !errors.As(err, &throttleErr) {
+		t.Fatalf("err = %v, want a *ThrottleError", err)
+	}
+	return throttleErr
+}
+
+func TestAccountRateLimitBlocksOnlyThatAccount(t *testing.T) {
+	limited := newAccountServer(t, "17841400000000001")
+	other := newAccountServer(t, "17841400000000002")
+	limited.FailNext("", "", userLimitError())
+
+	client := limited.Client()
+	if _, err := client.RefreshUsage(); instagram.ErrorKindOf(err) != instagram.ErrorKindRateLimit {
+		t.Fatalf("err = %v, want the rate limit error", err)
+	}
+
+	throttleErr := throttleErrorOf(t, client.CheckQuota())
+	if throttleErr.Reason != instagram.ThrottleBlocked || throttleErr.AccountID != limited.UserID {
+		t.Errorf("got %+v, want the limited account blocked", throttleErr)
+	}
+	if throttleErr.RetryAfter <= 0 {
+		t.Errorf("RetryAfter = %s, want the default block", throttleErr.RetryAfter)
+	}
+
+	if _, err := other.Client().RefreshUsage(); err != nil {
+		t.Errorf("other account was held back: %v", err)
+	}
+
+	usage := instagram.CurrentUsage()
+	if usage.BlockedUntil != nil || len(usage.BlockedAccounts) != 1 {
+		t.Errorf("usage = %+v, want only the limited account blocked", usage)
+	}
+}
+
+func TestAppRateLimitBlocksEveryAccount(t *testing.T) {
+	limited := newAccountServer(t, "17841400000000001")
+	other := newAccountServer(t, "17841400000000002")
+	limited.FailNext("", "", instagramtest.RateLimitError())
+
+	if _, err := limited.Client().RefreshUsage(); instagram.ErrorKindOf(err) != instagram.ErrorKindRateLimit {
+		t.Fatalf("err = %v, want the rate limit error", err)
+	}
+
+	_, err := other.Client().RefreshUsage()
+	throttleErr := throttleErrorOf(t, err)
+	if throttleErr.Reason != instagram.ThrottleBlocked || throttleErr.AccountID != "" {
+		t.Errorf("got %+v, want the app blocked", throttleErr)
+	}
+	if len(other.Requests()) != 0 {
+		t.Errorf("blocked call reached the server")
+	}
+}
+
+func TestNearLimitDefersCalls(t *testing.T) {
+	server := newAccountServer(t, instagramtest.DefaultUserID)
+	server.SetUsage(instagram.UsageReading{CallCount: 97, TotalCPUTime: 20, TotalTime: 20})
+
+	client := server.Client()
+	if _, err := client.RefreshUsage(); err != nil {
+		t.Fatalf("RefreshUsage: %v", err)
+	}
+
+	throttleErr := throttleErrorOf(t, client.CheckQuota())
+	if throttleErr.Reason != instagram.ThrottleNearLimit || throttleErr.Percent != 97 {
+		t.Errorf("got %+v, want deferral at 97%%", throttleErr)
+	}
+
+	instagram.ResetUsage()
+	if err := client.CheckQuota(); err != nil {
+		t.Errorf("CheckQuota after reset: %v", err)
+	}
+}
+
+func TestBusinessUsageCountsOnlyForItsAccount(t *testing.T) {
+	busy := newAccountServer(t, "17841400000000001")
+	quiet := newAccountServer(t, "17841400000000002")
+	busy.SetBusinessUsage(instagram.UsageReading{Type: "instagram", CallCount: 97})
+
+	client := busy.Client()
+	if _, err := client.RefreshUsage(); err != nil {
+		t.Fatalf("RefreshUsage: %v", err)
+	}
+
+	throttleErr := throttleErrorOf(t, client.CheckQuota())
+	if throttleErr.Reason != instagram.ThrottleNearLimit {
+		t.Errorf("got %+v, want the busy account deferred", throttleErr)
+	}
+	if err := quiet.Client().CheckQuota(); err != nil {
+		t.Errorf("quiet account was held back: %v", err)
+	}
+
+	usage := instagram.AccountUsage(busy.UserID)
+	if len(usage.Business) != 1 || usage.Business[0].AccountID != busy.UserID || usage.Percent != 97 {
+		t.Errorf("busy account usage = %+v", usage)
+	}
+	if usage := instagram.AccountUsage(quiet.UserID); len(usage.Business) != 0 || usage.Percent != 0 {
+		t.Errorf("quiet account usage = %+v, want none", usage)
+	}
+	if usage := instagram.CurrentUsage(); len(usage.Business) != 1 {
+		t.Errorf("current usage = %+v, want the busy account's reading", usage)
+	}
+}
@@ -0,0 +1,47 @@
+package instagram
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MediaTypeReels is the container media type for Reels
+const MediaTypeReels = "REELS"
+
+// defaultReelTimeout is how long Reels are given to process; video takes far longer than images
+const defaultReelTimeout = 10 * time.Minute
+
+// ReelOptions describes a Reel to publish
+type ReelOptions struct {
+	VideoURL string
+	// CoverURL is an optional cover image; when empty ThumbOffset picks a frame instead
+	CoverURL string
+	// ThumbOffset is the position of the frame used as the thumbnail
+	ThumbOffset time.Duration
+	// ShareToFeed also shows the Reel in the main feed grid
+	ShareToFeed bool
+}
+
+// CreateReelContainer starts uploading a Reel and returns its container ID.
+// The container must finish processing before it can be published.
+func (c *Client) CreateReelContainer(caption string, opts ReelOptions) (string, error) {
+	if opts.VideoURL == "" {
+		return "", fmt.Errorf("reel requires a video URL")
+	}
+
+	params := url.Values{}
+	params.Set("media_type", MediaTypeReels)
+	params.Set("video_url", opts.VideoURL)
+	params.Set("caption", caption)
+	params.Set("share_to_feed", strconv.FormatBool(opts.ShareToFeed))
+
+	if opts.CoverURL != "" {
+		params.Set("cover_url", opts.CoverURL)
+	} else if opts.ThumbOffset > 0 {
+		params.Set("thumb_offset", strconv.FormatInt(opts.ThumbOffset.Milliseconds(), 10))
+	}
+
+	return c.CreateMediaContainer(params)
+}
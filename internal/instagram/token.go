@@ -0,0 +1,108 @@
+package instagram
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token lifetimes enforced by Instagram
+const (
+	// MinTokenAgeForRefresh is how old a long-lived token must be before it can be refreshed
+	MinTokenAgeForRefresh = 24 * time.Hour
+	// LongLivedTokenLifetime is how long a long-lived token stays valid
+	LongLivedTokenLifetime = 60 * 24 * time.Hour
+)
+
+// Token is an access token and when it expires
+type Token struct {
+	AccessToken string    `json:"-"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// tokenResponse is the response of the token exchange and refresh endpoints
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (r tokenResponse) token(issuedAt time.Time) (*Token, error) {
+	if r.AccessToken == "" {
+		return nil, fmt.Errorf("no access token returned")
+	}
+
+	expiresIn := time.Duration(r.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = LongLivedTokenLifetime
+	}
+
+	return &Token{
+		AccessToken: r.AccessToken,
+		TokenType:   r.TokenType,
+		ExpiresAt:   issuedAt.Add(expiresIn),
+	}, nil
+}
+
+// ExchangeToken exchanges a short-lived token, which lasts an hour, for a
+// long-lived one that lasts 60 days. The request is sent with httpClient to
+// the token endpoints at baseURL; nil and empty mean the default client and
+// the Graph API host.
+func ExchangeToken(httpClient *http.Client, baseURL, shortLivedToken, appSecret string) (*Token, error) {
+	if shortLivedToken == "" {
+		return nil, fmt.Errorf("short-lived access token is required")
+	}
+	if appSecret == "" {
+		return nil, fmt.Errorf("app secret is required to exchange tokens")
+	}
+
+	if baseURL == "" {
+		baseURL = graphURL()
+	}
+	client := &Client{AccessToken: shortLivedToken, BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: httpClient}
+
+	params := url.Values{}
+	params.Set("grant_type", "ig_exchange_token")
+	params.Set("client_secret", appSecret)
+
+	issuedAt := time.Now()
+	var response tokenResponse
+	if err := client.get("access_token", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to exchange access token: %w", err)
+	}
+
+	token, err := response.token(issuedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange access token: %w", err)
+	}
+	return token, nil
+}
+
+// RefreshToken refreshes the client's long-lived token, extending it for
+// another 60 days. The token must be at least a day old and not yet expired.
+// The client keeps using the old token; callers should store the new one.
+func (c *Client) RefreshToken() (*Token, error) {
+	baseURL := c.TokenBaseURL
+	if baseURL == "" {
+		baseURL = graphURL()
+	}
+	client := &Client{AccessToken: c.AccessToken, UserID: c.UserID, BaseURL: baseURL, HTTPClient: c.HTTPClient}
+
+	params := url.Values{}
+	params.Set("grant_type", "ig_refresh_token")
+
+	issuedAt := time.Now()
+	var response tokenResponse
+	if err := client.get("refresh_access_token", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to refresh access token: %w", err)
+	}
+
+	token, err := response.token(issuedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh access token: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,146 @@
+package instagram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Webhook fields we subscribe to
+const (
+	WebhookFieldComments      = "comments"
+	WebhookFieldLiveComments  = "live_comments"
+	WebhookFieldMentions      = "mentions"
+	WebhookFieldStoryInsights = "story_insights"
+)
+
+// ErrInvalidSignature is returned when a webhook payload's signature doesn't match
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// VerifySignature checks the X-Hub-Signature-256 header of a webhook delivery,
+// which is the HMAC-SHA256 of the raw body keyed with the app secret
+func VerifySignature(body []byte, signatureHeader, appSecret string) error {
+	if appSecret == "" {
+		return fmt.Errorf("app secret is required to verify webhook signatures")
+	}
+
+	signature := strings.TrimPrefix(signatureHeader, "sha256=")
+	if signature == signatureHeader || signature == "" {
+		return ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// WebhookPayload is a webhook delivery. A delivery can batch several entries,
+// each with several changes.
+type WebhookPayload struct {
+	Object string         `json:"object"`
+	Entry  []WebhookEntry `json:"entry"`
+}
+
+// WebhookEntry holds the changes for one Instagram account
+type WebhookEntry struct {
+	// ID is the Instagram user ID of the account the changes belong to
+	ID      string          `json:"id"`
+	Time    int64           `json:"time"`
+	Changes []WebhookChange `json:"changes"`
+}
+
+// WebhookChange is a single event; Value depends on Field
+type WebhookChange struct {
+	Field string          `json:"field"`
+	Value json.RawMessage `json:"value"`
+}
+
+// CommentEvent is the value of a comments or live_comments change
+type CommentEvent struct {
+	ID       string `json:"id"`
+	ParentID string `json:"parent_id"`
+	Text     string `json:"text"`
+	From     struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Media struct {
+		ID               string `json:"id"`
+		MediaProductType string `json:"media_product_type"`
+	} `json:"media"`
+}
+
+// MentionEvent is the value of a mentions change. CommentID is empty when the
+// account was mentioned in a caption rather than a comment.
+type MentionEvent struct {
+	MediaID   string `json:"media_id"`
+	CommentID string `json:"comment_id"`
+}
+
+// StoryInsightsEvent is the value of a story_insights change, sent when a story expires
+type StoryInsightsEvent struct {
+	MediaID     string `json:"media_id"`
+	Impressions int    `json:"impressions"`
+	Reach       int    `json:"reach"`
+	Replies     int    `json:"replies"`
+	Exits       int    `json:"exits"`
+	TapsForward int    `json:"taps_forward"`
+	TapsBack    int    `json:"taps_back"`
+}
+
+// ParseWebhook parses a webhook delivery
+func ParseWebhook(body []byte) (*WebhookPayload, error) {
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &payload, nil
+}
+
+// Comment decodes the value of a comments or live_comments change
+func (c WebhookChange) Comment() (*CommentEvent, error) {
+	var event CommentEvent
+	if err := json.Unmarshal(c.Value, &event); err != nil {
+		return nil, fmt.Errorf("invalid %s event: %w", c.Field, err)
+	}
+	if event.ID == "" {
+		return nil, fmt.Errorf("invalid %s event: missing comment id", c.Field)
+	}
+	return &event, nil
+}
+
+// Mention decodes the value of a mentions change
+func (c WebhookChange) Mention() (*MentionEvent, error) {
+	var event MentionEvent
+	if err := json.Unmarshal(c.Value, &event); err != nil {
+		return nil, fmt.Errorf("invalid %s event: %w", c.Field, err)
+	}
+	if event.MediaID == "" {
+		return nil, fmt.Errorf("invalid %s event: missing media_id", c.Field)
+	}
+	return &event, nil
+}
+
+// StoryInsights decodes the value of a story_insights change
+func (c WebhookChange) StoryInsights() (*StoryInsightsEvent, error) {
+	var event StoryInsightsEvent
+	if err := json.Unmarshal(c.Value, &event); err != nil {
+		return nil, fmt.Errorf("invalid %s event: %w", c.Field, err)
+	}
+	if event.MediaID == "" {
+		return nil, fmt.Errorf("invalid %s event: missing media_id", c.Field)
+	}
+	return &event, nil
+}
@@ -0,0 +1,53 @@
+package instagram_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/igo-used/instagram-ai-agents/internal/instagram"
+)
+
+// sign returns the X-Hub-Signature-256 header Instagram sends for body
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"object":"instagram","entry":[]}`)
+	valid := sign(body, "app-secret")
+
+	tests := []struct {
+		name   string
+		body   []byte
+		header string
+		secret string
+		want   error
+	}{
+		{name: "valid", body: body, header: valid, secret: "app-secret"},
+		{name: "missing prefix", body: body, header: valid[len("sha256="):], secret: "app-secret", want: instagram.ErrInvalidSignature},
+		{name: "empty signature", body: body, header: "sha256=", secret: "app-secret", want: instagram.ErrInvalidSignature},
+		{name: "bad hex", body: body, header: "sha256=not-hex", secret: "app-secret", want: instagram.ErrInvalidSignature},
+		{name: "wrong secret", body: body, header: sign(body, "other-secret"), secret: "app-secret", want: instagram.ErrInvalidSignature},
+		{name: "tampered body", body: []byte(`{"object":"instagram","entry":[{}]}`), header: valid, secret: "app-secret", want: instagram.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := instagram.VerifySignature(tt.body, tt.header, tt.secret); !errors.Is(err, tt.want) {
+				t.Errorf("VerifySignature = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureNeedsAppSecret(t *testing.T) {
+	body := []byte(`{}`)
+	err := instagram.VerifySignature(body, sign(body, ""), "")
+	if err == nil || errors.Is(err, instagram.ErrInvalidSignature) {
+		t.Errorf("err = %v, want a configuration error", err)
+	}
+}